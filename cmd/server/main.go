@@ -5,6 +5,7 @@ import (
 	"os"
 
 	"admira-etl/internal/api"
+	"admira-etl/internal/storage"
 	"admira-etl/pkg/config"
 )
 
@@ -15,9 +16,20 @@ func main() {
 		log.Fatal("Error loading config:", err)
 	}
 
+	// Inicializar almacenamiento
+	store, err := storage.Open(cfg)
+	if err != nil {
+		log.Fatal("Error opening storage:", err)
+	}
+	defer store.Close()
+
 	// Inicializar servidor
-	server := api.NewServer(cfg)
-	
+	server, err := api.NewServer(cfg, store)
+	if err != nil {
+		log.Fatal("Error initializing server:", err)
+	}
+	defer server.Close()
+
 	// Iniciar servidor
 	if err := server.Start(); err != nil {
 		log.Fatal("Error starting server:", err)
@@ -0,0 +1,159 @@
+package api
+
+import (
+    "encoding/csv"
+    "encoding/json"
+    "fmt"
+    "net/http"
+    "strconv"
+    "strings"
+
+    "admira-etl/internal/etl"
+    "admira-etl/internal/models"
+
+    "github.com/gin-gonic/gin"
+)
+
+// Formatos de salida de los endpoints de métricas
+const (
+    formatJSON   = "json"
+    formatCSV    = "csv"
+    formatNDJSON = "ndjson"
+)
+
+// flushEvery es cada cuántas filas se vacía el buffer al cliente
+const flushEvery = 500
+
+// negotiateFormat elige el formato de salida: el parámetro format tiene
+// prioridad sobre la cabecera Accept
+func negotiateFormat(c *gin.Context) (string, error) {
+    if format := strings.ToLower(c.Query("format")); format != "" {
+        switch format {
+        case formatJSON, formatCSV, formatNDJSON:
+            return format, nil
+        }
+        return "", fmt.Errorf("unknown format %q (use json, csv or ndjson)", format)
+    }
+    
+    accept := c.GetHeader("Accept")
+    switch {
+    case strings.Contains(accept, "text/csv"):
+        return formatCSV, nil
+    case strings.Contains(accept, "application/x-ndjson"), strings.Contains(accept, "application/ndjson"):
+        return formatNDJSON, nil
+    }
+    return formatJSON, nil
+}
+
+// streamMetrics escribe las filas en CSV o NDJSON sin construir la respuesta completa
+func streamMetrics(c *gin.Context, format string, metrics []models.Metrics) {
+    switch format {
+    case formatCSV:
+        streamCSV(c, models.MetricsCSVHeader(), len(metrics), func(i int) []string {
+            return metrics[i].CSVRecord()
+        })
+    case formatNDJSON:
+        streamNDJSON(c, len(metrics), func(i int) interface{} {
+            return metrics[i]
+        })
+    }
+}
+
+// streamSeries escribe una serie temporal; en CSV el bucket va en las primeras columnas
+func streamSeries(c *gin.Context, format string, series []etl.SeriesPoint) {
+    switch format {
+    case formatCSV:
+        header := append([]string{"period", "start", "end"}, models.MetricsCSVHeader()...)
+        streamCSV(c, header, len(series), func(i int) []string {
+            point := series[i]
+            return append([]string{point.Period, point.Start, point.End}, point.Metrics.CSVRecord()...)
+        })
+    case formatNDJSON:
+        streamNDJSON(c, len(series), func(i int) interface{} {
+            return series[i]
+        })
+    }
+}
+
+// streamFunnel escribe un funnel por fila de desglose. En CSV hay una fila por
+// paso; revenue, cost y roas se repiten en todos los pasos de un mismo funnel.
+func streamFunnel(c *gin.Context, format string, breakdowns []funnelBreakdown) {
+    switch format {
+    case formatCSV:
+        header := []string{"key", "start", "end", "step", "count", "step_rate", "cumulative_rate", "revenue", "cost", "roas"}
+        rows := make([][]string, 0, len(breakdowns)*5)
+        for _, b := range breakdowns {
+            var start, end string
+            if b.Bucket != nil {
+                start, end = b.Start, b.End
+            }
+            for _, step := range b.Funnel.Steps {
+                rows = append(rows, []string{
+                    b.Key, start, end, step.Name,
+                    formatFloat(step.Count), formatFloat(step.StepRate), formatFloat(step.CumulativeRate),
+                    formatFloat(b.Funnel.Revenue), formatFloat(b.Funnel.Cost), formatFloat(b.Funnel.ROAS),
+                })
+            }
+        }
+        streamCSV(c, header, len(rows), func(i int) []string {
+            return rows[i]
+        })
+    case formatNDJSON:
+        streamNDJSON(c, len(breakdowns), func(i int) interface{} {
+            return breakdowns[i]
+        })
+    }
+}
+
+func formatFloat(v float64) string {
+    return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// window devuelve los límites [start, end) de una página; limit <= 0 no limita
+func window(n, offset, limit int) (int, int) {
+    if offset > n {
+        offset = n
+    }
+    end := n
+    if limit > 0 && offset+limit < n {
+        end = offset + limit
+    }
+    return offset, end
+}
+
+func streamCSV(c *gin.Context, header []string, n int, record func(i int) []string) {
+    c.Header("Content-Type", "text/csv; charset=utf-8")
+    c.Header("X-Total-Count", strconv.Itoa(n))
+    c.Status(http.StatusOK)
+    
+    writer := csv.NewWriter(c.Writer)
+    writer.Write(header)
+    for i := 0; i < n; i++ {
+        if err := writer.Write(record(i)); err != nil {
+            return
+        }
+        if (i+1)%flushEvery == 0 {
+            writer.Flush()
+            c.Writer.Flush()
+        }
+    }
+    writer.Flush()
+    c.Writer.Flush()
+}
+
+func streamNDJSON(c *gin.Context, n int, item func(i int) interface{}) {
+    c.Header("Content-Type", "application/x-ndjson")
+    c.Header("X-Total-Count", strconv.Itoa(n))
+    c.Status(http.StatusOK)
+    
+    encoder := json.NewEncoder(c.Writer)
+    for i := 0; i < n; i++ {
+        if err := encoder.Encode(item(i)); err != nil {
+            return
+        }
+        if (i+1)%flushEvery == 0 {
+            c.Writer.Flush()
+        }
+    }
+    c.Writer.Flush()
+}
@@ -1,18 +1,21 @@
 ﻿package api
 
 import (
-    "bytes"
-    "crypto/hmac"
-    "crypto/sha256"
-    "encoding/hex"
-    "encoding/json"
+    "context"
+    "errors"
     "fmt"
     "net/http"
     "strconv"
+    "sync"
     "time"
 
+    "admira-etl/internal/breaker"
     "admira-etl/internal/etl"
+    "admira-etl/internal/jobs"
     "admira-etl/internal/models"
+    "admira-etl/internal/retry"
+    "admira-etl/internal/scheduler"
+    "admira-etl/internal/sink"
     "admira-etl/internal/storage"
     "admira-etl/pkg/config"
 
@@ -22,20 +25,135 @@ import (
 type Server struct {
     cfg       *config.Config
     router    *gin.Engine
-    storage   *storage.MemoryStorage
+    storage   storage.Storage
     etl       *etl.Transformer
     extractor *etl.Extractor
+    quarantine *storage.QuarantineStore
+    watermarks *storage.WatermarkStore
+    // records guarda los registros extraídos para recalcular ventanas enteras;
+    // ingestMu serializa la carga de registros y el recálculo de métricas
+    records   *storage.RecordStore
+    ingestMu  sync.Mutex
+    jobs      *jobs.Manager
+    scheduler *scheduler.Scheduler
+    weekStart time.Weekday
+    exports   *sink.Dispatcher
+    // exportGroupBy es la agrupación por defecto de las exportaciones
+    exportGroupBy []etl.Dimension
 }
 
-func NewServer(cfg *config.Config) *Server {
+func NewServer(cfg *config.Config, store storage.Storage) (*Server, error) {
+    registry, err := etl.NewRegistryFromConfig(cfg)
+    if err != nil {
+        return nil, err
+    }
+    
+    model, err := etl.ParseAttributionModel(cfg.AttributionModel)
+    if err != nil {
+        return nil, err
+    }
+    transformOptions := etl.DefaultTransformOptions()
+    transformOptions.Attribution = etl.AttributionConfig{
+        Model:        model,
+        LookbackDays: cfg.AttributionLookbackDays,
+        HalfLifeDays: cfg.AttributionHalfLifeDays,
+    }
+    if cfg.ChannelRulesFile != "" {
+        transformOptions.Channels, err = etl.LoadChannelRules(cfg.ChannelRulesFile)
+        if err != nil {
+            return nil, err
+        }
+    }
+    if !cfg.UTMNormalization {
+        transformOptions.Normalizer = nil
+    } else if cfg.UTMAliasesFile != "" {
+        aliases, err := etl.LoadUTMAliases(cfg.UTMAliasesFile)
+        if err != nil {
+            return nil, err
+        }
+        transformOptions.Normalizer.SetAliases(aliases)
+    }
+
+    weekStart, err := etl.ParseWeekday(cfg.WeekStart)
+    if err != nil {
+        return nil, err
+    }
+    exportGroupBy, err := etl.ParseDimensions(cfg.ExportGroupBy)
+    if err != nil {
+        return nil, fmt.Errorf("EXPORT_GROUP_BY: %v", err)
+    }
+    
+    sinks, err := sink.Open(cfg)
+    if err != nil {
+        return nil, err
+    }
+    outbox, err := storage.OpenOutbox(cfg)
+    if err != nil {
+        return nil, err
+    }
+    history, err := storage.OpenExportHistory(cfg)
+    if err != nil {
+        outbox.Close()
+        return nil, err
+    }
+    
+    models.SetExtraDateFormats(cfg.CRMDateFormats)
+    quarantine, err := storage.OpenQuarantine(cfg)
+    if err != nil {
+        outbox.Close()
+        history.Close()
+        return nil, err
+    }
+    watermarks, err := storage.OpenWatermarks(cfg)
+    if err != nil {
+        quarantine.Close()
+        outbox.Close()
+        history.Close()
+        return nil, err
+    }
+    records, err := storage.OpenRecords(cfg)
+    if err != nil {
+        watermarks.Close()
+        quarantine.Close()
+        outbox.Close()
+        history.Close()
+        return nil, err
+    }
+    
     server := &Server{
         cfg:      cfg,
-        storage:  storage.NewMemoryStorage(),
-        etl:      etl.NewTransformer(),
-        extractor: etl.NewExtractor(cfg),
+        storage:  store,
+        etl:      etl.NewTransformerWithOptions(transformOptions),
+        extractor: etl.NewExtractor(cfg, registry),
+        quarantine: quarantine,
+        watermarks: watermarks,
+        records:  records,
+        jobs:     jobs.NewManager(cfg.IngestWorkers, cfg.IngestQueueSize),
+        weekStart: weekStart,
+        exportGroupBy: etl.ConsolidationGroupBy(exportGroupBy),
+        exports:   sink.NewDispatcher(outbox, history, sinks, sink.DispatcherOptions{
+            MaxAttempts: cfg.OutboxMaxAttempts,
+            Backoff: retry.Backoff{
+                Base: cfg.OutboxBackoff,
+                Max:  cfg.OutboxBackoffMax,
+            },
+            PollInterval: cfg.OutboxPollInterval,
+        }),
+    }
+    
+    server.scheduler, err = server.newScheduler()
+    if err != nil {
+        server.jobs.Stop()
+        server.quarantine.Close()
+        server.watermarks.Close()
+        server.records.Close()
+        outbox.Close()
+        history.Close()
+        return nil, err
     }
+    
     server.setupRouter()
-    return server
+    return server, nil
 }
 
 func (s *Server) setupRouter() {
@@ -48,76 +166,88 @@ func (s *Server) setupRouter() {
     router.GET("/readyz", s.readyCheck)
     
     router.POST("/ingest/run", s.runIngest)
+    router.GET("/ingest/jobs", s.listIngestJobs)
+    router.GET("/ingest/jobs/:id", s.getIngestJob)
+    
+    router.GET("/quarantine", s.listQuarantine)
+    router.POST("/quarantine/reprocess", s.reprocessQuarantine)
+    router.GET("/quarantine/:id", s.getQuarantinedRecord)
+    router.POST("/quarantine/:id/reprocess", s.reprocessQuarantinedRecord)
+    
+    router.GET("/watermarks", s.listWatermarks)
+    router.PUT("/watermarks/:source", s.setWatermark)
+    router.DELETE("/watermarks/:source", s.resetWatermark)
+    
+    router.GET("/schedule", s.getSchedule)
+    router.GET("/schedule/runs", s.listScheduleRuns)
+    router.GET("/schedule/runs/:id", s.getScheduleRun)
     router.POST("/export/run", s.runExport)
+    router.GET("/export/history", s.listExportHistory)
+    router.GET("/export/outbox", s.listOutbox)
+    router.GET("/export/outbox/:id", s.getOutboxEntry)
+    router.GET("/export/dead-letters", s.listDeadLetters)
+    router.POST("/export/dead-letters/replay", s.replayDeadLetters)
+    router.POST("/export/dead-letters/:id/replay", s.replayDeadLetter)
+    router.DELETE("/export/dead-letters/:id", s.removeDeadLetter)
     
     router.GET("/metrics/channel", s.getChannelMetrics)
     router.GET("/metrics/funnel", s.getFunnelMetrics)
+    router.GET("/metrics/query", s.queryMetrics)
+    router.POST("/metrics/query", s.queryMetrics)
+    
+    router.GET("/channels/rules", s.getChannelRules)
+    router.GET("/channels/preview", s.previewChannel)
     
     // Endpoints de debug
     router.GET("/debug/ads", s.debugAds)
     router.GET("/debug/crm", s.debugCRM)
     router.GET("/debug/matches", s.debugMatches)
+    router.GET("/debug/sources", s.debugSources)
     
     s.router = router
 }
 
+// Handler devuelve el router HTTP del servidor
+func (s *Server) Handler() http.Handler {
+    return s.router
+}
+
 func (s *Server) Start() error {
+    s.scheduler.Start()
+    s.exports.Start()
     return s.router.Run(":" + s.cfg.Port)
 }
 
+// Close detiene el scheduler, el outbox y los workers de jobs en segundo plano
+func (s *Server) Close() {
+    s.scheduler.Stop()
+    s.exports.Stop()
+    s.jobs.Stop()
+    s.quarantine.Close()
+    s.watermarks.Close()
+    s.records.Close()
+    s.exports.Outbox().Close()
+    s.exports.History().Close()
+}
+
 func (s *Server) healthCheck(c *gin.Context) {
     c.JSON(http.StatusOK, gin.H{"status": "healthy"})
 }
 
+// readyCheck incluye el estado del circuit breaker de cada origen. Con algún
+// circuito abierto el servicio sigue respondiendo pero se marca como degraded.
 func (s *Server) readyCheck(c *gin.Context) {
-    c.JSON(http.StatusOK, gin.H{"status": "ready"})
-}
-
-func (s *Server) runIngest(c *gin.Context) {
-    ctx := c.Request.Context()
-    
-    sinceStr := c.Query("since")
-    var since time.Time
-    if sinceStr != "" {
-        parsedSince, err := time.Parse("2006-01-02", sinceStr)
-        if err != nil {
-            c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date format. Use YYYY-MM-DD"})
-            return
+    status := "ready"
+    breakers := s.extractor.Breakers()
+    for _, b := range breakers {
+        if b.State != breaker.StateClosed {
+            status = "degraded"
         }
-        since = parsedSince
-    } else {
-        since = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
-    }
-    
-    adsData, err := s.extractor.ExtractAdsData(ctx)
-    if err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to extract ads data: %v", err)})
-        return
-    }
-    
-    crmData, err := s.extractor.ExtractCRMData(ctx)
-    if err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to extract CRM data: %v", err)})
-        return
-    }
-    
-    metrics, err := s.etl.Transform(adsData, crmData)
-    if err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to transform data: %v", err)})
-        return
-    }
-    
-    filteredMetrics := s.etl.FilterByDate(metrics, since)
-    
-    if err := s.storage.StoreMetrics(filteredMetrics); err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to store metrics: %v", err)})
-        return
     }
     
     c.JSON(http.StatusOK, gin.H{
-        "message": "Ingestion completed successfully",
-        "metrics_processed": len(filteredMetrics),
-        "since": since.Format("2006-01-02"),
+        "status": status,
+        "sources": breakers,
     })
 }
 
@@ -159,8 +289,66 @@ func (s *Server) getChannelMetrics(c *gin.Context) {
         }
     }
     
+    bucketOptions, err := s.bucketOptions(c.Query("granularity"), c.Query("week_start"))
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+    
+    compareMode, err := parseCompareMode(c.Query("compare"))
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+    
+    format, err := negotiateFormat(c)
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+    if format != formatJSON && compareMode != "" {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "compare is only available in JSON output"})
+        return
+    }
+    // CSV y NDJSON devuelven todas las filas salvo que se pida un limit
+    if format != formatJSON && limitStr == "" {
+        limit = 0
+    }
+    
     metrics := s.storage.GetMetricsByChannel(channel, from, to)
     
+    extra := gin.H{}
+    if compareMode != "" {
+        extra["comparison"] = s.compare(compareMode, from, to, metrics, func(from, to time.Time) []models.Metrics {
+            return s.storage.GetMetricsByChannel(channel, from, to)
+        })
+    }
+    
+    if bucketOptions != nil {
+        series, err := etl.TimeSeries(metrics, []etl.Dimension{etl.DimensionChannel}, from, to, *bucketOptions)
+        if err != nil {
+            c.JSON(seriesStatus(err), gin.H{"error": err.Error()})
+            return
+        }
+        // La serie siempre incluye el canal consultado, aunque no tenga datos
+        for i := range series {
+            series[i].Channel = channel
+        }
+        if format != formatJSON {
+            start, end := window(len(series), offset, limit)
+            streamSeries(c, format, series[start:end])
+            return
+        }
+        s.paginatedSeries(c, series, bucketOptions, limit, offset, extra)
+        return
+    }
+    
+    if format != formatJSON {
+        start, end := window(len(metrics), offset, limit)
+        streamMetrics(c, format, metrics[start:end])
+        return
+    }
+    
     end := offset + limit
     if end > len(metrics) {
         end = len(metrics)
@@ -172,7 +360,7 @@ func (s *Server) getChannelMetrics(c *gin.Context) {
     
     paginatedMetrics := metrics[offset:end]
     
-    c.JSON(http.StatusOK, gin.H{
+    response := gin.H{
         "data": paginatedMetrics,
         "pagination": gin.H{
             "total": len(metrics),
@@ -180,7 +368,11 @@ func (s *Server) getChannelMetrics(c *gin.Context) {
             "offset": offset,
             "has_more": end < len(metrics),
         },
-    })
+    }
+    for k, v := range extra {
+        response[k] = v
+    }
+    c.JSON(http.StatusOK, response)
 }
 
 func (s *Server) getFunnelMetrics(c *gin.Context) {
@@ -205,181 +397,346 @@ func (s *Server) getFunnelMetrics(c *gin.Context) {
         return
     }
     
-    metrics := s.storage.GetMetricsByCampaign(campaign, from, to)
-    c.JSON(http.StatusOK, metrics)
-}
-
-func (s *Server) runExport(c *gin.Context) {
-    dateStr := c.Query("date")
-    if dateStr == "" {
-        c.JSON(http.StatusBadRequest, gin.H{"error": "date parameter is required"})
+    breakdown := c.Query("breakdown")
+    bucketOptions, err := s.bucketOptions(c.Query("granularity"), c.Query("week_start"))
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
         return
     }
-    
-    date, err := time.Parse("2006-01-02", dateStr)
-    if err != nil {
-        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date format. Use YYYY-MM-DD"})
+    // granularity sin breakdown implica el desglose temporal
+    if bucketOptions != nil && breakdown == "" {
+        breakdown = "day"
+    }
+    if breakdown == "day" && bucketOptions == nil {
+        bucketOptions = &etl.BucketOptions{Granularity: etl.GranularityDay, WeekStart: s.weekStart}
+    }
+    if breakdown != "" && breakdown != "day" && breakdown != "channel" {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid breakdown. Use day or channel"})
         return
     }
-    
-    // Obtener métricas del día
-    metrics := s.storage.GetMetricsByDate(date)
-    if len(metrics) == 0 {
-        c.JSON(http.StatusNotFound, gin.H{"error": "No metrics found for the specified date"})
+    if breakdown == "channel" && bucketOptions != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "granularity only applies to breakdown=day"})
         return
     }
     
-    // Consolidar métricas del día
-    consolidatedMetrics := s.consolidateMetricsByDate(metrics, dateStr)
-    
-    // Verificar si hay SINK_URL configurado
-    if s.cfg.SinkURL == "" {
-        c.JSON(http.StatusOK, gin.H{
-            "message": "Export data prepared (no SINK_URL configured)",
-            "date": dateStr,
-            "metrics": consolidatedMetrics,
-            "total_records": len(consolidatedMetrics),
-        })
+    compareMode, err := parseCompareMode(c.Query("compare"))
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
         return
     }
     
-    // Exportar al sink
-    err = s.exportToSink(consolidatedMetrics, dateStr)
+    format, err := negotiateFormat(c)
     if err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to export to sink: %v", err)})
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
         return
     }
-    
-    c.JSON(http.StatusOK, gin.H{
-        "message": "Export completed successfully",
-        "date": dateStr,
-        "total_records": len(consolidatedMetrics),
-        "sink_url": s.cfg.SinkURL,
-    })
-}
-
-// consolidateMetricsByDate agrupa las métricas por canal y campaña para el día
-func (s *Server) consolidateMetricsByDate(metrics []models.Metrics, date string) []models.Metrics {
-    // Crear un mapa para consolidar por canal y campaña
-    consolidated := make(map[string]*models.Metrics)
-    
-    for _, metric := range metrics {
-        key := fmt.Sprintf("%s_%s_%s", metric.Channel, metric.CampaignID, metric.UTMCampaign)
-        
-        if existing, exists := consolidated[key]; exists {
-            // Consolidar métricas existentes
-            existing.Clicks += metric.Clicks
-            existing.Impressions += metric.Impressions
-            existing.Cost += metric.Cost
-            existing.Leads += metric.Leads
-            existing.Opportunities += metric.Opportunities
-            existing.ClosedWon += metric.ClosedWon
-            existing.Revenue += metric.Revenue
-            
-            // Recalcular métricas derivadas
-            s.calculateDerivedMetrics(existing)
-        } else {
-            // Crear nueva métrica consolidada
-            newMetric := metric
-            consolidated[key] = &newMetric
-        }
+    if format != formatJSON && compareMode != "" {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "compare is only available in JSON output"})
+        return
     }
     
-    // Convertir mapa a slice
-    var result []models.Metrics
-    for _, metric := range consolidated {
-        result = append(result, *metric)
-    }
+    campaign = s.normalizeUTM(etl.FieldUTMCampaign, campaign)
+    metrics := s.storage.GetMetricsByCampaign(campaign, from, to)
     
-    return result
-}
-
-// calculateDerivedMetrics recalcula las métricas derivadas
-func (s *Server) calculateDerivedMetrics(metric *models.Metrics) {
-    if metric.Clicks > 0 {
-        metric.CPC = metric.Cost / float64(metric.Clicks)
-    } else {
-        metric.CPC = 0
+    totals := models.Metrics{}
+    if all := etl.Aggregate(metrics, nil); len(all) > 0 {
+        totals = all[0]
     }
     
-    if metric.Leads > 0 {
-        metric.CPA = metric.Cost / float64(metric.Leads)
-    } else {
-        metric.CPA = 0
+    response := gin.H{
+        "utm_campaign": campaign,
+        "from": fromStr,
+        "to": toStr,
+        "rows": len(metrics),
+        "funnel": etl.BuildFunnel(totals),
     }
     
-    if metric.Leads > 0 {
-        metric.CVRLeadToOpp = float64(metric.Opportunities) / float64(metric.Leads)
-    } else {
-        metric.CVRLeadToOpp = 0
+    if compareMode != "" {
+        comparison := s.compare(compareMode, from, to, metrics, func(from, to time.Time) []models.Metrics {
+            return s.storage.GetMetricsByCampaign(campaign, from, to)
+        })
+        currentFunnel := etl.BuildFunnel(comparison.Current.Totals)
+        previousFunnel := etl.BuildFunnel(comparison.Previous.Totals)
+        comparison.Current.Funnel = &currentFunnel
+        comparison.Previous.Funnel = &previousFunnel
+        response["comparison"] = comparison
     }
     
-    if metric.Opportunities > 0 {
-        metric.CVROppToWon = float64(metric.ClosedWon) / float64(metric.Opportunities)
-    } else {
-        metric.CVROppToWon = 0
+    var breakdowns []funnelBreakdown
+    switch breakdown {
+    case "day":
+        series, err := etl.TimeSeries(metrics, nil, from, to, *bucketOptions)
+        if err != nil {
+            c.JSON(seriesStatus(err), gin.H{"error": err.Error()})
+            return
+        }
+        breakdowns = make([]funnelBreakdown, 0, len(series))
+        for i := range series {
+            breakdowns = append(breakdowns, funnelBreakdown{
+                Key:    series[i].Period,
+                Bucket: &series[i].Bucket,
+                Funnel: etl.BuildFunnel(series[i].Metrics),
+            })
+        }
+        response["breakdown"] = breakdown
+        response["granularity"] = bucketOptions.Granularity
+        response["breakdowns"] = breakdowns
+    case "channel":
+        rows := etl.Aggregate(metrics, []etl.Dimension{etl.DimensionChannel})
+        breakdowns = make([]funnelBreakdown, 0, len(rows))
+        for _, row := range rows {
+            breakdowns = append(breakdowns, funnelBreakdown{
+                Key:    row.Channel,
+                Funnel: etl.BuildFunnel(row),
+            })
+        }
+        response["breakdown"] = breakdown
+        response["breakdowns"] = breakdowns
     }
     
-    if metric.Cost > 0 {
-        metric.ROAS = metric.Revenue / metric.Cost
-    } else {
-        metric.ROAS = 0
+    if format != formatJSON {
+        // Sin desglose se escribe una única fila con el funnel total
+        if breakdown == "" {
+            breakdowns = []funnelBreakdown{{Key: "total", Funnel: etl.BuildFunnel(totals)}}
+        }
+        streamFunnel(c, format, breakdowns)
+        return
     }
+    c.JSON(http.StatusOK, response)
+}
+
+// funnelBreakdown es el funnel de un día (o bucket) o de un canal
+type funnelBreakdown struct {
+    Key string `json:"key"`
+    *etl.Bucket
+    Funnel etl.Funnel `json:"funnel"`
 }
 
-// exportToSink envía los datos al sink con HMAC signature
-func (s *Server) exportToSink(metrics []models.Metrics, date string) error {
-    // Preparar el payload
-    payload := map[string]interface{}{
-        "date": date,
-        "metrics": metrics,
-        "exported_at": time.Now().UTC().Format(time.RFC3339),
+// runExport exporta un día (?date=) o un rango (?from=&to=), pero no ambos. El
+// rango se divide en chunks por día y por tamaño; todos comparten batch_id.
+func (s *Server) runExport(c *gin.Context) {
+    dateStr := c.Query("date")
+    fromStr := c.Query("from")
+    toStr := c.Query("to")
+    if dateStr != "" && (fromStr != "" || toStr != "") {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "date cannot be combined with from or to"})
+        return
+    }
+    if dateStr != "" {
+        fromStr, toStr = dateStr, dateStr
+    }
+    if fromStr == "" || toStr == "" {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "date or from and to parameters are required"})
+        return
     }
     
-    // Serializar a JSON
-    jsonData, err := json.Marshal(payload)
+    from, err := time.Parse("2006-01-02", fromStr)
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date format. Use YYYY-MM-DD"})
+        return
+    }
+    to, err := time.Parse("2006-01-02", toStr)
     if err != nil {
-        return fmt.Errorf("failed to marshal payload: %v", err)
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date format. Use YYYY-MM-DD"})
+        return
+    }
+    if to.Before(from) {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "to must not be before from"})
+        return
+    }
+    if days := int(to.Sub(from).Hours()/24) + 1; s.cfg.ExportMaxRangeDays > 0 && days > s.cfg.ExportMaxRangeDays {
+        c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("export range is limited to %d days", s.cfg.ExportMaxRangeDays)})
+        return
     }
     
-    // Generar HMAC signature
-    signature := s.generateHMACSignature(jsonData)
+    // ?group_by= sustituye la agrupación configurada para esta exportación
+    groupBy := s.exportGroupBy
+    if groupByStr := c.Query("group_by"); groupByStr != "" {
+        parsed, err := etl.ParseDimensions(splitQueryList(groupByStr))
+        if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+            return
+        }
+        groupBy = etl.ConsolidationGroupBy(parsed)
+    }
     
-    // Crear request HTTP
-    req, err := http.NewRequest("POST", s.cfg.SinkURL, bytes.NewBuffer(jsonData))
-    if err != nil {
-        return fmt.Errorf("failed to create request: %v", err)
+    result, err := s.executeExport(c.Request.Context(), from, to, groupBy)
+    if errors.Is(err, errNoMetrics) {
+        c.JSON(http.StatusNotFound, gin.H{"error": "No metrics found for the specified range"})
+        return
+    }
+    if err != nil && !errors.Is(err, errSinkFailed) {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to export: %v", err)})
+        return
     }
     
-    // Configurar headers
-    req.Header.Set("Content-Type", "application/json")
-    req.Header.Set("X-Signature", signature)
-    req.Header.Set("User-Agent", "Admira-ETL-Service/1.0")
+    response := gin.H{
+        "batch_id": result.BatchID,
+        "from": result.From,
+        "to": result.To,
+        "days": result.Days,
+        "total_chunks": result.Chunks,
+        "total_records": result.Records,
+        "group_by": result.GroupBy,
+    }
+    if dateStr != "" {
+        response["date"] = dateStr
+    }
     
-    // Enviar request
-    client := &http.Client{
-        Timeout: s.cfg.Timeout,
+    // Sin sinks configurados solo se devuelven los datos preparados
+    if len(result.Sinks) == 0 {
+        response["message"] = "Export data prepared (no sinks configured)"
+        response["metrics"] = result.Metrics
+        c.JSON(http.StatusOK, response)
+        return
     }
     
-    resp, err := client.Do(req)
-    if err != nil {
-        return fmt.Errorf("failed to send request: %v", err)
+    status := http.StatusOK
+    response["message"] = "Export completed successfully"
+    if dead := result.countSinks(sink.StatusDead); dead > 0 {
+        status = http.StatusBadGateway
+        response["message"] = fmt.Sprintf("Export failed for %d of %d sinks (moved to dead-letter)", dead, len(result.Sinks))
+    } else if !result.Delivered {
+        status = http.StatusAccepted
+        response["message"] = fmt.Sprintf("Export queued for retry for %d of %d sinks", result.countSinks(sink.StatusRetrying), len(result.Sinks))
     }
-    defer resp.Body.Close()
+    response["sinks"] = result.Sinks
+    c.JSON(status, response)
+}
+
+var (
+    errNoMetrics  = errors.New("no metrics found for the specified range")
+    errSinkFailed = errors.New("export failed for one or more sinks")
+)
+
+// exportResult resume una exportación de un rango de días
+type exportResult struct {
+    BatchID string
+    From    string
+    To      string
+    // Days es el número de días con métricas
+    Days    int
+    Chunks  int
+    Records int
+    // GroupBy son las dimensiones con las que se consolidó cada día
+    GroupBy []etl.Dimension
+    Metrics []models.Metrics
+    // Delivered indica que todos los sinks recibieron todos los chunks
+    Delivered bool
+    Sinks     []sink.Result
+}
+
+func (r *exportResult) countSinks(status string) int {
+    count := 0
+    for _, result := range r.Sinks {
+        if result.Status == status {
+            count++
+        }
+    }
+    return count
+}
+
+// executeExport consolida las métricas de cada día del rango por groupBy, las
+// divide en chunks y las entrega a todos los sinks a través del outbox. Si algún
+// chunk acaba en dead-letter devuelve el resultado junto con errSinkFailed.
+func (s *Server) executeExport(ctx context.Context, from, to time.Time, groupBy []etl.Dimension) (*exportResult, error) {
+    result := &exportResult{
+        BatchID: sink.NewBatchID(),
+        From:    from.Format("2006-01-02"),
+        To:      to.Format("2006-01-02"),
+        GroupBy: etl.ConsolidationGroupBy(groupBy),
+    }
+    
+    // Consolidar las métricas día a día; los días sin datos no generan chunks
+    var days []sink.Day
+    for date := from; !date.After(to); date = date.AddDate(0, 0, 1) {
+        metrics := s.storage.GetMetricsByDate(date)
+        if len(metrics) == 0 {
+            continue
+        }
+        
+        dateStr := date.Format("2006-01-02")
+        consolidated, _ := etl.Consolidate(metrics, result.GroupBy)
+        days = append(days, sink.Day{Date: dateStr, Metrics: consolidated})
+        result.Metrics = append(result.Metrics, consolidated...)
+    }
+    // Las exportaciones que no llegan al outbox también quedan en el historial
+    failure := sink.Batch{ID: result.BatchID, From: result.From, To: result.To}
+    if result.From == result.To {
+        failure.Date = result.From
+    }
+    if len(days) == 0 {
+        s.exports.RecordFailure(failure, sink.StatusFailed, errNoMetrics.Error())
+        return nil, errNoMetrics
+    }
+    
+    groupByNames := make([]string, len(result.GroupBy))
+    for i, d := range result.GroupBy {
+        groupByNames[i] = string(d)
+    }
+    // El tamaño de cada chunk se mide en los formatos de los sinks que lo reciben
+    var formats []string
+    for _, target := range s.exports.Sinks() {
+        formats = append(formats, target.Format())
+    }
+    batches := sink.Chunk(sink.Batch{
+        ID:         result.BatchID,
+        From:       result.From,
+        To:         result.To,
+        GroupBy:    groupByNames,
+        ExportedAt: time.Now().UTC(),
+    }, days, sink.ChunkOptions{
+        MaxRecords: s.cfg.ExportMaxRecords,
+        MaxBytes:   s.cfg.ExportMaxBytes,
+        Formats:    formats,
+    })
+    result.Days = len(days)
+    result.Chunks = len(batches)
+    result.Records = len(result.Metrics)
     
-    // Verificar respuesta
-    if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-        return fmt.Errorf("sink returned status %d", resp.StatusCode)
+    // Verificar si hay sinks configurados
+    if len(s.exports.Sinks()) == 0 {
+        failure.Metrics = result.Metrics
+        s.exports.RecordFailure(failure, sink.StatusSkipped, "no sinks configured")
+        return result, nil
     }
     
-    return nil
+    // Los chunks quedan en el outbox antes del primer intento; los fallos se reintentan
+    sinks, err := s.exports.Export(ctx, batches)
+    if err != nil {
+        return nil, err
+    }
+    result.Sinks = sinks
+    if dead := result.countSinks(sink.StatusDead); dead > 0 {
+        return result, fmt.Errorf("%w: %d of %d moved to dead-letter", errSinkFailed, dead, len(result.Sinks))
+    }
+    result.Delivered = result.countSinks(sink.StatusDelivered) == len(result.Sinks)
+    return result, nil
 }
 
-// generateHMACSignature genera la firma HMAC-SHA256
-func (s *Server) generateHMACSignature(data []byte) string {
-    h := hmac.New(sha256.New, []byte(s.cfg.SinkSecret))
-    h.Write(data)
-    return hex.EncodeToString(h.Sum(nil))
+func (s *Server) getChannelRules(c *gin.Context) {
+    rules := s.etl.Options().Channels.Rules()
+    c.JSON(http.StatusOK, gin.H{
+        "rules": rules,
+        "total_rules": len(rules),
+    })
+}
+
+// previewChannel muestra qué regla resuelve el canal de un par utm_source/utm_medium
+func (s *Server) previewChannel(c *gin.Context) {
+    source := c.Query("utm_source")
+    medium := c.Query("utm_medium")
+    if source == "" && medium == "" {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "utm_source or utm_medium parameter is required"})
+        return
+    }
+    
+    match := s.etl.Options().Channels.Match(source, medium)
+    c.JSON(http.StatusOK, gin.H{
+        "utm_source": source,
+        "utm_medium": medium,
+        "match": match,
+    })
 }
 
 // Endpoints de debug
@@ -396,16 +753,15 @@ func (s *Server) debugAds(c *gin.Context) {
         return
     }
     
-    ctx := c.Request.Context()
-    adsData, err := s.extractor.ExtractAdsData(ctx)
+    records, err := s.extractDebugRecords(c)
     if err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to extract ads data: %v", err)})
+        c.JSON(extractErrorStatus(err), gin.H{"error": fmt.Sprintf("Failed to extract ads data: %v", err)})
         return
     }
     
     // Filtrar por fecha
     var filteredAds []models.AdsPerformance
-    for _, ad := range adsData {
+    for _, ad := range records.Ads {
         adDate, err := time.Parse("2006-01-02", ad.Date)
         if err == nil && adDate.Equal(date) {
             filteredAds = append(filteredAds, ad)
@@ -432,16 +788,15 @@ func (s *Server) debugCRM(c *gin.Context) {
         return
     }
     
-    ctx := c.Request.Context()
-    crmData, err := s.extractor.ExtractCRMData(ctx)
+    records, err := s.extractDebugRecords(c)
     if err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to extract CRM data: %v", err)})
+        c.JSON(extractErrorStatus(err), gin.H{"error": fmt.Sprintf("Failed to extract CRM data: %v", err)})
         return
     }
     
     // Filtrar por fecha
     var filteredCRM []models.CRMOpportunity
-    for _, crm := range crmData {
+    for _, crm := range records.CRM {
         crmDate := crm.CreatedAt.Format("2006-01-02")
         if crmDate == dateStr {
             filteredCRM = append(filteredCRM, crm)
@@ -462,40 +817,96 @@ func (s *Server) debugMatches(c *gin.Context) {
         return
     }
     
-    ctx := c.Request.Context()
-    adsData, err := s.extractor.ExtractAdsData(ctx)
+    records, err := s.extractDebugRecords(c)
     if err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to extract ads data: %v", err)})
+        c.JSON(extractErrorStatus(err), gin.H{"error": fmt.Sprintf("Failed to extract data: %v", err)})
         return
     }
     
-    crmData, err := s.extractor.ExtractCRMData(ctx)
-    if err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to extract CRM data: %v", err)})
-        return
+    // Comparar con los UTMs ya normalizados, igual que hace el transformer
+    campaign = s.normalizeUTM(etl.FieldUTMCampaign, campaign)
+    if normalizer := s.etl.Options().Normalizer; normalizer != nil {
+        records.Ads = normalizer.NormalizeAds(records.Ads, nil)
+        records.CRM = normalizer.NormalizeCRM(records.CRM, nil)
     }
     
     // Filtrar por campaña
     var matchingAds []models.AdsPerformance
     var matchingCRM []models.CRMOpportunity
     
-    for _, ad := range adsData {
+    for _, ad := range records.Ads {
         if ad.UTMCampaign == campaign {
             matchingAds = append(matchingAds, ad)
         }
     }
     
-    for _, crm := range crmData {
+    for _, crm := range records.CRM {
         if crm.UTMCampaign == campaign {
             matchingCRM = append(matchingCRM, crm)
         }
     }
     
-    c.JSON(http.StatusOK, gin.H{
+    response := gin.H{
         "utm_campaign": campaign,
         "ads_matches": matchingAds,
         "crm_matches": matchingCRM,
         "ads_count": len(matchingAds),
         "crm_count": len(matchingCRM),
+    }
+    
+    // Con ?model= se muestra cómo quedaría la atribución para la campaña
+    if modelName := c.Query("model"); modelName != "" {
+        options := s.etl.Options()
+        options.Attribution.Model, err = etl.ParseAttributionModel(modelName)
+        if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+            return
+        }
+        result, err := s.etl.TransformWithOptions(matchingAds, matchingCRM, options)
+        if err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to transform data: %v", err)})
+            return
+        }
+        response["attributed_metrics"] = result.Metrics
+        response["attribution"] = result.Attribution
+    }
+    
+    c.JSON(http.StatusOK, response)
+}
+
+func (s *Server) debugSources(c *gin.Context) {
+    var names []string
+    for _, src := range s.extractor.Registry().Sources() {
+        names = append(names, src.Name())
+    }
+    
+    c.JSON(http.StatusOK, gin.H{
+        "sources": names,
+        "total_sources": len(names),
     })
 }
+
+// extractErrorStatus responde 503 si el origen tiene el circuito abierto
+func extractErrorStatus(err error) int {
+    if errors.Is(err, breaker.ErrOpen) {
+        return http.StatusServiceUnavailable
+    }
+    return http.StatusInternalServerError
+}
+
+// extractDebugRecords extrae el origen indicado en ?source= o todos los registrados
+func (s *Server) extractDebugRecords(c *gin.Context) (*etl.Records, error) {
+    ctx := c.Request.Context()
+    if name := c.Query("source"); name != "" {
+        return s.extractor.Extract(ctx, name)
+    }
+    return s.extractor.ExtractAll(ctx)
+}
+
+// normalizeUTM aplica a un parámetro de consulta la misma normalización que la ingesta
+func (s *Server) normalizeUTM(field, value string) string {
+    if normalizer := s.etl.Options().Normalizer; normalizer != nil {
+        return normalizer.Normalize(field, value, nil)
+    }
+    return value
+}
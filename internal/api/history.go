@@ -0,0 +1,54 @@
+package api
+
+import (
+    "net/http"
+    "strconv"
+    "time"
+
+    "admira-etl/internal/storage"
+
+    "github.com/gin-gonic/gin"
+)
+
+// listExportHistory lista los intentos de entrega, los más recientes primero.
+// ?date= responde a "¿se entregó este día?"; from/to acotan por día del chunk.
+func (s *Server) listExportHistory(c *gin.Context) {
+    filter := storage.ExportHistoryFilter{
+        Sink:     c.Query("sink"),
+        BatchID:  c.Query("batch_id"),
+        OutboxID: c.Query("outbox_id"),
+        Status:   c.Query("status"),
+        From:     c.Query("from"),
+        To:       c.Query("to"),
+    }
+    if date := c.Query("date"); date != "" {
+        filter.From, filter.To = date, date
+    }
+    for _, value := range []string{filter.From, filter.To} {
+        if value == "" {
+            continue
+        }
+        if _, err := time.Parse("2006-01-02", value); err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date format. Use YYYY-MM-DD"})
+            return
+        }
+    }
+    
+    limit := 100
+    if limitStr := c.Query("limit"); limitStr != "" {
+        if parsedLimit, err := strconv.Atoi(limitStr); err == nil && parsedLimit > 0 {
+            limit = parsedLimit
+        }
+    }
+    
+    attempts := s.exports.History().List(filter)
+    total := len(attempts)
+    if len(attempts) > limit {
+        attempts = attempts[:limit]
+    }
+    
+    c.JSON(http.StatusOK, gin.H{
+        "data": attempts,
+        "total": total,
+    })
+}
@@ -0,0 +1,449 @@
+package api
+
+import (
+    "context"
+    "fmt"
+    "net/http"
+    "strconv"
+    "time"
+
+    "admira-etl/internal/etl"
+    "admira-etl/internal/jobs"
+    "admira-etl/internal/models"
+    "admira-etl/internal/storage"
+
+    "github.com/gin-gonic/gin"
+)
+
+const ingestJobType = "ingest"
+
+// Marcas que envía una ingesta a los orígenes incrementales
+const (
+    // incrementalWatermark envía la marca guardada de cada origen
+    incrementalWatermark = "watermark"
+    // incrementalSince envía el since de la petición a los orígenes por fecha
+    incrementalSince = "since"
+    // incrementalFull no envía marcas y descarga todo
+    incrementalFull = "full"
+)
+
+// ingestParams son los parámetros de una ejecución del pipeline de ingesta
+type ingestParams struct {
+    Since       time.Time
+    Attribution etl.AttributionModel
+    Incremental string
+}
+
+func (p ingestParams) toMap() map[string]string {
+    return map[string]string{
+        "since": p.Since.Format("2006-01-02"),
+        "attribution": string(p.Attribution),
+        "incremental": p.Incremental,
+    }
+}
+
+// runIngest encola un job de ingesta y responde inmediatamente con su ID
+func (s *Server) runIngest(c *gin.Context) {
+    sinceStr := c.Query("since")
+    var since time.Time
+    incremental := incrementalWatermark
+    if sinceStr != "" {
+        parsedSince, err := time.Parse("2006-01-02", sinceStr)
+        if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date format. Use YYYY-MM-DD"})
+            return
+        }
+        since = parsedSince
+        incremental = incrementalSince
+    } else {
+        since = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+    }
+    
+    attribution := s.etl.Options().Attribution.Model
+    if modelName := c.Query("attribution"); modelName != "" {
+        model, err := etl.ParseAttributionModel(modelName)
+        if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+            return
+        }
+        attribution = model
+    }
+    
+    if c.Query("full") == "true" {
+        incremental = incrementalFull
+    }
+    
+    job, err := s.submitIngest(ingestParams{Since: since, Attribution: attribution, Incremental: incremental})
+    if err != nil {
+        c.JSON(http.StatusServiceUnavailable, gin.H{"error": fmt.Sprintf("Failed to enqueue ingest job: %v", err)})
+        return
+    }
+    
+    c.JSON(http.StatusAccepted, gin.H{
+        "message": "Ingestion job enqueued",
+        "job_id": job.ID,
+        "status": job.Status,
+        "status_url": "/ingest/jobs/" + job.ID,
+        "since": since.Format("2006-01-02"),
+        "attribution": attribution,
+        "incremental": incremental,
+    })
+}
+
+func (s *Server) submitIngest(params ingestParams) (jobs.Job, error) {
+    return s.jobs.Submit(ingestJobType, params.toMap(), func(ctx context.Context, t *jobs.Tracker) error {
+        return s.executeIngest(ctx, params, t)
+    })
+}
+
+// executeIngest ejecuta extracción, transformación y carga registrando cada etapa.
+// Cada página extraída se guarda según llega en el almacén de registros y las
+// métricas se recalculan con todo lo guardado en la ventana afectada, no solo
+// con lo que trajo esta extracción incremental.
+func (s *Server) executeIngest(ctx context.Context, params ingestParams, t *jobs.Tracker) error {
+    options := s.etl.Options()
+    if params.Attribution != "" {
+        options.Attribution.Model = params.Attribution
+    }
+    acc, err := s.etl.NewAccumulator(options)
+    if err != nil {
+        return fmt.Errorf("failed to transform data: %v", err)
+    }
+    
+    // Las ingestas se serializan: el recálculo lee lo que guardaron todas
+    s.ingestMu.Lock()
+    defer s.ingestMu.Unlock()
+    
+    watermarks := s.ingestWatermarks(params)
+    var (
+        summary  *etl.Records
+        rejected []etl.RejectedRecord
+        ads, crm int
+    )
+    loads := make(map[string]*storage.RecordLoad)
+    err = t.Stage("extract", func() error {
+        extracted, err := s.extractor.ExtractPages(ctx, watermarks, func(source string, page *etl.Records) error {
+            load, err := s.recordLoad(loads, source, watermarks[source])
+            if err != nil {
+                return err
+            }
+            if err := load.Add(page.Ads, page.CRM); err != nil {
+                return fmt.Errorf("failed to store records: %v", err)
+            }
+            ads += len(page.Ads)
+            crm += len(page.CRM)
+            rejected = append(rejected, page.Rejected...)
+            return nil
+        })
+        summary = extracted
+        if err != nil {
+            return fmt.Errorf("failed to extract data: %v", err)
+        }
+        return nil
+    })
+    // Los intentos de descarga se reportan también si la extracción falla
+    if summary != nil {
+        s.reportFetches(t, summary.Fetches)
+        s.reportSources(t, summary)
+    }
+    if err != nil {
+        for _, load := range loads {
+            load.Finish(false)
+        }
+        return err
+    }
+    if err := s.finishLoads(loads, summary.Sources, watermarks); err != nil {
+        return err
+    }
+    t.SetCount("ads_records", ads)
+    t.SetCount("crm_records", crm)
+    if len(rejected) > 0 {
+        t.SetCount("quarantined_records", s.quarantineRejected(rejected))
+    }
+    
+    var window *metricsWindow
+    err = t.Stage("transform", func() error {
+        window, err = s.transformWindow(acc, options.Attribution, params.Since, params.Incremental == incrementalFull)
+        if err != nil {
+            return fmt.Errorf("failed to transform data: %v", err)
+        }
+        if window != nil {
+            t.SetDetail("attribution", window.Result.Attribution)
+            t.SetDetail("utm_normalization", window.Result.Normalization)
+            t.SetDetail("recomputed_from", window.From.Format("2006-01-02"))
+        }
+        return nil
+    })
+    if err != nil {
+        return err
+    }
+    if window != nil {
+        t.SetCount("metrics_processed", len(window.Metrics))
+    } else {
+        t.SetCount("metrics_processed", 0)
+    }
+    
+    err = t.Stage("load", func() error {
+        return s.loadWindow(window)
+    })
+    if err != nil {
+        return err
+    }
+    
+    s.advanceWatermarks(t, summary.Sources)
+    return nil
+}
+
+// recordLoad devuelve la carga de registros de un origen, abriéndola con su
+// primera página. Sin marca la extracción es completa y sustituye todo lo
+// guardado del origen.
+func (s *Server) recordLoad(loads map[string]*storage.RecordLoad, source, watermark string) (*storage.RecordLoad, error) {
+    if load, ok := loads[source]; ok {
+        return load, nil
+    }
+    completeFrom := ""
+    if incremental, ok := s.extractor.Incremental(source); ok {
+        var err error
+        if completeFrom, err = etl.CompleteFrom(incremental, watermark); err != nil {
+            return nil, err
+        }
+    }
+    load := s.records.Load(source, watermark == "", completeFrom)
+    loads[source] = load
+    return load, nil
+}
+
+// finishLoads cierra la carga de cada origen. Solo se guardan los que
+// terminaron bien: los fallidos conservan su marca y se vuelven a descargar en
+// la siguiente ingesta.
+func (s *Server) finishLoads(loads map[string]*storage.RecordLoad, reports []etl.SourceReport, watermarks map[string]string) error {
+    var firstErr error
+    succeeded := make(map[string]bool)
+    for _, report := range reports {
+        if report.Status != etl.SourceSucceeded {
+            continue
+        }
+        succeeded[report.Source] = true
+        // Un origen completo sin registros también sustituye lo guardado
+        if _, err := s.recordLoad(loads, report.Source, watermarks[report.Source]); err != nil && firstErr == nil {
+            firstErr = fmt.Errorf("failed to store records of source %s: %v", report.Source, err)
+        }
+    }
+    for source, load := range loads {
+        if err := load.Finish(succeeded[source]); err != nil && firstErr == nil {
+            firstErr = fmt.Errorf("failed to store records of source %s: %v", source, err)
+        }
+    }
+    return firstErr
+}
+
+// metricsWindow son las métricas recalculadas desde From con los registros guardados
+type metricsWindow struct {
+    From    time.Time
+    Metrics []models.Metrics
+    Result  *etl.TransformResult
+    // clears indica que From cubre todo lo pendiente de recalcular
+    clears bool
+    // attribution es la atribución de todas las métricas guardadas tras la
+    // carga; nil si mezclan varias
+    attribution *etl.AttributionConfig
+}
+
+// transformWindow recalcula las métricas afectadas por los cambios pendientes
+// del almacén de registros sin bajar de since. Un cambio en el día P altera las
+// filas desde P menos la ventana de atribución (L): se recalculan con las filas
+// de Ads desde P-2L, que pueden recibir crédito de los registros CRM creados
+// desde P-L. Con full, o si las métricas guardadas se calcularon con otra
+// atribución, se recalcula todo. Devuelve nil si no hay nada que recalcular.
+// Debe llamarse con ingestMu.
+func (s *Server) transformWindow(acc *etl.Accumulator, attribution etl.AttributionConfig, since time.Time, full bool) (*metricsWindow, error) {
+    stored, known := s.records.Attribution()
+    all := full || !known || stored != attribution
+    lookback := attribution.LookbackDays
+    
+    pending := s.records.Pending()
+    if pending == "" && !all {
+        return nil, nil
+    }
+    var pendingFrom time.Time
+    if pending != "" {
+        day, err := time.Parse("2006-01-02", pending)
+        if err != nil {
+            return nil, fmt.Errorf("invalid pending date %q: %v", pending, err)
+        }
+        pendingFrom = day.AddDate(0, 0, -lookback)
+    }
+    
+    var recalcFrom time.Time
+    adsFrom, crmFrom := "", ""
+    if !all {
+        recalcFrom = pendingFrom
+        adsFrom = recalcFrom.AddDate(0, 0, -lookback).Format("2006-01-02")
+        crmFrom = recalcFrom.Format("2006-01-02")
+    }
+    err := s.records.Window(adsFrom, crmFrom, func(adsData []models.AdsPerformance, crmData []models.CRMOpportunity) {
+        if len(adsData) > 0 {
+            acc.AddAds(adsData)
+        }
+        if len(crmData) > 0 {
+            acc.AddCRM(crmData)
+        }
+    })
+    if err != nil {
+        return nil, err
+    }
+    result := acc.Result()
+    
+    from := recalcFrom
+    if since.After(from) {
+        from = since
+    }
+    window := &metricsWindow{
+        From:        from,
+        Metrics:     s.etl.FilterByDate(result.Metrics, from),
+        Result:      result,
+        clears:      pending == "" || !from.After(pendingFrom),
+        attribution: &attribution,
+    }
+    // Un recálculo completo que since deja a medias mezcla atribuciones
+    if all && len(window.Metrics) < len(result.Metrics) {
+        window.attribution = nil
+    }
+    return window, nil
+}
+
+// loadWindow sustituye las métricas guardadas desde el inicio de la ventana y
+// registra en el almacén de registros lo que queda pendiente y la atribución
+// de las métricas. Debe llamarse con ingestMu.
+func (s *Server) loadWindow(window *metricsWindow) error {
+    if window == nil {
+        return nil
+    }
+    if err := s.storage.ReplaceMetrics(window.From, window.Metrics); err != nil {
+        return fmt.Errorf("failed to store metrics: %v", err)
+    }
+    if err := s.records.MetricsLoaded(window.clears, window.attribution); err != nil {
+        return fmt.Errorf("failed to store records: %v", err)
+    }
+    return nil
+}
+
+// ingestWatermarks devuelve la marca que se envía a cada origen incremental
+func (s *Server) ingestWatermarks(params ingestParams) map[string]string {
+    watermarks := make(map[string]string)
+    if params.Incremental == incrementalFull {
+        return watermarks
+    }
+    
+    for _, src := range s.extractor.Registry().Sources() {
+        incremental, ok := s.extractor.Incremental(src.Name())
+        if !ok {
+            continue
+        }
+        if params.Incremental == incrementalSince && incremental.Type == etl.IncrementalDate {
+            watermarks[src.Name()] = params.Since.UTC().Format(time.RFC3339)
+            continue
+        }
+        if mark, ok := s.watermarks.Get(src.Name()); ok {
+            watermarks[src.Name()] = mark.Value
+        }
+    }
+    return watermarks
+}
+
+// advanceWatermarks guarda la marca alcanzada por cada origen que terminó
+// bien; en una ingesta parcial los orígenes fallidos conservan la suya. Si no
+// se puede guardar, la siguiente ingesta vuelve a descargar desde la anterior.
+func (s *Server) advanceWatermarks(t *jobs.Tracker, reports []etl.SourceReport) {
+    advanced := make(map[string]string)
+    failed := make(map[string]string)
+    for _, report := range reports {
+        if report.Status != etl.SourceSucceeded || report.NextWatermark == "" {
+            continue
+        }
+        incremental, ok := s.extractor.Incremental(report.Source)
+        if !ok {
+            continue
+        }
+        mark, moved, err := s.watermarks.Advance(report.Source, incremental.Type, report.NextWatermark, t.JobID())
+        if err != nil {
+            failed[report.Source] = err.Error()
+            continue
+        }
+        if moved {
+            advanced[report.Source] = mark.Value
+        }
+    }
+    if len(advanced) > 0 {
+        t.SetDetail("watermarks", advanced)
+    }
+    if len(failed) > 0 {
+        t.SetDetail("watermark_errors", failed)
+    }
+}
+
+// reportFetches añade al job cada intento de descarga y el total de intentos
+func (s *Server) reportFetches(t *jobs.Tracker, fetches []etl.FetchReport) {
+    attempts := 0
+    for _, fetch := range fetches {
+        attempts += len(fetch.Attempts)
+    }
+    t.SetCount("fetch_attempts", attempts)
+    t.SetDetail("fetches", fetches)
+}
+
+// reportSources añade el resultado de cada origen y marca el job como parcial
+// si alguno falló con on_error=continue
+func (s *Server) reportSources(t *jobs.Tracker, records *etl.Records) {
+    t.SetDetail("sources", records.Sources)
+    if !records.Partial {
+        return
+    }
+    
+    failed := 0
+    for _, report := range records.Sources {
+        if report.Status != etl.SourceSucceeded {
+            failed++
+        }
+    }
+    t.SetDetail("partial", true)
+    t.SetCount("failed_sources", failed)
+}
+
+func (s *Server) getIngestJob(c *gin.Context) {
+    job, ok := s.jobs.Get(c.Param("id"))
+    if !ok || job.Type != ingestJobType {
+        c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+        return
+    }
+    c.JSON(http.StatusOK, job)
+}
+
+func (s *Server) listIngestJobs(c *gin.Context) {
+    limit := 50
+    if limitStr := c.Query("limit"); limitStr != "" {
+        if parsedLimit, err := strconv.Atoi(limitStr); err == nil && parsedLimit > 0 {
+            limit = parsedLimit
+        }
+    }
+    
+    status := jobs.Status(c.Query("status"))
+    result := make([]jobs.Job, 0)
+    for _, job := range s.jobs.List(ingestJobType, 0) {
+        if status != "" && job.Status != status {
+            continue
+        }
+        result = append(result, job)
+    }
+    
+    // total cuenta todos los jobs que cumplen el filtro, no solo la página
+    total := len(result)
+    if len(result) > limit {
+        result = result[:limit]
+    }
+    
+    c.JSON(http.StatusOK, gin.H{
+        "data": result,
+        "total": total,
+    })
+}
@@ -0,0 +1,325 @@
+package api
+
+import (
+    "errors"
+    "fmt"
+    "net/http"
+    "strconv"
+    "strings"
+    "time"
+
+    "admira-etl/internal/etl"
+    "admira-etl/internal/models"
+
+    "github.com/gin-gonic/gin"
+)
+
+// metricsQueryRequest es la consulta de /metrics/query. Por GET los filtros son
+// parámetros con el nombre de la dimensión (valores separados por comas); por
+// POST se envía como JSON.
+type metricsQueryRequest struct {
+    From    string              `json:"from"`
+    To      string              `json:"to"`
+    Filters map[string][]string `json:"filters"`
+    GroupBy []string            `json:"group_by"`
+    // Granularity agrupa la fecha en buckets (day, week, month, quarter)
+    Granularity string          `json:"granularity"`
+    WeekStart   string          `json:"week_start"`
+    Limit   int                 `json:"limit"`
+    Offset  int                 `json:"offset"`
+}
+
+func (s *Server) bindMetricsQuery(c *gin.Context) (*metricsQueryRequest, error) {
+    req := &metricsQueryRequest{Filters: make(map[string][]string)}
+    
+    if c.Request.Method == http.MethodPost {
+        if err := c.ShouldBindJSON(req); err != nil {
+            return nil, fmt.Errorf("invalid JSON body: %v", err)
+        }
+        return req, nil
+    }
+    
+    req.From = c.Query("from")
+    req.To = c.Query("to")
+    req.GroupBy = splitQueryList(c.Query("group_by"))
+    req.Granularity = c.Query("granularity")
+    req.WeekStart = c.Query("week_start")
+    for _, d := range etl.Dimensions() {
+        if values := splitQueryList(c.Query(string(d))); len(values) > 0 {
+            req.Filters[string(d)] = values
+        }
+    }
+    if limit, err := strconv.Atoi(c.Query("limit")); err == nil {
+        req.Limit = limit
+    }
+    if offset, err := strconv.Atoi(c.Query("offset")); err == nil {
+        req.Offset = offset
+    }
+    return req, nil
+}
+
+// toQuery valida la petición y la convierte en una etl.MetricsQuery
+func (s *Server) toQuery(req *metricsQueryRequest) (etl.MetricsQuery, error) {
+    query := etl.MetricsQuery{Filters: make(map[etl.Dimension][]string)}
+    
+    var err error
+    if req.From != "" {
+        if query.From, err = time.Parse("2006-01-02", req.From); err != nil {
+            return query, fmt.Errorf("invalid from date format. Use YYYY-MM-DD")
+        }
+    }
+    if req.To != "" {
+        if query.To, err = time.Parse("2006-01-02", req.To); err != nil {
+            return query, fmt.Errorf("invalid to date format. Use YYYY-MM-DD")
+        }
+    }
+    if !query.From.IsZero() && !query.To.IsZero() && query.To.Before(query.From) {
+        return query, fmt.Errorf("to must not be before from")
+    }
+    
+    for name, values := range req.Filters {
+        dimension, err := etl.ParseDimension(name)
+        if err != nil {
+            return query, err
+        }
+        // Los UTMs se normalizan igual que en la ingesta
+        switch dimension {
+        case etl.DimensionUTMCampaign, etl.DimensionUTMSource, etl.DimensionUTMMedium:
+            normalized := make([]string, len(values))
+            for i, v := range values {
+                normalized[i] = s.normalizeUTM(string(dimension), v)
+            }
+            values = normalized
+        }
+        query.Filters[dimension] = values
+    }
+    
+    if query.GroupBy, err = etl.ParseDimensions(req.GroupBy); err != nil {
+        return query, err
+    }
+    return query, nil
+}
+
+// queryMetrics agrega las métricas almacenadas con filtros y group-by arbitrarios
+func (s *Server) queryMetrics(c *gin.Context) {
+    req, err := s.bindMetricsQuery(c)
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+    
+    query, err := s.toQuery(req)
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+    
+    bucketOptions, err := s.bucketOptions(req.Granularity, req.WeekStart)
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+    
+    format, err := negotiateFormat(c)
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+    
+    groupBy := query.GroupBy
+    if groupBy == nil {
+        groupBy = []etl.Dimension{}
+    }
+    
+    metrics := s.storage.GetMetrics(query.Match)
+    
+    totals := models.Metrics{}
+    if all := etl.Aggregate(metrics, nil); len(all) > 0 {
+        totals = all[0]
+    }
+    
+    limit := 100
+    if req.Limit > 0 {
+        limit = req.Limit
+    } else if format != formatJSON {
+        // CSV y NDJSON devuelven todas las filas salvo que se pida un limit
+        limit = 0
+    }
+    offset := 0
+    if req.Offset > 0 {
+        offset = req.Offset
+    }
+    
+    if bucketOptions != nil {
+        series, err := etl.TimeSeries(metrics, query.GroupBy, query.From, query.To, *bucketOptions)
+        if err != nil {
+            c.JSON(seriesStatus(err), gin.H{"error": err.Error()})
+            return
+        }
+        if format != formatJSON {
+            start, end := window(len(series), offset, limit)
+            streamSeries(c, format, series[start:end])
+            return
+        }
+        s.paginatedSeries(c, series, bucketOptions, limit, offset, gin.H{
+            "group_by": groupBy,
+            "totals": totals,
+            "rows_scanned": len(metrics),
+        })
+        return
+    }
+    
+    rows := etl.Aggregate(metrics, query.GroupBy)
+    if format != formatJSON {
+        start, end := window(len(rows), offset, limit)
+        streamMetrics(c, format, rows[start:end])
+        return
+    }
+    if offset > len(rows) {
+        offset = len(rows)
+    }
+    end := offset + limit
+    if end > len(rows) {
+        end = len(rows)
+    }
+    
+    c.JSON(http.StatusOK, gin.H{
+        "data": rows[offset:end],
+        "group_by": groupBy,
+        "totals": totals,
+        "rows_scanned": len(metrics),
+        "pagination": gin.H{
+            "total": len(rows),
+            "limit": limit,
+            "offset": offset,
+            "has_more": end < len(rows),
+        },
+    })
+}
+
+// comparisonPeriod son los totales de uno de los dos rangos comparados
+type comparisonPeriod struct {
+    From   string         `json:"from"`
+    To     string         `json:"to"`
+    Totals models.Metrics `json:"totals"`
+    Funnel *etl.Funnel    `json:"funnel,omitempty"`
+}
+
+// periodComparison compara el rango consultado con el periodo anterior o el
+// mismo rango del año anterior
+type periodComparison struct {
+    Mode     etl.CompareMode      `json:"mode"`
+    Current  comparisonPeriod     `json:"current"`
+    Previous comparisonPeriod     `json:"previous"`
+    Deltas   map[string]etl.Delta `json:"deltas"`
+}
+
+// parseCompareMode valida el parámetro compare; vacío desactiva la comparación
+func parseCompareMode(value string) (etl.CompareMode, error) {
+    if value == "" {
+        return "", nil
+    }
+    return etl.ParseCompareMode(value)
+}
+
+// compare agrega las métricas actuales y las del rango de comparación, que se
+// leen del storage con fetch
+func (s *Server) compare(mode etl.CompareMode, from, to time.Time, current []models.Metrics, fetch func(from, to time.Time) []models.Metrics) *periodComparison {
+    prevFrom, prevTo := etl.ComparisonRange(from, to, mode)
+    
+    total := func(metrics []models.Metrics) models.Metrics {
+        if all := etl.Aggregate(metrics, nil); len(all) > 0 {
+            return all[0]
+        }
+        return models.Metrics{}
+    }
+    currentTotals := total(current)
+    previousTotals := total(fetch(prevFrom, prevTo))
+    
+    return &periodComparison{
+        Mode: mode,
+        Current: comparisonPeriod{
+            From:   from.Format("2006-01-02"),
+            To:     to.Format("2006-01-02"),
+            Totals: currentTotals,
+        },
+        Previous: comparisonPeriod{
+            From:   prevFrom.Format("2006-01-02"),
+            To:     prevTo.Format("2006-01-02"),
+            Totals: previousTotals,
+        },
+        Deltas: etl.CompareMetrics(currentTotals, previousTotals),
+    }
+}
+
+// bucketOptions construye las opciones de agrupado temporal; devuelve nil si
+// no se pidió granularidad
+func (s *Server) bucketOptions(granularity, weekStart string) (*etl.BucketOptions, error) {
+    if granularity == "" {
+        return nil, nil
+    }
+    
+    g, err := etl.ParseGranularity(granularity)
+    if err != nil {
+        return nil, err
+    }
+    options := &etl.BucketOptions{Granularity: g, WeekStart: s.weekStart}
+    if weekStart != "" {
+        if options.WeekStart, err = etl.ParseWeekday(weekStart); err != nil {
+            return nil, err
+        }
+    }
+    return options, nil
+}
+
+// seriesStatus devuelve el código HTTP de un error de etl.TimeSeries: un rango
+// con demasiados buckets es un error del cliente
+func seriesStatus(err error) int {
+    if errors.Is(err, etl.ErrTooManyBuckets) {
+        return http.StatusBadRequest
+    }
+    return http.StatusInternalServerError
+}
+
+// paginatedSeries responde con una página de una serie temporal
+func (s *Server) paginatedSeries(c *gin.Context, series []etl.SeriesPoint, options *etl.BucketOptions, limit, offset int, extra ...gin.H) {
+    if series == nil {
+        series = []etl.SeriesPoint{}
+    }
+    if offset > len(series) {
+        offset = len(series)
+    }
+    end := offset + limit
+    if end > len(series) {
+        end = len(series)
+    }
+    
+    response := gin.H{
+        "data": series[offset:end],
+        "granularity": options.Granularity,
+        "week_start": strings.ToLower(options.WeekStart.String()),
+        "pagination": gin.H{
+            "total": len(series),
+            "limit": limit,
+            "offset": offset,
+            "has_more": end < len(series),
+        },
+    }
+    for _, fields := range extra {
+        for k, v := range fields {
+            response[k] = v
+        }
+    }
+    c.JSON(http.StatusOK, response)
+}
+
+// splitQueryList separa un parámetro con valores separados por comas
+func splitQueryList(value string) []string {
+    var result []string
+    for _, item := range strings.Split(value, ",") {
+        if item = strings.TrimSpace(item); item != "" {
+            result = append(result, item)
+        }
+    }
+    return result
+}
@@ -0,0 +1,130 @@
+package api
+
+import (
+    "net/http"
+    "strconv"
+
+    "admira-etl/internal/storage"
+
+    "github.com/gin-gonic/gin"
+)
+
+// listOutbox lista las entregas del outbox sin el detalle de métricas
+func (s *Server) listOutbox(c *gin.Context) {
+    s.respondOutbox(c, storage.OutboxFilter{
+        Status:  storage.OutboxStatus(c.Query("status")),
+        Sink:    c.Query("sink"),
+        Date:    c.Query("date"),
+        BatchID: c.Query("batch_id"),
+    })
+}
+
+// listDeadLetters lista los lotes que agotaron sus intentos
+func (s *Server) listDeadLetters(c *gin.Context) {
+    s.respondOutbox(c, storage.OutboxFilter{
+        Status:  storage.OutboxDead,
+        Sink:    c.Query("sink"),
+        Date:    c.Query("date"),
+        BatchID: c.Query("batch_id"),
+    })
+}
+
+func (s *Server) respondOutbox(c *gin.Context, filter storage.OutboxFilter) {
+    limit := 100
+    if limitStr := c.Query("limit"); limitStr != "" {
+        if parsedLimit, err := strconv.Atoi(limitStr); err == nil && parsedLimit > 0 {
+            limit = parsedLimit
+        }
+    }
+    
+    entries := s.exports.Outbox().List(filter)
+    total := len(entries)
+    if len(entries) > limit {
+        entries = entries[:limit]
+    }
+    for i := range entries {
+        entries[i].Metrics = nil
+    }
+    
+    c.JSON(http.StatusOK, gin.H{
+        "data": entries,
+        "total": total,
+    })
+}
+
+func (s *Server) getOutboxEntry(c *gin.Context) {
+    entry, ok := s.exports.Outbox().Get(c.Param("id"))
+    if !ok {
+        c.JSON(http.StatusNotFound, gin.H{"error": "Outbox entry not found"})
+        return
+    }
+    c.JSON(http.StatusOK, entry)
+}
+
+// replayDeadLetter vuelve a encolar un lote de la dead-letter list
+func (s *Server) replayDeadLetter(c *gin.Context) {
+    id := c.Param("id")
+    if _, ok := s.exports.Outbox().Get(id); !ok {
+        c.JSON(http.StatusNotFound, gin.H{"error": "Outbox entry not found"})
+        return
+    }
+    
+    entry, err := s.exports.Replay(id)
+    if err != nil {
+        c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+        return
+    }
+    entry.Metrics = nil
+    
+    c.JSON(http.StatusAccepted, gin.H{
+        "message": "Dead letter requeued for delivery",
+        "entry": entry,
+    })
+}
+
+// replayDeadLetters vuelve a encolar todos los lotes de la dead-letter list
+func (s *Server) replayDeadLetters(c *gin.Context) {
+    dead := s.exports.Outbox().List(storage.OutboxFilter{
+        Status:  storage.OutboxDead,
+        Sink:    c.Query("sink"),
+        Date:    c.Query("date"),
+        BatchID: c.Query("batch_id"),
+    })
+    
+    replayed := make([]string, 0, len(dead))
+    failed := make(map[string]string)
+    for _, entry := range dead {
+        if _, err := s.exports.Replay(entry.ID); err != nil {
+            failed[entry.ID] = err.Error()
+            continue
+        }
+        replayed = append(replayed, entry.ID)
+    }
+    
+    c.JSON(http.StatusAccepted, gin.H{
+        "replayed": replayed,
+        "failed": failed,
+    })
+}
+
+// removeDeadLetter borra un lote de la dead-letter list; si no, se conserva
+// hasta que se reintenta
+func (s *Server) removeDeadLetter(c *gin.Context) {
+    id := c.Param("id")
+    if _, ok := s.exports.Outbox().Get(id); !ok {
+        c.JSON(http.StatusNotFound, gin.H{"error": "Outbox entry not found"})
+        return
+    }
+    
+    entry, err := s.exports.Outbox().Remove(id)
+    if err != nil {
+        c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+        return
+    }
+    entry.Metrics = nil
+    
+    c.JSON(http.StatusOK, gin.H{
+        "message": "Dead letter removed",
+        "entry": entry,
+    })
+}
@@ -0,0 +1,203 @@
+package api
+
+import (
+    "fmt"
+    "net/http"
+    "strconv"
+    "time"
+
+    "admira-etl/internal/etl"
+    "admira-etl/internal/jobs"
+    "admira-etl/internal/storage"
+
+    "github.com/gin-gonic/gin"
+)
+
+func (s *Server) listQuarantine(c *gin.Context) {
+    limit := 100
+    if limitStr := c.Query("limit"); limitStr != "" {
+        if parsedLimit, err := strconv.Atoi(limitStr); err == nil && parsedLimit > 0 {
+            limit = parsedLimit
+        }
+    }
+    
+    records := s.quarantine.List(storage.QuarantineFilter{
+        Status: storage.QuarantineStatus(c.Query("status")),
+        Source: c.Query("source"),
+        Kind:   c.Query("kind"),
+    })
+    
+    total := len(records)
+    if len(records) > limit {
+        records = records[:limit]
+    }
+    
+    c.JSON(http.StatusOK, gin.H{
+        "data": records,
+        "total": total,
+    })
+}
+
+func (s *Server) getQuarantinedRecord(c *gin.Context) {
+    record, ok := s.quarantine.Get(c.Param("id"))
+    if !ok {
+        c.JSON(http.StatusNotFound, gin.H{"error": "Quarantined record not found"})
+        return
+    }
+    c.JSON(http.StatusOK, record)
+}
+
+// reprocessQuarantinedRecord vuelve a validar un registro con las reglas de
+// parsing actuales. Si ahora es válido se guarda con los registros de su origen
+// y se recalculan las métricas de su ventana; solo entonces se marca como
+// resuelto.
+func (s *Server) reprocessQuarantinedRecord(c *gin.Context) {
+    record, ok := s.quarantine.Get(c.Param("id"))
+    if !ok {
+        c.JSON(http.StatusNotFound, gin.H{"error": "Quarantined record not found"})
+        return
+    }
+    
+    decoded, err := etl.DecodeRecord(record.Kind, record.Raw)
+    if err != nil {
+        s.quarantine.UpdateReason(record.ID, err.Error())
+        c.JSON(http.StatusUnprocessableEntity, gin.H{
+            "error": "Record is still invalid",
+            "reason": err.Error(),
+            "id": record.ID,
+        })
+        return
+    }
+    
+    window, err := s.loadReprocessed(map[string]*etl.Records{record.Source: decoded})
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        return
+    }
+    
+    resolved, err := s.quarantine.Resolve(record.ID)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        return
+    }
+    
+    response := gin.H{
+        "record": resolved,
+        "parsed": decoded,
+    }
+    addRecomputed(response, window)
+    c.JSON(http.StatusOK, response)
+}
+
+// reprocessQuarantine reprocesa todos los registros pendientes de cuarentena.
+// Los válidos se cargan juntos y se marcan como resueltos después.
+func (s *Server) reprocessQuarantine(c *gin.Context) {
+    pending := s.quarantine.List(storage.QuarantineFilter{
+        Status: storage.QuarantineOpen,
+        Source: c.Query("source"),
+    })
+    
+    var valid []string
+    failed := make(map[string]string)
+    bySource := make(map[string]*etl.Records)
+    for _, record := range pending {
+        decoded, err := etl.DecodeRecord(record.Kind, record.Raw)
+        if err != nil {
+            s.quarantine.UpdateReason(record.ID, err.Error())
+            failed[record.ID] = err.Error()
+            continue
+        }
+        records, ok := bySource[record.Source]
+        if !ok {
+            records = &etl.Records{}
+            bySource[record.Source] = records
+        }
+        records.Ads = append(records.Ads, decoded.Ads...)
+        records.CRM = append(records.CRM, decoded.CRM...)
+        valid = append(valid, record.ID)
+    }
+    
+    var window *metricsWindow
+    if len(valid) > 0 {
+        var err error
+        if window, err = s.loadReprocessed(bySource); err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            return
+        }
+    }
+    
+    resolved := make([]string, 0, len(valid))
+    for _, id := range valid {
+        if _, err := s.quarantine.Resolve(id); err != nil {
+            failed[id] = err.Error()
+            continue
+        }
+        resolved = append(resolved, id)
+    }
+    
+    response := gin.H{
+        "processed": len(pending),
+        "resolved": resolved,
+        "failed": failed,
+    }
+    addRecomputed(response, window)
+    c.JSON(http.StatusOK, response)
+}
+
+// loadReprocessed guarda los registros reprocesados con los de su origen y
+// recalcula las métricas afectadas, igual que la carga de una ingesta
+func (s *Server) loadReprocessed(bySource map[string]*etl.Records) (*metricsWindow, error) {
+    options := s.etl.Options()
+    acc, err := s.etl.NewAccumulator(options)
+    if err != nil {
+        return nil, fmt.Errorf("failed to transform data: %v", err)
+    }
+    
+    s.ingestMu.Lock()
+    defer s.ingestMu.Unlock()
+    
+    for source, records := range bySource {
+        if err := s.records.Add(source, records.Ads, records.CRM); err != nil {
+            return nil, fmt.Errorf("failed to store records of source %s: %v", source, err)
+        }
+    }
+    window, err := s.transformWindow(acc, options.Attribution, time.Time{}, false)
+    if err != nil {
+        return nil, fmt.Errorf("failed to transform data: %v", err)
+    }
+    if err := s.loadWindow(window); err != nil {
+        return nil, err
+    }
+    return window, nil
+}
+
+// addRecomputed añade a la respuesta la ventana de métricas recalculada
+func addRecomputed(response gin.H, window *metricsWindow) {
+    if window == nil {
+        return
+    }
+    response["recomputed_from"] = window.From.Format("2006-01-02")
+    response["metrics_processed"] = len(window.Metrics)
+}
+
+// quarantineRejected guarda en cuarentena los registros rechazados en la extracción
+func (s *Server) quarantineRejected(rejected []etl.RejectedRecord) int {
+    stored := 0
+    for _, r := range rejected {
+        if _, err := s.quarantine.Add(r.Source, r.Kind, r.Raw, r.Reason); err != nil {
+            continue
+        }
+        stored++
+    }
+    return stored
+}
+
+// submitReingest encola una ingesta con las opciones por defecto. incremental
+// indica qué marcas se envían a los orígenes incrementales.
+func (s *Server) submitReingest(incremental string) (jobs.Job, error) {
+    return s.submitIngest(ingestParams{
+        Since:       time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC),
+        Attribution: s.etl.Options().Attribution.Model,
+        Incremental: incremental,
+    })
+}
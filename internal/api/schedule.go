@@ -0,0 +1,130 @@
+package api
+
+import (
+    "context"
+    "fmt"
+    "net/http"
+    "path/filepath"
+    "strconv"
+    "time"
+
+    "admira-etl/internal/jobs"
+    "admira-etl/internal/scheduler"
+    "admira-etl/internal/storage"
+
+    "github.com/gin-gonic/gin"
+)
+
+// newScheduler registra las ejecuciones recurrentes configuradas (ingesta y export)
+func (s *Server) newScheduler() (*scheduler.Scheduler, error) {
+    location, err := time.LoadLocation(s.cfg.ScheduleTimezone)
+    if err != nil {
+        return nil, fmt.Errorf("invalid schedule timezone: %v", err)
+    }
+    
+    var journal *storage.Journal
+    if s.cfg.StorageBackend == "file" {
+        journal, err = storage.OpenJournal(filepath.Join(s.cfg.DataDir, "schedule_runs.jsonl"))
+        if err != nil {
+            return nil, err
+        }
+    }
+    
+    sched, err := scheduler.New(location, journal)
+    if err != nil {
+        if journal != nil {
+            journal.Close()
+        }
+        return nil, err
+    }
+    
+    // Stop cierra también el journal
+    missed := scheduler.MissedPolicy(s.cfg.ScheduleMissedPolicy)
+    if s.cfg.ScheduleIngest != "" {
+        if err := sched.Add("ingest", s.cfg.ScheduleIngest, missed, s.scheduledIngest); err != nil {
+            sched.Stop()
+            return nil, err
+        }
+    }
+    if s.cfg.ScheduleExport != "" {
+        if err := sched.Add("export", s.cfg.ScheduleExport, missed, s.scheduledExport); err != nil {
+            sched.Stop()
+            return nil, err
+        }
+    }
+    return sched, nil
+}
+
+// scheduledIngest encola un job de ingesta y espera a que termine
+func (s *Server) scheduledIngest(ctx context.Context, scheduledFor time.Time) (map[string]interface{}, error) {
+    job, err := s.submitReingest(incrementalWatermark)
+    if err != nil {
+        return nil, err
+    }
+    
+    job, err = s.jobs.Wait(ctx, job.ID)
+    if err != nil {
+        return nil, err
+    }
+    
+    details := map[string]interface{}{
+        "job_id": job.ID,
+        "counts": job.Counts,
+    }
+    if job.Status == jobs.StatusFailed {
+        return details, fmt.Errorf("ingest job %s failed: %s", job.ID, job.Error)
+    }
+    return details, nil
+}
+
+// scheduledExport exporta el día anterior al instante programado
+func (s *Server) scheduledExport(ctx context.Context, scheduledFor time.Time) (map[string]interface{}, error) {
+    day := scheduledFor.AddDate(0, 0, -1)
+    date := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+    
+    result, err := s.executeExport(ctx, date, date, s.exportGroupBy)
+    if result == nil {
+        return map[string]interface{}{"date": date.Format("2006-01-02")}, err
+    }
+    
+    return map[string]interface{}{
+        "date": result.From,
+        "batch_id": result.BatchID,
+        "total_chunks": result.Chunks,
+        "total_records": result.Records,
+        "group_by": result.GroupBy,
+        "delivered": result.Delivered,
+        "sinks": result.Sinks,
+    }, err
+}
+
+func (s *Server) getSchedule(c *gin.Context) {
+    c.JSON(http.StatusOK, gin.H{
+        "timezone": s.cfg.ScheduleTimezone,
+        "entries": s.scheduler.Entries(),
+    })
+}
+
+func (s *Server) listScheduleRuns(c *gin.Context) {
+    limit := 50
+    if limitStr := c.Query("limit"); limitStr != "" {
+        if parsedLimit, err := strconv.Atoi(limitStr); err == nil && parsedLimit > 0 {
+            limit = parsedLimit
+        }
+    }
+    
+    runs := s.scheduler.Runs(c.Query("entry"), limit)
+    c.JSON(http.StatusOK, gin.H{
+        "data": runs,
+        "total": len(runs),
+    })
+}
+
+func (s *Server) getScheduleRun(c *gin.Context) {
+    run, ok := s.scheduler.Run(c.Param("id"))
+    if !ok {
+        c.JSON(http.StatusNotFound, gin.H{"error": "Run not found"})
+        return
+    }
+    c.JSON(http.StatusOK, run)
+}
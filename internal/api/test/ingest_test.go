@@ -0,0 +1,259 @@
+package test
+
+import (
+    "encoding/json"
+    "fmt"
+    "net/http"
+    "net/http/httptest"
+    "sort"
+    "strings"
+    "sync"
+    "testing"
+    "time"
+    "admira-etl/internal/api"
+    "admira-etl/internal/etl"
+    "admira-etl/internal/jobs"
+    "admira-etl/internal/models"
+    "admira-etl/internal/storage"
+    "admira-etl/pkg/config"
+)
+
+type adsRow struct {
+    Date        string  `json:"date"`
+    CampaignID  string  `json:"campaign_id"`
+    Channel     string  `json:"channel"`
+    Clicks      int     `json:"clicks"`
+    Cost        float64 `json:"cost"`
+    UTMCampaign string  `json:"utm_campaign"`
+    UTMSource   string  `json:"utm_source"`
+    UTMMedium   string  `json:"utm_medium"`
+}
+
+type crmRow struct {
+    OpportunityID string  `json:"opportunity_id"`
+    Stage         string  `json:"stage"`
+    Amount        float64 `json:"amount"`
+    CreatedAt     string  `json:"created_at"`
+    UTMCampaign   string  `json:"utm_campaign"`
+    UTMSource     string  `json:"utm_source"`
+    UTMMedium     string  `json:"utm_medium"`
+}
+
+// dataset es el contenido del upstream, que el test amplía entre ingestas
+type dataset struct {
+    mu  sync.Mutex
+    ads []adsRow
+    crm []crmRow
+}
+
+func (d *dataset) add(ads []adsRow, crm []crmRow) {
+    d.mu.Lock()
+    defer d.mu.Unlock()
+    d.ads = append(d.ads, ads...)
+    d.crm = append(d.crm, crm...)
+}
+
+// upstream sirve /ads y /crm filtrando por el parámetro since como un API
+// incremental: filas de Ads con date >= since y CRM creados desde since
+func upstream(t *testing.T, data *dataset) *httptest.Server {
+    server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        since := r.URL.Query().Get("since")
+        data.mu.Lock()
+        defer data.mu.Unlock()
+
+        var body interface{}
+        switch r.URL.Path {
+        case "/ads":
+            rows := make([]adsRow, 0)
+            for _, row := range data.ads {
+                if row.Date >= since {
+                    rows = append(rows, row)
+                }
+            }
+            body = map[string]interface{}{"external": map[string]interface{}{"ads": map[string]interface{}{"performance": rows}}}
+        case "/crm":
+            rows := make([]crmRow, 0)
+            for _, row := range data.crm {
+                if row.CreatedAt[:10] >= since {
+                    rows = append(rows, row)
+                }
+            }
+            body = map[string]interface{}{"external": map[string]interface{}{"crm": map[string]interface{}{"opportunities": rows}}}
+        default:
+            http.NotFound(w, r)
+            return
+        }
+        json.NewEncoder(w).Encode(body)
+    }))
+    t.Cleanup(server.Close)
+    return server
+}
+
+func newServer(t *testing.T, upstreamURL string) (*api.Server, storage.Storage) {
+    incremental := &config.IncrementalConfig{Type: etl.IncrementalDate, Param: "since"}
+    cfg := &config.Config{
+        Timeout:                 time.Second,
+        MaxRetries:              1,
+        ExtractConcurrency:      2,
+        BreakerFailureThreshold: 5,
+        StorageBackend:          "memory",
+        IngestWorkers:           1,
+        IngestQueueSize:         4,
+        ScheduleTimezone:        "UTC",
+        AttributionModel:        "linear",
+        AttributionLookbackDays: 7,
+        WeekStart:               "monday",
+        Sources: []config.SourceConfig{
+            {Name: "ads", Format: "ads", URL: upstreamURL + "/ads", Incremental: incremental},
+            {Name: "crm", Format: "crm", URL: upstreamURL + "/crm", Incremental: incremental},
+        },
+    }
+    store := storage.NewMemoryStorage()
+    server, err := api.NewServer(cfg, store)
+    if err != nil {
+        t.Fatalf("NewServer failed: %v", err)
+    }
+    t.Cleanup(server.Close)
+    return server, store
+}
+
+// request hace una petición al router y decodifica la respuesta JSON
+func request(t *testing.T, server *api.Server, method, path string, out interface{}) int {
+    recorder := httptest.NewRecorder()
+    server.Handler().ServeHTTP(recorder, httptest.NewRequest(method, path, nil))
+    if out != nil {
+        if err := json.Unmarshal(recorder.Body.Bytes(), out); err != nil {
+            t.Fatalf("%s %s returned invalid JSON: %v", method, path, err)
+        }
+    }
+    return recorder.Code
+}
+
+// runIngest lanza una ingesta y espera a que termine bien
+func runIngest(t *testing.T, server *api.Server, query string) jobs.Job {
+    var accepted struct {
+        JobID string `json:"job_id"`
+    }
+    if code := request(t, server, http.MethodPost, "/ingest/run"+query, &accepted); code != http.StatusAccepted {
+        t.Fatalf("Expected 202 from /ingest/run, got %d", code)
+    }
+
+    deadline := time.Now().Add(5 * time.Second)
+    for time.Now().Before(deadline) {
+        var job jobs.Job
+        request(t, server, http.MethodGet, "/ingest/jobs/"+accepted.JobID, &job)
+        switch job.Status {
+        case jobs.StatusSucceeded:
+            return job
+        case jobs.StatusFailed:
+            t.Fatalf("Ingest job failed: %+v", job)
+        }
+        time.Sleep(10 * time.Millisecond)
+    }
+    t.Fatalf("Ingest job %s did not finish", accepted.JobID)
+    return jobs.Job{}
+}
+
+// snapshot devuelve las métricas guardadas en un orden estable
+func snapshot(store storage.Storage) []models.Metrics {
+    metrics := store.GetMetrics(func(models.Metrics) bool { return true })
+    sort.Slice(metrics, func(i, j int) bool {
+        return fmt.Sprint(etl.MetricKeyOf(metrics[i])) < fmt.Sprint(etl.MetricKeyOf(metrics[j]))
+    })
+    return metrics
+}
+
+func TestIngest_IncrementalRunsMatchFullRun(t *testing.T) {
+    first := []adsRow{
+        {Date: "2025-08-01", CampaignID: "C-1", Channel: "google_ads", Clicks: 10, Cost: 5, UTMCampaign: "bts", UTMSource: "google", UTMMedium: "cpc"},
+        {Date: "2025-08-05", CampaignID: "C-1", Channel: "google_ads", Clicks: 20, Cost: 8, UTMCampaign: "bts", UTMSource: "google", UTMMedium: "cpc"},
+        {Date: "2025-08-10", CampaignID: "C-2", Channel: "meta_ads", Clicks: 15, Cost: 6, UTMCampaign: "promo", UTMSource: "facebook", UTMMedium: "paid_social"},
+    }
+    firstCRM := []crmRow{
+        {OpportunityID: "O-1", Stage: "lead", CreatedAt: "2025-08-03T10:00:00Z", UTMCampaign: "bts", UTMSource: "google", UTMMedium: "cpc"},
+        {OpportunityID: "O-2", Stage: "closed_won", Amount: 100, CreatedAt: "2025-08-06T10:00:00Z", UTMCampaign: "bts", UTMSource: "google", UTMMedium: "cpc"},
+    }
+    second := []adsRow{
+        {Date: "2025-08-12", CampaignID: "C-1", Channel: "google_ads", Clicks: 30, Cost: 12, UTMCampaign: "bts", UTMSource: "google", UTMMedium: "cpc"},
+        {Date: "2025-08-14", CampaignID: "C-2", Channel: "meta_ads", Clicks: 5, Cost: 2, UTMCampaign: "promo", UTMSource: "facebook", UTMMedium: "paid_social"},
+    }
+    // O-3 llega después de la marca de CRM pero sus Ads (2025-08-05) son
+    // anteriores a la marca de Ads: solo los registros guardados la atribuyen
+    secondCRM := []crmRow{
+        {OpportunityID: "O-3", Stage: "opportunity", CreatedAt: "2025-08-08T10:00:00Z", UTMCampaign: "bts", UTMSource: "google", UTMMedium: "cpc"},
+        {OpportunityID: "O-4", Stage: "closed_won", Amount: 50, CreatedAt: "2025-08-13T10:00:00Z", UTMCampaign: "bts", UTMSource: "google", UTMMedium: "cpc"},
+        {OpportunityID: "O-5", Stage: "lead", CreatedAt: "2025-08-14T10:00:00Z", UTMCampaign: "promo", UTMSource: "facebook", UTMMedium: "paid_social"},
+    }
+
+    incrementalData := &dataset{}
+    incrementalData.add(first, firstCRM)
+    incrementalServer, incrementalStore := newServer(t, upstream(t, incrementalData).URL)
+    runIngest(t, incrementalServer, "")
+    incrementalData.add(second, secondCRM)
+    job := runIngest(t, incrementalServer, "")
+
+    var sources []etl.SourceReport
+    encoded, _ := json.Marshal(job.Details["sources"])
+    json.Unmarshal(encoded, &sources)
+    for _, report := range sources {
+        if report.Watermark == "" {
+            t.Fatalf("Expected the second run to be incremental, got %+v", report)
+        }
+    }
+
+    fullData := &dataset{}
+    fullData.add(append(first, second...), append(firstCRM, secondCRM...))
+    fullServer, fullStore := newServer(t, upstream(t, fullData).URL)
+    runIngest(t, fullServer, "")
+
+    got, want := snapshot(incrementalStore), snapshot(fullStore)
+    if len(got) != len(want) {
+        t.Fatalf("Expected %d metric rows, got %d:\n%+v\n%+v", len(want), len(got), want, got)
+    }
+    for i := range want {
+        if got[i] != want[i] {
+            t.Errorf("Row %d differs:\nincremental %+v\nfull        %+v", i, got[i], want[i])
+        }
+    }
+    for _, metric := range got {
+        if metric.CampaignID == "" && strings.HasPrefix(metric.UTMCampaign, "bts") {
+            t.Errorf("Expected every bts record to be attributed to Ads, got inferred row %+v", metric)
+        }
+    }
+}
+
+func TestIngest_AttributionChangeRecomputesStoredMetrics(t *testing.T) {
+    data := &dataset{}
+    data.add(
+        []adsRow{
+            {Date: "2025-08-04", CampaignID: "C-1", Channel: "google_ads", Clicks: 10, Cost: 5, UTMCampaign: "bts", UTMSource: "google", UTMMedium: "cpc"},
+            {Date: "2025-08-05", CampaignID: "C-1", Channel: "google_ads", Clicks: 20, Cost: 8, UTMCampaign: "bts", UTMSource: "google", UTMMedium: "cpc"},
+        },
+        []crmRow{{OpportunityID: "O-1", Stage: "closed_won", Amount: 100, CreatedAt: "2025-08-06T10:00:00Z", UTMCampaign: "bts", UTMSource: "google", UTMMedium: "cpc"}},
+    )
+    server, store := newServer(t, upstream(t, data).URL)
+
+    // Sin cambios en el upstream, cada ingesta con otra atribución recalcula todo
+    for _, run := range []struct {
+        query string
+        want  []float64
+    }{
+        {"", []float64{0.5, 0.5}},
+        {"?attribution=last_touch", []float64{0, 1}},
+        {"", []float64{0.5, 0.5}},
+    } {
+        job := runIngest(t, server, run.query)
+        if job.Counts["metrics_processed"] != 2 {
+            t.Errorf("Expected %q to recompute both rows, got %+v", run.query, job.Counts)
+        }
+        rows := snapshot(store)
+        if len(rows) != 2 || float64(rows[0].ClosedWon) != run.want[0] || float64(rows[1].ClosedWon) != run.want[1] {
+            t.Errorf("Expected closed_won %v after %q, got %+v", run.want, run.query, rows)
+        }
+    }
+
+    // Con la misma atribución y nada nuevo no hay nada que recalcular
+    if job := runIngest(t, server, ""); job.Counts["metrics_processed"] != 0 {
+        t.Errorf("Expected nothing to recompute, got %+v", job.Counts)
+    }
+}
@@ -0,0 +1,112 @@
+package test
+
+import (
+    "net/http"
+    "testing"
+    "time"
+    "admira-etl/internal/api"
+    "admira-etl/internal/models"
+    "admira-etl/internal/storage"
+)
+
+// quarantineData tiene una fila de Ads, un lead válido y un closed_won con una
+// fecha que solo se entiende con CRM_DATE_FORMATS
+func quarantineData(extra ...crmRow) *dataset {
+    data := &dataset{}
+    data.add(
+        []adsRow{{Date: "2025-08-05", CampaignID: "C-1", Channel: "google_ads", Clicks: 20, Cost: 8, UTMCampaign: "bts", UTMSource: "google", UTMMedium: "cpc"}},
+        append([]crmRow{
+            {OpportunityID: "O-1", Stage: "lead", CreatedAt: "2025-08-05T09:00:00Z", UTMCampaign: "bts", UTMSource: "google", UTMMedium: "cpc"},
+            {OpportunityID: "O-2", Stage: "closed_won", Amount: 100, CreatedAt: "06.08.2025 10:00", UTMCampaign: "bts", UTMSource: "google", UTMMedium: "cpc"},
+        }, extra...),
+    )
+    return data
+}
+
+// acceptDotDates simula que se añade el formato de O-2 a CRM_DATE_FORMATS
+func acceptDotDates(t *testing.T) {
+    models.SetExtraDateFormats([]string{"02.01.2006 15:04"})
+    t.Cleanup(func() { models.SetExtraDateFormats(nil) })
+}
+
+func revenue(store storage.Storage) float64 {
+    total := 0.0
+    for _, metric := range store.GetMetrics(func(models.Metrics) bool { return true }) {
+        total += metric.Revenue
+    }
+    return total
+}
+
+func quarantined(t *testing.T, server *api.Server, status string) []storage.QuarantinedRecord {
+    var response struct {
+        Data []storage.QuarantinedRecord `json:"data"`
+    }
+    request(t, server, http.MethodGet, "/quarantine?status="+status, &response)
+    return response.Data
+}
+
+func TestReprocessQuarantinedRecord_LoadsRecordBeforeResolving(t *testing.T) {
+    server, store := newServer(t, upstream(t, quarantineData()).URL)
+    job := runIngest(t, server, "")
+    if job.Counts["quarantined_records"] != 1 || revenue(store) != 0 {
+        t.Fatalf("Expected O-2 in quarantine and no revenue, got %+v (revenue %.2f)", job.Counts, revenue(store))
+    }
+    id := quarantined(t, server, "quarantined")[0].ID
+
+    // Sin el formato sigue siendo inválido y no se resuelve
+    var invalid map[string]interface{}
+    if code := request(t, server, http.MethodPost, "/quarantine/"+id+"/reprocess", &invalid); code != http.StatusUnprocessableEntity {
+        t.Fatalf("Expected 422 for a still invalid record, got %d: %v", code, invalid)
+    }
+    if open := quarantined(t, server, "quarantined"); len(open) != 1 {
+        t.Errorf("Expected the record to stay quarantined, got %+v", open)
+    }
+
+    acceptDotDates(t)
+    var response struct {
+        Record         storage.QuarantinedRecord `json:"record"`
+        RecomputedFrom string                    `json:"recomputed_from"`
+    }
+    if code := request(t, server, http.MethodPost, "/quarantine/"+id+"/reprocess", &response); code != http.StatusOK {
+        t.Fatalf("Expected 200 reprocessing a valid record, got %d", code)
+    }
+    if response.Record.Status != storage.QuarantineResolved || response.RecomputedFrom == "" {
+        t.Errorf("Expected a resolved record and a recomputed window, got %+v", response)
+    }
+    // La venta ya está atribuida a su fila de Ads cuando se marca como resuelta
+    date, _ := time.Parse("2006-01-02", "2025-08-05")
+    rows := store.GetMetricsByDate(date)
+    if len(rows) != 1 || rows[0].ClosedWon != 1 || rows[0].Revenue != 100 || rows[0].Leads != 1 {
+        t.Errorf("Expected O-1 and O-2 attributed to the Ads row, got %+v", rows)
+    }
+}
+
+func TestReprocessQuarantine_ResolvesOnlyLoadedRecords(t *testing.T) {
+    broken := crmRow{OpportunityID: "O-3", Stage: "lead", CreatedAt: "not a date", UTMCampaign: "bts"}
+    server, store := newServer(t, upstream(t, quarantineData(broken)).URL)
+    if job := runIngest(t, server, ""); job.Counts["quarantined_records"] != 2 {
+        t.Fatalf("Expected 2 quarantined records, got %+v", job.Counts)
+    }
+
+    acceptDotDates(t)
+    var response struct {
+        Processed      int               `json:"processed"`
+        Resolved       []string          `json:"resolved"`
+        Failed         map[string]string `json:"failed"`
+        RecomputedFrom string            `json:"recomputed_from"`
+    }
+    if code := request(t, server, http.MethodPost, "/quarantine/reprocess", &response); code != http.StatusOK {
+        t.Fatalf("Expected 200 from /quarantine/reprocess, got %d", code)
+    }
+    if response.Processed != 2 || len(response.Resolved) != 1 || len(response.Failed) != 1 || response.RecomputedFrom == "" {
+        t.Fatalf("Expected one resolved and one failed record, got %+v", response)
+    }
+    if revenue(store) != 100 {
+        t.Errorf("Expected the reprocessed sale to be loaded, got revenue %.2f", revenue(store))
+    }
+
+    open := quarantined(t, server, "quarantined")
+    if len(open) != 1 || open[0].ID == response.Resolved[0] {
+        t.Errorf("Expected only O-3 to stay quarantined, got %+v", open)
+    }
+}
@@ -0,0 +1,93 @@
+package api
+
+import (
+    "fmt"
+    "net/http"
+
+    "admira-etl/internal/etl"
+    "admira-etl/internal/storage"
+    "admira-etl/pkg/config"
+
+    "github.com/gin-gonic/gin"
+)
+
+// listWatermarks lista la marca de cada origen incremental; los que aún no
+// tienen una aparecen con value vacío
+func (s *Server) listWatermarks(c *gin.Context) {
+    result := make([]storage.Watermark, 0)
+    for _, src := range s.extractor.Registry().Sources() {
+        incremental, ok := s.extractor.Incremental(src.Name())
+        if !ok {
+            continue
+        }
+        mark, exists := s.watermarks.Get(src.Name())
+        if !exists {
+            mark = storage.Watermark{Source: src.Name(), Kind: incremental.Type}
+        }
+        result = append(result, mark)
+    }
+    
+    c.JSON(http.StatusOK, gin.H{
+        "data": result,
+        "total": len(result),
+    })
+}
+
+// setWatermark fija a mano la marca de un origen, p. ej. a una fecha anterior
+// para un backfill. Body: {"value": "2025-07-01"}
+func (s *Server) setWatermark(c *gin.Context) {
+    name, incremental, ok := s.incrementalSource(c)
+    if !ok {
+        return
+    }
+    
+    var body struct {
+        Value string `json:"value"`
+    }
+    if err := c.ShouldBindJSON(&body); err != nil || body.Value == "" {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "A JSON body with a non-empty value is required"})
+        return
+    }
+    value, err := etl.ParseWatermark(incremental.Type, body.Value)
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+    
+    mark, err := s.watermarks.Set(name, incremental.Type, value)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to store watermark: %v", err)})
+        return
+    }
+    c.JSON(http.StatusOK, mark)
+}
+
+// resetWatermark vacía la marca de un origen: la siguiente ingesta lo descarga entero
+func (s *Server) resetWatermark(c *gin.Context) {
+    name, incremental, ok := s.incrementalSource(c)
+    if !ok {
+        return
+    }
+    
+    mark, err := s.watermarks.Set(name, incremental.Type, "")
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to reset watermark: %v", err)})
+        return
+    }
+    c.JSON(http.StatusOK, mark)
+}
+
+// incrementalSource valida que el origen de la ruta exista y sea incremental
+func (s *Server) incrementalSource(c *gin.Context) (string, config.IncrementalConfig, bool) {
+    name := c.Param("source")
+    if _, ok := s.extractor.Registry().Get(name); !ok {
+        c.JSON(http.StatusNotFound, gin.H{"error": "Source not found"})
+        return "", config.IncrementalConfig{}, false
+    }
+    incremental, ok := s.extractor.Incremental(name)
+    if !ok {
+        c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Source %s is not incremental", name)})
+        return "", config.IncrementalConfig{}, false
+    }
+    return name, incremental, true
+}
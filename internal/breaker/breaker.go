@@ -0,0 +1,178 @@
+package breaker
+
+import (
+    "errors"
+    "fmt"
+    "sync"
+    "time"
+)
+
+// State es el estado de un circuit breaker
+type State string
+
+const (
+    // StateClosed deja pasar todas las llamadas
+    StateClosed State = "closed"
+    // StateOpen rechaza las llamadas hasta que pasa el cool-down
+    StateOpen State = "open"
+    // StateHalfOpen deja pasar una llamada de prueba: si va bien se cierra y si
+    // falla se vuelve a abrir
+    StateHalfOpen State = "half_open"
+)
+
+// ErrOpen se devuelve (envuelto en *OpenError) mientras el circuito está abierto
+var ErrOpen = errors.New("circuit breaker is open")
+
+// OpenError indica qué circuito rechazó la llamada y cuándo se volverá a probar
+type OpenError struct {
+    Name      string
+    RetryAt   time.Time
+    LastError string
+}
+
+func (e *OpenError) Error() string {
+    message := fmt.Sprintf("circuit breaker for %s is open until %s", e.Name, e.RetryAt.UTC().Format(time.RFC3339))
+    if e.LastError != "" {
+        message += " (last error: " + e.LastError + ")"
+    }
+    return message
+}
+
+func (e *OpenError) Is(target error) bool {
+    return target == ErrOpen
+}
+
+// Options configura cuándo se abre el circuito y cuánto tarda en probarse
+type Options struct {
+    // FailureThreshold es el número de fallos consecutivos que abren el circuito
+    FailureThreshold int
+    // CoolDown es el tiempo que el circuito permanece abierto
+    CoolDown time.Duration
+}
+
+// Status es una instantánea del breaker para /readyz
+type Status struct {
+    Name                string     `json:"name"`
+    State               State      `json:"state"`
+    ConsecutiveFailures int        `json:"consecutive_failures"`
+    OpenedAt            *time.Time `json:"opened_at,omitempty"`
+    RetryAt             *time.Time `json:"retry_at,omitempty"`
+    LastError           string     `json:"last_error,omitempty"`
+}
+
+// Breaker es un circuit breaker closed/open/half-open
+type Breaker struct {
+    name    string
+    options Options
+    now     func() time.Time
+
+    mu        sync.Mutex
+    state     State
+    failures  int
+    openedAt  time.Time
+    probing   bool
+    lastError string
+}
+
+func New(name string, options Options) *Breaker {
+    if options.FailureThreshold < 1 {
+        options.FailureThreshold = 1
+    }
+    return &Breaker{name: name, options: options, now: time.Now, state: StateClosed}
+}
+
+// WithClock sustituye el reloj (para tests)
+func (b *Breaker) WithClock(now func() time.Time) *Breaker {
+    b.now = now
+    return b
+}
+
+func (b *Breaker) Name() string {
+    return b.name
+}
+
+// Allow indica si se puede hacer una llamada. Cada llamada permitida debe
+// terminar con Success, Failure o Ignore.
+func (b *Breaker) Allow() error {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+
+    switch b.state {
+    case StateOpen:
+        if b.now().Before(b.retryAtLocked()) {
+            return b.openErrorLocked()
+        }
+        b.state = StateHalfOpen
+        b.probing = true
+        return nil
+    case StateHalfOpen:
+        if b.probing {
+            return b.openErrorLocked()
+        }
+        b.probing = true
+        return nil
+    }
+    return nil
+}
+
+// Success cierra el circuito y reinicia el contador de fallos
+func (b *Breaker) Success() {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+
+    b.state = StateClosed
+    b.failures = 0
+    b.probing = false
+    b.lastError = ""
+}
+
+// Failure cuenta un fallo; abre el circuito al llegar al umbral o si falla la
+// llamada de prueba en half-open
+func (b *Breaker) Failure(err error) {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+
+    b.failures++
+    if err != nil {
+        b.lastError = err.Error()
+    }
+    if b.state == StateHalfOpen || b.failures >= b.options.FailureThreshold {
+        b.state = StateOpen
+        b.openedAt = b.now()
+    }
+    b.probing = false
+}
+
+// Ignore termina una llamada sin contarla, p.ej. si la canceló quien la hizo
+func (b *Breaker) Ignore() {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    b.probing = false
+}
+
+func (b *Breaker) Status() Status {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+
+    status := Status{
+        Name:                b.name,
+        State:               b.state,
+        ConsecutiveFailures: b.failures,
+        LastError:           b.lastError,
+    }
+    if b.state != StateClosed {
+        openedAt := b.openedAt
+        retryAt := b.retryAtLocked()
+        status.OpenedAt = &openedAt
+        status.RetryAt = &retryAt
+    }
+    return status
+}
+
+func (b *Breaker) retryAtLocked() time.Time {
+    return b.openedAt.Add(b.options.CoolDown)
+}
+
+func (b *Breaker) openErrorLocked() error {
+    return &OpenError{Name: b.name, RetryAt: b.retryAtLocked(), LastError: b.lastError}
+}
@@ -0,0 +1,48 @@
+package test
+
+import (
+    "errors"
+    "testing"
+    "time"
+    "admira-etl/internal/breaker"
+)
+
+func TestBreaker_OpensAfterThresholdAndProbesAfterCoolDown(t *testing.T) {
+    now := time.Date(2025, 8, 1, 12, 0, 0, 0, time.UTC)
+    b := breaker.New("ads", breaker.Options{FailureThreshold: 2, CoolDown: time.Minute}).
+        WithClock(func() time.Time { return now })
+
+    for i := 0; i < 2; i++ {
+        if err := b.Allow(); err != nil {
+            t.Fatalf("Closed breaker rejected call %d: %v", i, err)
+        }
+        b.Failure(errors.New("connection refused"))
+    }
+
+    err := b.Allow()
+    if !errors.Is(err, breaker.ErrOpen) || b.Status().State != breaker.StateOpen {
+        t.Fatalf("Expected the breaker to be open, got %v (%+v)", err, b.Status())
+    }
+
+    // Tras el cool-down deja pasar una sola llamada de prueba
+    now = now.Add(time.Minute)
+    if err := b.Allow(); err != nil {
+        t.Fatalf("Expected a probe after the cool-down, got %v", err)
+    }
+    if err := b.Allow(); !errors.Is(err, breaker.ErrOpen) || b.Status().State != breaker.StateHalfOpen {
+        t.Errorf("Expected concurrent calls to be rejected while probing, got %v", err)
+    }
+
+    // Si la prueba falla se vuelve a abrir
+    b.Failure(errors.New("still down"))
+    if status := b.Status(); status.State != breaker.StateOpen || !status.RetryAt.Equal(now.Add(time.Minute)) {
+        t.Errorf("Expected the breaker to reopen, got %+v", status)
+    }
+
+    now = now.Add(time.Minute)
+    b.Allow()
+    b.Success()
+    if status := b.Status(); status.State != breaker.StateClosed || status.ConsecutiveFailures != 0 {
+        t.Errorf("Expected the breaker to close after a successful probe, got %+v", status)
+    }
+}
@@ -0,0 +1,155 @@
+package etl
+
+import (
+    "fmt"
+    "math"
+    "sort"
+    "time"
+
+    "admira-etl/internal/models"
+)
+
+// AttributionModel decide cómo se reparte el crédito de un registro CRM
+// entre las filas de Ads (touchpoints) que lo precedieron
+type AttributionModel string
+
+const (
+    AttributionLastTouch  AttributionModel = "last_touch"
+    AttributionFirstTouch AttributionModel = "first_touch"
+    AttributionLinear     AttributionModel = "linear"
+    AttributionTimeDecay  AttributionModel = "time_decay"
+)
+
+// ParseAttributionModel valida el nombre de un modelo de atribución
+func ParseAttributionModel(name string) (AttributionModel, error) {
+    switch model := AttributionModel(name); model {
+    case AttributionLastTouch, AttributionFirstTouch, AttributionLinear, AttributionTimeDecay:
+        return model, nil
+    default:
+        return "", fmt.Errorf("unknown attribution model: %s", name)
+    }
+}
+
+type AttributionConfig struct {
+    Model        AttributionModel `json:"model"`
+    LookbackDays int              `json:"lookback_days"`
+    HalfLifeDays float64          `json:"half_life_days"`
+}
+
+// AttributionStats resume cuántos registros CRM se atribuyeron a filas de Ads
+type AttributionStats struct {
+    Model        AttributionModel `json:"model"`
+    Attributed   int              `json:"attributed"`
+    Unattributed int              `json:"unattributed"`
+}
+
+// touchpoint es una fila de Ads candidata a recibir crédito
+type touchpoint struct {
+    metric *models.Metrics
+    date   time.Time
+}
+
+// touchpointIndex agrupa las filas de Ads por utm_campaign para buscar candidatos
+type touchpointIndex map[string][]touchpoint
+
+func newTouchpointIndex(metricsMap map[MetricKey]*models.Metrics) touchpointIndex {
+    index := make(touchpointIndex)
+    for _, metric := range metricsMap {
+        date, err := time.Parse("2006-01-02", metric.Date)
+        if err != nil || metric.UTMCampaign == "" {
+            continue
+        }
+        index[metric.UTMCampaign] = append(index[metric.UTMCampaign], touchpoint{metric: metric, date: date})
+    }
+
+    // Orden estable para que el reparto sea determinista
+    for _, tps := range index {
+        sort.Slice(tps, func(i, j int) bool {
+            if !tps[i].date.Equal(tps[j].date) {
+                return tps[i].date.Before(tps[j].date)
+            }
+            return tps[i].metric.CampaignID < tps[j].metric.CampaignID
+        })
+    }
+    return index
+}
+
+// candidates devuelve las filas de Ads de la misma campaña (y source/medium si
+// ambos lados los informan) dentro de la ventana [createdAt - lookback, createdAt]
+func (idx touchpointIndex) candidates(crm models.CRMOpportunity, createdDate time.Time, lookbackDays int) []touchpoint {
+    windowStart := createdDate.AddDate(0, 0, -lookbackDays)
+
+    var result []touchpoint
+    for _, tp := range idx[crm.UTMCampaign] {
+        if tp.date.Before(windowStart) || tp.date.After(createdDate) {
+            continue
+        }
+        if crm.UTMSource != "" && tp.metric.UTMSource != "" && crm.UTMSource != tp.metric.UTMSource {
+            continue
+        }
+        if crm.UTMMedium != "" && tp.metric.UTMMedium != "" && crm.UTMMedium != tp.metric.UTMMedium {
+            continue
+        }
+        result = append(result, tp)
+    }
+    return result
+}
+
+// attributionWeights reparte un crédito de 1.0 entre los touchpoints (ordenados por fecha)
+func attributionWeights(cfg AttributionConfig, tps []touchpoint, createdDate time.Time) []float64 {
+    weights := make([]float64, len(tps))
+
+    switch cfg.Model {
+    case AttributionFirstTouch, AttributionLastTouch:
+        target := tps[len(tps)-1].date
+        if cfg.Model == AttributionFirstTouch {
+            target = tps[0].date
+        }
+        // Si hay varias filas el mismo día se reparte entre ellas
+        var n int
+        for _, tp := range tps {
+            if tp.date.Equal(target) {
+                n++
+            }
+        }
+        for i, tp := range tps {
+            if tp.date.Equal(target) {
+                weights[i] = 1 / float64(n)
+            }
+        }
+
+    case AttributionTimeDecay:
+        halfLife := cfg.HalfLifeDays
+        if halfLife <= 0 {
+            halfLife = 7
+        }
+        var total float64
+        for i, tp := range tps {
+            daysBefore := createdDate.Sub(tp.date).Hours() / 24
+            weights[i] = math.Pow(0.5, daysBefore/halfLife)
+            total += weights[i]
+        }
+        for i := range weights {
+            weights[i] /= total
+        }
+
+    default: // linear
+        for i := range weights {
+            weights[i] = 1 / float64(len(tps))
+        }
+    }
+    return weights
+}
+
+// applyCRMCredit suma a la métrica la fracción weight del registro CRM
+func applyCRMCredit(metric *models.Metrics, crm models.CRMOpportunity, weight float64) {
+    switch crm.Stage {
+    case "lead":
+        metric.Leads += weight
+    case "opportunity":
+        metric.Opportunities += weight
+    case "closed_won":
+        metric.ClosedWon += weight
+        metric.Revenue += crm.Amount * weight
+    }
+}
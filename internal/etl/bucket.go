@@ -0,0 +1,225 @@
+package etl
+
+import (
+    "errors"
+    "fmt"
+    "sort"
+    "strings"
+    "time"
+
+    "admira-etl/internal/models"
+)
+
+// MaxBuckets es el número máximo de buckets de una serie temporal
+const MaxBuckets = 1000
+
+// ErrTooManyBuckets indica que el rango pedido genera más de MaxBuckets buckets
+var ErrTooManyBuckets = errors.New("too many buckets")
+
+// Granularity es el tamaño de los buckets de una serie temporal
+type Granularity string
+
+const (
+    GranularityDay     Granularity = "day"
+    GranularityWeek    Granularity = "week"
+    GranularityMonth   Granularity = "month"
+    GranularityQuarter Granularity = "quarter"
+)
+
+func ParseGranularity(value string) (Granularity, error) {
+    switch g := Granularity(strings.ToLower(value)); g {
+    case GranularityDay, GranularityWeek, GranularityMonth, GranularityQuarter:
+        return g, nil
+    }
+    return "", fmt.Errorf("unknown granularity %q (use day, week, month or quarter)", value)
+}
+
+// ParseWeekday acepta el nombre del día en inglés ("monday", "sun", ...)
+func ParseWeekday(value string) (time.Weekday, error) {
+    value = strings.ToLower(strings.TrimSpace(value))
+    for d := time.Sunday; d <= time.Saturday; d++ {
+        name := strings.ToLower(d.String())
+        if value == name || value == name[:3] {
+            return d, nil
+        }
+    }
+    return time.Monday, fmt.Errorf("unknown weekday %q", value)
+}
+
+// BucketOptions configura el agrupado temporal
+type BucketOptions struct {
+    Granularity Granularity
+    // WeekStart es el primer día de las semanas; con lunes son semanas ISO
+    WeekStart time.Weekday
+}
+
+// Bucket es un periodo [Start, End] (ambos inclusive, en días)
+type Bucket struct {
+    Period string `json:"period"`
+    Start  string `json:"start"`
+    End    string `json:"end"`
+}
+
+// BucketFor devuelve el bucket que contiene la fecha
+func (o BucketOptions) BucketFor(date time.Time) Bucket {
+    date = time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+
+    var start, end time.Time
+    var period string
+    switch o.Granularity {
+    case GranularityWeek:
+        offset := (int(date.Weekday()) - int(o.WeekStart) + 7) % 7
+        start = date.AddDate(0, 0, -offset)
+        end = start.AddDate(0, 0, 6)
+        if o.WeekStart == time.Monday {
+            year, week := start.ISOWeek()
+            period = fmt.Sprintf("%d-W%02d", year, week)
+        } else {
+            period = start.Format("2006-01-02")
+        }
+    case GranularityMonth:
+        start = time.Date(date.Year(), date.Month(), 1, 0, 0, 0, 0, time.UTC)
+        end = start.AddDate(0, 1, -1)
+        period = start.Format("2006-01")
+    case GranularityQuarter:
+        quarter := (int(date.Month()) - 1) / 3
+        start = time.Date(date.Year(), time.Month(quarter*3+1), 1, 0, 0, 0, 0, time.UTC)
+        end = start.AddDate(0, 3, -1)
+        period = fmt.Sprintf("%d-Q%d", date.Year(), quarter+1)
+    default:
+        start, end = date, date
+        period = date.Format("2006-01-02")
+    }
+
+    return Bucket{
+        Period: period,
+        Start:  start.Format("2006-01-02"),
+        End:    end.Format("2006-01-02"),
+    }
+}
+
+// Buckets enumera todos los buckets que cubren el rango [from, to]. Falla con
+// ErrTooManyBuckets si son más de MaxBuckets.
+func (o BucketOptions) Buckets(from, to time.Time) ([]Bucket, error) {
+    var buckets []Bucket
+    for date := from; !date.After(to); {
+        if len(buckets) == MaxBuckets {
+            return nil, fmt.Errorf("%w: the range %s to %s needs more than %d %s buckets",
+                ErrTooManyBuckets, from.Format("2006-01-02"), to.Format("2006-01-02"), MaxBuckets, o.Granularity)
+        }
+        bucket := o.BucketFor(date)
+        buckets = append(buckets, bucket)
+        end, _ := time.Parse("2006-01-02", bucket.End)
+        date = end.AddDate(0, 0, 1)
+    }
+    return buckets, nil
+}
+
+// SeriesPoint es una fila de una serie temporal: el bucket y las métricas
+// agregadas en él. Date contiene el inicio del bucket.
+type SeriesPoint struct {
+    Bucket
+    models.Metrics
+}
+
+// TimeSeries agrega las métricas por bucket y por las dimensiones de groupBy
+// (la fecha se ignora si aparece en groupBy). Cada serie se rellena con ceros
+// en los buckets sin datos entre from y to; si from o to son cero se usa el
+// rango de las propias métricas. Sin métricas, un from sin to se rellena hasta
+// hoy y un to sin from es un único bucket.
+func TimeSeries(metrics []models.Metrics, groupBy []Dimension, from, to time.Time, options BucketOptions) ([]SeriesPoint, error) {
+    var dimensions []Dimension
+    for _, d := range groupBy {
+        if d != DimensionDate {
+            dimensions = append(dimensions, d)
+        }
+    }
+
+    type seriesKey struct {
+        bucket string
+        group  string
+    }
+    points := make(map[seriesKey]*SeriesPoint)
+    groups := make(map[string]models.Metrics)
+    var minDate, maxDate time.Time
+
+    for _, metric := range metrics {
+        date, err := time.Parse("2006-01-02", metric.Date)
+        if err != nil {
+            return nil, fmt.Errorf("invalid metric date %q: %v", metric.Date, err)
+        }
+        if minDate.IsZero() || date.Before(minDate) {
+            minDate = date
+        }
+        if date.After(maxDate) {
+            maxDate = date
+        }
+
+        group := models.Metrics{}
+        values := make([]string, len(dimensions))
+        for i, d := range dimensions {
+            values[i] = d.Value(metric)
+            d.set(&group, values[i])
+        }
+        groupKey := strings.Join(values, "\x00")
+        groups[groupKey] = group
+
+        bucket := options.BucketFor(date)
+        key := seriesKey{bucket: bucket.Start, group: groupKey}
+        point, exists := points[key]
+        if !exists {
+            point = &SeriesPoint{Bucket: bucket, Metrics: group}
+            point.Date = bucket.Start
+            points[key] = point
+        }
+        AddCounters(&point.Metrics, metric)
+    }
+
+    if from.IsZero() {
+        from = minDate
+    }
+    if to.IsZero() {
+        to = maxDate
+    }
+    if len(metrics) == 0 {
+        switch {
+        case !from.IsZero() && to.IsZero():
+            now := time.Now().UTC()
+            to = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+        case from.IsZero() && !to.IsZero():
+            from = to
+        }
+    }
+    // Sin dimensiones adicionales siempre hay una serie, aunque esté vacía
+    if len(dimensions) == 0 && len(groups) == 0 {
+        groups[""] = models.Metrics{}
+    }
+
+    var result []SeriesPoint
+    if from.IsZero() || to.IsZero() {
+        return result, nil
+    }
+    buckets, err := options.Buckets(from, to)
+    if err != nil {
+        return nil, err
+    }
+
+    groupKeys := make([]string, 0, len(groups))
+    for key := range groups {
+        groupKeys = append(groupKeys, key)
+    }
+    sort.Strings(groupKeys)
+
+    for _, groupKey := range groupKeys {
+        for _, bucket := range buckets {
+            point, exists := points[seriesKey{bucket: bucket.Start, group: groupKey}]
+            if !exists {
+                point = &SeriesPoint{Bucket: bucket, Metrics: groups[groupKey]}
+                point.Date = bucket.Start
+            }
+            CalculateDerivedMetrics(&point.Metrics)
+            result = append(result, *point)
+        }
+    }
+    return result, nil
+}
@@ -0,0 +1,157 @@
+package etl
+
+import (
+    "encoding/json"
+    "fmt"
+    "os"
+    "regexp"
+    "strings"
+)
+
+// MatchType es el tipo de comparación de una condición de regla
+type MatchType string
+
+const (
+    MatchExact           MatchType = "exact"
+    MatchCaseInsensitive MatchType = "case_insensitive"
+    MatchPrefix          MatchType = "prefix" // sin distinguir mayúsculas
+    MatchRegex           MatchType = "regex"
+)
+
+// Condition compara un valor UTM (source o medium)
+type Condition struct {
+    Type  MatchType `json:"type"`
+    Value string    `json:"value"`
+
+    re *regexp.Regexp
+}
+
+func (c *Condition) compile() error {
+    switch c.Type {
+    case MatchExact, MatchCaseInsensitive, MatchPrefix:
+        return nil
+    case MatchRegex:
+        re, err := regexp.Compile(c.Value)
+        if err != nil {
+            return fmt.Errorf("invalid regex %q: %v", c.Value, err)
+        }
+        c.re = re
+        return nil
+    default:
+        return fmt.Errorf("unknown match type %q", c.Type)
+    }
+}
+
+func (c *Condition) matches(value string) bool {
+    switch c.Type {
+    case MatchExact:
+        return value == c.Value
+    case MatchCaseInsensitive:
+        return strings.EqualFold(value, c.Value)
+    case MatchPrefix:
+        return strings.HasPrefix(strings.ToLower(value), strings.ToLower(c.Value))
+    case MatchRegex:
+        return c.re.MatchString(value)
+    }
+    return false
+}
+
+// ChannelRule asigna un canal canónico cuando se cumplen sus condiciones.
+// Una condición nula se considera cumplida.
+type ChannelRule struct {
+    Name    string     `json:"name"`
+    Source  *Condition `json:"source,omitempty"`
+    Medium  *Condition `json:"medium,omitempty"`
+    Channel string     `json:"channel"`
+}
+
+// ChannelMatch describe qué regla resolvió el canal de un par source/medium
+type ChannelMatch struct {
+    Channel   string `json:"channel"`
+    Rule      string `json:"rule,omitempty"`
+    RuleIndex int    `json:"rule_index"`
+    Fallback  bool   `json:"fallback"`
+}
+
+// ChannelRules es una lista ordenada de reglas; gana la primera que coincide
+type ChannelRules struct {
+    rules []ChannelRule
+}
+
+func NewChannelRules(rules []ChannelRule) (*ChannelRules, error) {
+    compiled := make([]ChannelRule, len(rules))
+    for i, rule := range rules {
+        if rule.Channel == "" {
+            return nil, fmt.Errorf("rule %d (%s): channel is required", i, rule.Name)
+        }
+        for _, cond := range []*Condition{rule.Source, rule.Medium} {
+            if cond == nil {
+                continue
+            }
+            if err := cond.compile(); err != nil {
+                return nil, fmt.Errorf("rule %d (%s): %v", i, rule.Name, err)
+            }
+        }
+        compiled[i] = rule
+    }
+    return &ChannelRules{rules: compiled}, nil
+}
+
+// DefaultChannelRules reproduce el mapeo histórico de utm_source a canal
+func DefaultChannelRules() *ChannelRules {
+    var rules []ChannelRule
+    for _, source := range []string{"google", "facebook", "tiktok", "linkedin"} {
+        rules = append(rules, ChannelRule{
+            Name:    source,
+            Source:  &Condition{Type: MatchCaseInsensitive, Value: source},
+            Channel: source + "_ads",
+        })
+    }
+    channelRules, _ := NewChannelRules(rules)
+    return channelRules
+}
+
+// LoadChannelRules lee las reglas de un fichero JSON: {"rules": [...]}
+func LoadChannelRules(path string) (*ChannelRules, error) {
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return nil, fmt.Errorf("failed to read channel rules: %v", err)
+    }
+
+    var file struct {
+        Rules []ChannelRule `json:"rules"`
+    }
+    if err := json.Unmarshal(data, &file); err != nil {
+        return nil, fmt.Errorf("failed to parse channel rules: %v", err)
+    }
+    return NewChannelRules(file.Rules)
+}
+
+// Rules devuelve una copia de las reglas en orden de evaluación
+func (r *ChannelRules) Rules() []ChannelRule {
+    return append([]ChannelRule{}, r.rules...)
+}
+
+// Match evalúa las reglas en orden. Si ninguna coincide el canal es "source_medium".
+func (r *ChannelRules) Match(utmSource, utmMedium string) ChannelMatch {
+    for i, rule := range r.rules {
+        if rule.Source != nil && !rule.Source.matches(utmSource) {
+            continue
+        }
+        if rule.Medium != nil && !rule.Medium.matches(utmMedium) {
+            continue
+        }
+        return ChannelMatch{Channel: rule.Channel, Rule: rule.Name, RuleIndex: i}
+    }
+
+    return ChannelMatch{
+        Channel:   fmt.Sprintf("%s_%s", utmSource, utmMedium),
+        RuleIndex: -1,
+        Fallback:  true,
+    }
+}
+
+// Infer devuelve solo el canal resultante
+func (r *ChannelRules) Infer(utmSource, utmMedium string) string {
+    return r.Match(utmSource, utmMedium).Channel
+}
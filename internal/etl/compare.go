@@ -0,0 +1,69 @@
+package etl
+
+import (
+    "fmt"
+    "time"
+
+    "admira-etl/internal/models"
+)
+
+// CompareMode es el periodo con el que se compara un rango de fechas
+type CompareMode string
+
+const (
+    ComparePreviousPeriod CompareMode = "previous_period"
+    ComparePreviousYear   CompareMode = "previous_year"
+)
+
+func ParseCompareMode(value string) (CompareMode, error) {
+    switch mode := CompareMode(value); mode {
+    case ComparePreviousPeriod, ComparePreviousYear:
+        return mode, nil
+    }
+    return "", fmt.Errorf("unknown compare mode %q (use previous_period or previous_year)", value)
+}
+
+// ComparisonRange devuelve el rango de comparación de [from, to] (inclusive).
+// previous_period es el mismo número de días justo antes de from;
+// previous_year son las mismas fechas un año antes.
+func ComparisonRange(from, to time.Time, mode CompareMode) (time.Time, time.Time) {
+    if mode == ComparePreviousYear {
+        return from.AddDate(-1, 0, 0), to.AddDate(-1, 0, 0)
+    }
+    days := int(to.Sub(from).Hours()/24) + 1
+    return from.AddDate(0, 0, -days), from.AddDate(0, 0, -1)
+}
+
+// Delta es la variación de una métrica. Percent es nil si el valor previo es 0.
+type Delta struct {
+    Current  float64  `json:"current"`
+    Previous float64  `json:"previous"`
+    Absolute float64  `json:"absolute"`
+    Percent  *float64 `json:"percent"`
+}
+
+func NewDelta(current, previous float64) Delta {
+    delta := Delta{
+        Current:  current,
+        Previous: previous,
+        Absolute: current - previous,
+    }
+    if previous != 0 {
+        percent := (current - previous) / previous * 100
+        delta.Percent = &percent
+    }
+    return delta
+}
+
+// CompareMetrics calcula las variaciones de clicks, cost, leads, revenue, CPA y
+// ROAS entre dos filas agregadas
+func CompareMetrics(current, previous models.Metrics) map[string]Delta {
+    return map[string]Delta{
+        "clicks":  NewDelta(float64(current.Clicks), float64(previous.Clicks)),
+        "cost":    NewDelta(current.Cost, previous.Cost),
+        "leads":   NewDelta(current.Leads, previous.Leads),
+        "revenue": NewDelta(current.Revenue, previous.Revenue),
+        "cpa":     NewDelta(current.CPA, previous.CPA),
+        "roas":    NewDelta(current.ROAS, previous.ROAS),
+    }
+}
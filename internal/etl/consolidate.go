@@ -0,0 +1,28 @@
+package etl
+
+import (
+    "admira-etl/internal/models"
+)
+
+// ConsolidationGroupBy normaliza la agrupación de una exportación. Vacía usa
+// todas las dimensiones de MetricKey, de modo que dos filas que difieren en
+// utm_source o utm_medium no se mezclan. La fecha se añade siempre en primer
+// lugar porque las exportaciones se particionan por día.
+func ConsolidationGroupBy(groupBy []Dimension) []Dimension {
+    if len(groupBy) == 0 {
+        return Dimensions()
+    }
+    for _, d := range groupBy {
+        if d == DimensionDate {
+            return groupBy
+        }
+    }
+    return append([]Dimension{DimensionDate}, groupBy...)
+}
+
+// Consolidate agrupa las métricas igual que Aggregate (contadores sumados y
+// métricas derivadas recalculadas) y devuelve también la agrupación usada
+func Consolidate(metrics []models.Metrics, groupBy []Dimension) ([]models.Metrics, []Dimension) {
+    groupBy = ConsolidationGroupBy(groupBy)
+    return Aggregate(metrics, groupBy), groupBy
+}
@@ -2,91 +2,379 @@
 
 import (
     "context"
-    "encoding/json"
+    "errors"
     "fmt"
     "io"
     "net/http"
+    "sync"
     "time"
 
-    "admira-etl/internal/models"
+    "admira-etl/internal/breaker"
+    "admira-etl/internal/retry"
     "admira-etl/pkg/config"
 )
 
 type Extractor struct {
-    cfg *config.Config
+    cfg      *config.Config
+    registry *Registry
+    // client se comparte entre intentos y orígenes para reutilizar conexiones
+    client *http.Client
+    policy retry.Policy
+
+    // Un circuit breaker por origen, creado en la primera descarga
+    breakerOptions breaker.Options
+    breakersMu     sync.Mutex
+    breakers       map[string]*breaker.Breaker
 }
 
-func NewExtractor(cfg *config.Config) *Extractor {
-    return &Extractor{cfg: cfg}
+func NewExtractor(cfg *config.Config, registry *Registry) *Extractor {
+    return &Extractor{
+        cfg:      cfg,
+        registry: registry,
+        client:   &http.Client{Timeout: cfg.Timeout},
+        policy: retry.Policy{
+            MaxAttempts: cfg.MaxRetries,
+            Backoff: retry.Backoff{
+                Base: cfg.BackoffTime,
+                Max:  cfg.BackoffMax,
+            },
+            MaxRetryAfter: cfg.RetryAfterMax,
+        },
+        breakerOptions: breaker.Options{
+            FailureThreshold: cfg.BreakerFailureThreshold,
+            CoolDown:         cfg.BreakerCoolDown,
+        },
+        breakers: make(map[string]*breaker.Breaker),
+    }
 }
 
-func (e *Extractor) fetchWithRetry(ctx context.Context, url string, maxRetries int) ([]byte, error) {
-    var lastErr error
-    
-    for i := 0; i < maxRetries; i++ {
-        req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-        if err != nil {
-            return nil, err
-        }
+// breakerFor devuelve el circuit breaker de un origen
+func (e *Extractor) breakerFor(name string) *breaker.Breaker {
+    e.breakersMu.Lock()
+    defer e.breakersMu.Unlock()
 
-        client := &http.Client{Timeout: e.cfg.Timeout}
-        resp, err := client.Do(req)
-        if err != nil {
-            lastErr = err
-            time.Sleep(e.cfg.BackoffTime * time.Duration(i+1))
-            continue
-        }
-        defer resp.Body.Close()
+    b, ok := e.breakers[name]
+    if !ok {
+        b = breaker.New(name, e.breakerOptions)
+        e.breakers[name] = b
+    }
+    return b
+}
 
-        if resp.StatusCode != http.StatusOK {
-            lastErr = fmt.Errorf("HTTP error: %s", resp.Status)
-            time.Sleep(e.cfg.BackoffTime * time.Duration(i+1))
-            continue
-        }
+// Breakers devuelve el estado del circuit breaker de cada origen registrado
+func (e *Extractor) Breakers() []breaker.Status {
+    var result []breaker.Status
+    for _, src := range e.registry.Sources() {
+        result = append(result, e.breakerFor(src.Name()).Status())
+    }
+    return result
+}
 
-        body, err := io.ReadAll(resp.Body)
-        if err != nil {
-            return nil, err
+// fetchWithRetry descarga una URL con la política de reintentos y devuelve
+// también cada intento realizado. Cada intento pasa por el circuit breaker del
+// origen: con el circuito abierto falla al instante sin agotar los reintentos.
+func (e *Extractor) fetchWithRetry(ctx context.Context, b *breaker.Breaker, url string) ([]byte, []retry.Attempt, error) {
+    var body []byte
+    attempts, err := e.policy.Do(ctx, func(ctx context.Context) error {
+        if err := b.Allow(); err != nil {
+            return err
         }
 
-        return body, nil
+        data, err := e.get(ctx, url)
+        switch {
+        case err == nil:
+            b.Success()
+            body = data
+        case errors.Is(err, context.Canceled):
+            b.Ignore()
+        case isClientError(err):
+            // El upstream responde: un 4xx no indica que esté caído
+            b.Success()
+        default:
+            b.Failure(err)
+        }
+        return err
+    })
+    if err != nil {
+        return nil, attempts, err
     }
-
-    return nil, fmt.Errorf("failed after %d retries: %v", maxRetries, lastErr)
+    return body, attempts, nil
 }
 
-func (e *Extractor) ExtractAdsData(ctx context.Context) ([]models.AdsPerformance, error) {
-    body, err := e.fetchWithRetry(ctx, e.cfg.AdsURL, e.cfg.MaxRetries)
+func (e *Extractor) get(ctx context.Context, url string) ([]byte, error) {
+    req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
     if err != nil {
         return nil, err
     }
 
-    var response models.AdsResponse
-    if err := json.Unmarshal(body, &response); err != nil {
+    resp, err := e.client.Do(req)
+    if err != nil {
         return nil, err
     }
+    defer resp.Body.Close()
 
-    for i := range response.External.Ads.Performance {
-        response.External.Ads.Performance[i].IngestedAt = time.Now()
+    if err := retry.CheckResponse(resp); err != nil {
+        // Vaciar el cuerpo para que la conexión se pueda reutilizar
+        io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+        return nil, err
     }
+    return io.ReadAll(resp.Body)
+}
 
-    return response.External.Ads.Performance, nil
+// isClientError indica si el error es una respuesta 4xx distinta de 429
+func isClientError(err error) bool {
+    status := retry.StatusCode(err)
+    return status >= 400 && status < 500 && status != http.StatusTooManyRequests
 }
 
-func (e *Extractor) ExtractCRMData(ctx context.Context) ([]models.CRMOpportunity, error) {
-    body, err := e.fetchWithRetry(ctx, e.cfg.CrmURL, e.cfg.MaxRetries)
-    if err != nil {
-        return nil, err
+// Registry devuelve los orígenes registrados en el extractor
+func (e *Extractor) Registry() *Registry {
+    return e.registry
+}
+
+// PageFunc recibe cada página de registros de un origen ya decodificada
+type PageFunc func(source string, page *Records) error
+
+// Extract descarga y decodifica un único origen registrado
+func (e *Extractor) Extract(ctx context.Context, name string) (*Records, error) {
+    src, ok := e.registry.Get(name)
+    if !ok {
+        return nil, fmt.Errorf("unknown source: %s", name)
     }
 
-    var response models.CRMResponse
-    if err := json.Unmarshal(body, &response); err != nil {
-        return nil, err
+    records := &Records{}
+    result, err := e.extractSource(ctx, src, "", func(page *Records) error {
+        records.Merge(page)
+        return nil
+    })
+    records.Fetches = result.fetches
+    return records, err
+}
+
+// ExtractAll extrae todos los orígenes registrados como ExtractPages, sin
+// marcas incrementales, y combina sus registros en orden de registro
+func (e *Extractor) ExtractAll(ctx context.Context) (*Records, error) {
+    bySource := make(map[string]*Records)
+    summary, err := e.ExtractPages(ctx, nil, func(source string, page *Records) error {
+        if bySource[source] == nil {
+            bySource[source] = &Records{}
+        }
+        bySource[source].Merge(page)
+        return nil
+    })
+
+    all := &Records{}
+    for _, report := range summary.Sources {
+        all.Merge(bySource[report.Source])
+    }
+    all.Fetches = summary.Fetches
+    all.Sources = summary.Sources
+    all.Partial = summary.Partial
+    return all, err
+}
+
+// ExtractPages extrae en paralelo todos los orígenes registrados, como mucho
+// ExtractConcurrency a la vez, y entrega cada página a emit según llega; las
+// llamadas a emit nunca son concurrentes. watermarks indica la marca de cada
+// origen incremental (sin marca se descarga todo). Si falla un origen con
+// on_error=abort se cancelan los demás y se devuelve el error; con
+// on_error=continue se sigue con el resto y el resultado se marca como
+// Partial. Los registros devueltos solo llevan los intentos de descarga y el
+// resumen de cada origen, también si falla.
+func (e *Extractor) ExtractPages(ctx context.Context, watermarks map[string]string, emit PageFunc) (*Records, error) {
+    ctx, cancel := context.WithCancel(ctx)
+    defer cancel()
+
+    sources := e.registry.Sources()
+    fetches := make([][]FetchReport, len(sources))
+    reports := make([]SourceReport, len(sources))
+
+    limit := e.cfg.ExtractConcurrency
+    if limit < 1 {
+        limit = 1
+    }
+    sem := make(chan struct{}, limit)
+
+    var (
+        wg       sync.WaitGroup
+        emitMu   sync.Mutex
+        abortMu  sync.Mutex
+        abortErr error
+    )
+    for i, src := range sources {
+        wg.Add(1)
+        go func(i int, src Source) {
+            defer wg.Done()
+
+            report := SourceReport{Source: src.Name(), OnError: e.onError(src.Name())}
+            select {
+            case sem <- struct{}{}:
+                defer func() { <-sem }()
+            case <-ctx.Done():
+                report.Status = SourceCanceled
+                report.Error = ctx.Err().Error()
+                reports[i] = report
+                return
+            }
+
+            start := time.Now()
+            result, err := e.extractSource(ctx, src, watermarks[src.Name()], func(page *Records) error {
+                report.Pages++
+                report.Records += len(page.Ads) + len(page.CRM)
+
+                emitMu.Lock()
+                defer emitMu.Unlock()
+                return emit(src.Name(), page)
+            })
+            report.DurationMs = time.Since(start).Milliseconds()
+            report.Watermark = result.watermark
+            report.NextWatermark = result.nextWatermark
+            fetches[i] = result.fetches
+
+            switch {
+            case err == nil:
+                report.Status = SourceSucceeded
+            case errors.Is(err, context.Canceled) && ctx.Err() != nil:
+                report.Status = SourceCanceled
+                report.Error = err.Error()
+            default:
+                report.Status = SourceFailed
+                report.Error = err.Error()
+                if report.OnError == OnErrorAbort {
+                    abortMu.Lock()
+                    if abortErr == nil {
+                        abortErr = fmt.Errorf("source %s: %w", src.Name(), err)
+                    }
+                    abortMu.Unlock()
+                    cancel()
+                }
+            }
+            reports[i] = report
+        }(i, src)
+    }
+    wg.Wait()
+
+    summary := &Records{}
+    for i := range sources {
+        summary.Fetches = append(summary.Fetches, fetches[i]...)
+        summary.Sources = append(summary.Sources, reports[i])
+        if reports[i].Status != SourceSucceeded {
+            summary.Partial = true
+        }
+    }
+    if abortErr != nil {
+        return summary, abortErr
+    }
+    return summary, nil
+}
+
+// Incremental devuelve la extracción incremental de un origen, con los
+// valores por defecto completados, si la tiene configurada
+func (e *Extractor) Incremental(name string) (config.IncrementalConfig, bool) {
+    for _, sc := range e.cfg.Sources {
+        if sc.Name != name || sc.Incremental == nil {
+            continue
+        }
+        // NewRegistryFromConfig ya validó la configuración
+        incremental, err := normalizeIncremental(*sc.Incremental)
+        return incremental, err == nil
     }
+    return config.IncrementalConfig{}, false
+}
 
-    for i := range response.External.CRM.Opportunities {
-        response.External.CRM.Opportunities[i].IngestedAt = time.Now()
+// onError devuelve la política ante fallos de un origen
+func (e *Extractor) onError(name string) string {
+    for _, sc := range e.cfg.Sources {
+        if sc.Name == name && sc.OnError != "" {
+            return sc.OnError
+        }
     }
+    if e.cfg.SourceOnError != "" {
+        return e.cfg.SourceOnError
+    }
+    return OnErrorAbort
+}
 
-    return response.External.CRM.Opportunities, nil
+// extraction es lo que queda de extraer un origen además de sus páginas
+type extraction struct {
+    fetches []FetchReport
+    // watermark es el valor enviado al upstream y nextWatermark la marca
+    // alcanzada (vacía si el origen no es incremental o no trajo nada)
+    watermark     string
+    nextWatermark string
+}
+
+// extractSource descarga y decodifica un origen, página a página si es un
+// PagedSource, y entrega cada página a emit. En un origen incremental envía
+// watermark en cada petición. Devuelve siempre los intentos de descarga
+// hechos, también si falla.
+func (e *Extractor) extractSource(ctx context.Context, src Source, watermark string, emit func(*Records) error) (extraction, error) {
+    var result extraction
+    incremental, isIncremental := e.Incremental(src.Name())
+    tracker := &watermarkTracker{config: incremental}
+    if isIncremental && watermark != "" {
+        param, err := watermarkParam(incremental, watermark)
+        if err != nil {
+            return result, err
+        }
+        result.watermark = param
+    }
+
+    b := e.breakerFor(src.Name())
+    fetch := func(ctx context.Context, url string) ([]byte, error) {
+        if result.watermark != "" {
+            withWatermark, err := withQuery(url, incremental.Param, result.watermark)
+            if err != nil {
+                return nil, err
+            }
+            url = withWatermark
+        }
+        body, attempts, err := e.fetchWithRetry(ctx, b, url)
+        result.fetches = append(result.fetches, FetchReport{Source: src.Name(), URL: url, Attempts: attempts})
+        if err == nil && isIncremental {
+            tracker.observeBody(body)
+        }
+        return body, err
+    }
+
+    stamp := func(records *Records) error {
+        now := time.Now()
+        for i := range records.Ads {
+            records.Ads[i].IngestedAt = now
+        }
+        for i := range records.CRM {
+            records.CRM[i].IngestedAt = now
+        }
+        for i := range records.Rejected {
+            records.Rejected[i].Source = src.Name()
+        }
+        if isIncremental {
+            tracker.observe(records)
+        }
+        return emit(records)
+    }
+
+    var err error
+    if paged, ok := src.(PagedSource); ok {
+        err = paged.FetchPages(ctx, fetch, stamp)
+    } else {
+        err = fetchOnce(ctx, src, fetch, stamp)
+    }
+    if err == nil {
+        result.nextWatermark = tracker.next()
+    }
+    return result, err
+}
+
+// fetchOnce descarga y decodifica un Source con una única petición
+func fetchOnce(ctx context.Context, src Source, fetch Fetcher, emit func(*Records) error) error {
+    body, err := src.Fetch(ctx, fetch)
+    if err != nil {
+        return err
+    }
+    records, err := src.Decode(body)
+    if err != nil {
+        return err
+    }
+    return emit(records)
 }
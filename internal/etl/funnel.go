@@ -0,0 +1,72 @@
+package etl
+
+import "admira-etl/internal/models"
+
+// Pasos del funnel en orden
+const (
+    FunnelImpressions   = "impressions"
+    FunnelClicks        = "clicks"
+    FunnelLeads         = "leads"
+    FunnelOpportunities = "opportunities"
+    FunnelClosedWon     = "closed_won"
+)
+
+// FunnelStep es un paso del funnel con su conversión respecto al paso anterior
+// (StepRate) y respecto al primero (CumulativeRate)
+type FunnelStep struct {
+    Name           string  `json:"name"`
+    Count          float64 `json:"count"`
+    StepRate       float64 `json:"step_rate"`
+    CumulativeRate float64 `json:"cumulative_rate"`
+}
+
+// Funnel resume unas métricas agregadas como impressions → clicks → leads →
+// opportunities → closed_won
+type Funnel struct {
+    Steps   []FunnelStep `json:"steps"`
+    Revenue float64      `json:"revenue"`
+    Cost    float64      `json:"cost"`
+    ROAS    float64      `json:"roas"`
+}
+
+// BuildFunnel construye el funnel a partir de una fila de métricas agregada
+func BuildFunnel(m models.Metrics) Funnel {
+    counts := []struct {
+        name  string
+        count float64
+    }{
+        {FunnelImpressions, float64(m.Impressions)},
+        {FunnelClicks, float64(m.Clicks)},
+        {FunnelLeads, m.Leads},
+        {FunnelOpportunities, m.Opportunities},
+        {FunnelClosedWon, m.ClosedWon},
+    }
+
+    funnel := Funnel{
+        Steps:   make([]FunnelStep, len(counts)),
+        Revenue: m.Revenue,
+        Cost:    m.Cost,
+    }
+    if m.Cost > 0 {
+        funnel.ROAS = m.Revenue / m.Cost
+    }
+
+    for i, c := range counts {
+        step := FunnelStep{Name: c.name, Count: c.count}
+        if i == 0 {
+            if c.count > 0 {
+                step.StepRate = 1
+                step.CumulativeRate = 1
+            }
+        } else {
+            if previous := counts[i-1].count; previous > 0 {
+                step.StepRate = c.count / previous
+            }
+            if first := counts[0].count; first > 0 {
+                step.CumulativeRate = c.count / first
+            }
+        }
+        funnel.Steps[i] = step
+    }
+    return funnel
+}
@@ -0,0 +1,143 @@
+package etl
+
+import (
+    "fmt"
+    "time"
+
+    "admira-etl/pkg/config"
+)
+
+// Tipos de marca de extracción incremental
+const (
+    // IncrementalDate envía el mayor date/created_at de la última extracción correcta
+    IncrementalDate = "date"
+    // IncrementalCursor envía el cursor opaco que devolvió el upstream
+    IncrementalCursor = "cursor"
+)
+
+const defaultWatermarkLayout = "2006-01-02"
+
+// normalizeIncremental valida la extracción incremental de un origen y
+// completa los valores por defecto
+func normalizeIncremental(c config.IncrementalConfig) (config.IncrementalConfig, error) {
+    switch c.Type {
+    case IncrementalDate:
+        if c.Layout == "" {
+            c.Layout = defaultWatermarkLayout
+        }
+        if c.OverlapDays < 0 {
+            return c, fmt.Errorf("overlap_days must not be negative")
+        }
+    case IncrementalCursor:
+        if c.CursorPath == "" {
+            return c, fmt.Errorf("cursor_path is required for cursor watermarks")
+        }
+    default:
+        return c, fmt.Errorf("unknown incremental type %q", c.Type)
+    }
+    if c.Param == "" {
+        return c, fmt.Errorf("incremental param is required")
+    }
+    return c, nil
+}
+
+// ParseWatermark valida una marca fijada a mano y la devuelve tal y como se
+// guarda: las fechas (YYYY-MM-DD o RFC3339) en RFC3339 UTC y los cursores sin
+// cambios
+func ParseWatermark(kind, value string) (string, error) {
+    if kind != IncrementalDate || value == "" {
+        return value, nil
+    }
+    t, err := time.Parse(time.RFC3339, value)
+    if err != nil {
+        if t, err = time.Parse("2006-01-02", value); err != nil {
+            return "", fmt.Errorf("invalid date watermark %q: use YYYY-MM-DD or RFC3339", value)
+        }
+    }
+    return formatWatermark(t), nil
+}
+
+func formatWatermark(t time.Time) string {
+    return t.UTC().Format(time.RFC3339)
+}
+
+// watermarkParam devuelve el valor que se envía al upstream para una marca guardada
+func watermarkParam(c config.IncrementalConfig, watermark string) (string, error) {
+    if c.Type != IncrementalDate {
+        return watermark, nil
+    }
+    t, err := time.Parse(time.RFC3339, watermark)
+    if err != nil {
+        return "", fmt.Errorf("invalid date watermark %q: %v", watermark, err)
+    }
+    return t.AddDate(0, 0, -c.OverlapDays).UTC().Format(c.Layout), nil
+}
+
+// watermarkTracker sigue la marca que alcanza la extracción de un origen
+type watermarkTracker struct {
+    config config.IncrementalConfig
+    latest time.Time
+    cursor string
+}
+
+// observe registra las fechas de una página de registros
+func (w *watermarkTracker) observe(records *Records) {
+    if w.config.Type != IncrementalDate {
+        return
+    }
+    for _, ad := range records.Ads {
+        if date, err := time.Parse("2006-01-02", ad.Date); err == nil && date.After(w.latest) {
+            w.latest = date
+        }
+    }
+    for _, crm := range records.CRM {
+        if crm.CreatedAt.After(w.latest) {
+            w.latest = crm.CreatedAt
+        }
+    }
+}
+
+// observeBody registra el cursor incremental de una respuesta del upstream
+func (w *watermarkTracker) observeBody(body []byte) {
+    if w.config.Type != IncrementalCursor {
+        return
+    }
+    if cursor, found, err := lookupPath(body, w.config.CursorPath); err == nil && found {
+        w.cursor = cursor
+    }
+}
+
+// next devuelve la marca alcanzada, vacía si no se extrajo nada
+func (w *watermarkTracker) next() string {
+    if w.config.Type == IncrementalCursor {
+        return w.cursor
+    }
+    if w.latest.IsZero() {
+        return ""
+    }
+    return formatWatermark(w.latest)
+}
+
+// CompleteFrom devuelve el primer día (YYYY-MM-DD) que una extracción con la
+// marca indicada descarga entero. Con una marca de fecha con hora el día de la
+// marca llega a medias; los cursores y las extracciones sin marca devuelven
+// vacío: todos los días llegan enteros. c es la configuración normalizada que
+// devuelve Extractor.Incremental.
+func CompleteFrom(c config.IncrementalConfig, watermark string) (string, error) {
+    if c.Type != IncrementalDate || watermark == "" {
+        return "", nil
+    }
+    param, err := watermarkParam(c, watermark)
+    if err != nil {
+        return "", err
+    }
+    sent, err := time.Parse(c.Layout, param)
+    if err != nil {
+        return "", fmt.Errorf("invalid watermark param %q: %v", param, err)
+    }
+    day := time.Date(sent.Year(), sent.Month(), sent.Day(), 0, 0, 0, 0, sent.Location())
+    if !sent.Equal(day) {
+        day = day.AddDate(0, 0, 1)
+    }
+    return day.Format("2006-01-02"), nil
+}
@@ -0,0 +1,151 @@
+package etl
+
+import (
+    "encoding/json"
+    "fmt"
+    "net/url"
+    "os"
+    "strings"
+
+    "admira-etl/internal/models"
+)
+
+// Campos UTM que normaliza el UTMNormalizer
+const (
+    FieldUTMCampaign = "utm_campaign"
+    FieldUTMSource   = "utm_source"
+    FieldUTMMedium   = "utm_medium"
+)
+
+// Reglas de normalización, en orden de aplicación
+const (
+    RuleURLDecode = "url_decode"
+    RuleTrim      = "trim"
+    RuleLowercase = "lowercase"
+    RuleAlias     = "alias"
+)
+
+// NormalizationStats cuenta cuántos valores reescribió cada regla
+type NormalizationStats map[string]int
+
+// UTMNormalizer limpia los valores UTM antes de agrupar por MetricKey para que
+// variantes como " Back_To_School", "back%5Fto%5Fschool" o un alias heredado
+// caigan en la misma fila
+type UTMNormalizer struct {
+    URLDecode bool
+    Trim      bool
+    Lowercase bool
+    // Aliases mapea campo -> valor heredado -> valor canónico
+    Aliases map[string]map[string]string
+}
+
+func DefaultUTMNormalizer() *UTMNormalizer {
+    return &UTMNormalizer{
+        URLDecode: true,
+        Trim:      true,
+        Lowercase: true,
+        Aliases:   make(map[string]map[string]string),
+    }
+}
+
+// LoadUTMAliases lee la tabla de alias de un fichero JSON:
+// {"utm_campaign": {"bts": "back_to_school"}, "utm_source": {"fb": "facebook"}}.
+// Se instala con SetAliases para que las claves se normalicen como la entrada.
+func LoadUTMAliases(path string) (map[string]map[string]string, error) {
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return nil, fmt.Errorf("failed to read UTM aliases: %v", err)
+    }
+
+    var aliases map[string]map[string]string
+    if err := json.Unmarshal(data, &aliases); err != nil {
+        return nil, fmt.Errorf("failed to parse UTM aliases: %v", err)
+    }
+
+    for field := range aliases {
+        switch field {
+        case FieldUTMCampaign, FieldUTMSource, FieldUTMMedium:
+        default:
+            return nil, fmt.Errorf("unknown UTM field in aliases: %s", field)
+        }
+    }
+    return aliases, nil
+}
+
+// SetAliases instala la tabla de alias aplicando a sus claves y valores
+// canónicos las mismas reglas que a la entrada (URL-decode, trim, minúsculas),
+// ya que la búsqueda se hace sobre el valor ya normalizado
+func (n *UTMNormalizer) SetAliases(aliases map[string]map[string]string) {
+    rules := *n
+    rules.Aliases = nil
+
+    n.Aliases = make(map[string]map[string]string, len(aliases))
+    for field, table := range aliases {
+        normalized := make(map[string]string, len(table))
+        for legacy, canonical := range table {
+            normalized[rules.Normalize(field, legacy, nil)] = rules.Normalize(field, canonical, nil)
+        }
+        n.Aliases[field] = normalized
+    }
+}
+
+// Normalize aplica las reglas a un valor y suma en stats las reescrituras
+func (n *UTMNormalizer) Normalize(field, value string, stats NormalizationStats) string {
+    apply := func(rule string, fn func(string) string) {
+        if rewritten := fn(value); rewritten != value {
+            value = rewritten
+            if stats != nil {
+                stats[rule]++
+            }
+        }
+    }
+
+    if n.URLDecode {
+        apply(RuleURLDecode, func(v string) string {
+            decoded, err := url.QueryUnescape(v)
+            if err != nil {
+                return v
+            }
+            return decoded
+        })
+    }
+    if n.Trim {
+        apply(RuleTrim, strings.TrimSpace)
+    }
+    if n.Lowercase {
+        apply(RuleLowercase, strings.ToLower)
+    }
+    if aliases := n.Aliases[field]; len(aliases) > 0 {
+        apply(RuleAlias, func(v string) string {
+            if canonical, ok := aliases[v]; ok {
+                return canonical
+            }
+            return v
+        })
+    }
+    return value
+}
+
+// NormalizeAds devuelve una copia de los registros de Ads con los UTMs normalizados
+func (n *UTMNormalizer) NormalizeAds(adsData []models.AdsPerformance, stats NormalizationStats) []models.AdsPerformance {
+    result := make([]models.AdsPerformance, len(adsData))
+    for i, ad := range adsData {
+        ad.UTMCampaign = n.Normalize(FieldUTMCampaign, ad.UTMCampaign, stats)
+        ad.UTMSource = n.Normalize(FieldUTMSource, ad.UTMSource, stats)
+        ad.UTMMedium = n.Normalize(FieldUTMMedium, ad.UTMMedium, stats)
+        result[i] = ad
+    }
+    return result
+}
+
+// NormalizeCRM devuelve una copia de los registros CRM con los UTMs normalizados
+func (n *UTMNormalizer) NormalizeCRM(crmData []models.CRMOpportunity, stats NormalizationStats) []models.CRMOpportunity {
+    result := make([]models.CRMOpportunity, len(crmData))
+    for i, crm := range crmData {
+        crm.UTMCampaign = n.Normalize(FieldUTMCampaign, crm.UTMCampaign, stats)
+        crm.UTMSource = n.Normalize(FieldUTMSource, crm.UTMSource, stats)
+        crm.UTMMedium = n.Normalize(FieldUTMMedium, crm.UTMMedium, stats)
+        result[i] = crm
+    }
+    return result
+}
@@ -0,0 +1,211 @@
+package etl
+
+import (
+    "bytes"
+    "context"
+    "encoding/json"
+    "fmt"
+    "net/url"
+    "strconv"
+    "strings"
+
+    "admira-etl/pkg/config"
+)
+
+// Tipos de paginación de un upstream
+const (
+    // PaginationPage pide page=1, 2, ... hasta una página incompleta o vacía
+    PaginationPage = "page"
+    // PaginationOffset pide offset=0, n, 2n, ... hasta una página incompleta o vacía
+    PaginationOffset = "offset"
+    // PaginationCursor envía el cursor que devuelve cada página hasta que no hay más
+    PaginationCursor = "cursor"
+    // PaginationNextLink sigue la URL de la siguiente página que indica la respuesta
+    PaginationNextLink = "next_link"
+)
+
+// DefaultMaxPages es el límite de páginas si no se configura ninguno
+const DefaultMaxPages = 100
+
+// normalizePagination valida la paginación de un origen y completa los valores por defecto
+func normalizePagination(p config.PaginationConfig) (config.PaginationConfig, error) {
+    switch p.Type {
+    case PaginationPage:
+        if p.PageParam == "" {
+            p.PageParam = "page"
+        }
+        if p.StartPage == 0 {
+            p.StartPage = 1
+        }
+    case PaginationOffset:
+        if p.OffsetParam == "" {
+            p.OffsetParam = "offset"
+        }
+    case PaginationCursor:
+        if p.CursorParam == "" {
+            p.CursorParam = "cursor"
+        }
+        if p.CursorPath == "" {
+            p.CursorPath = "next_cursor"
+        }
+    case PaginationNextLink:
+        if p.NextPath == "" {
+            p.NextPath = "next"
+        }
+    default:
+        return p, fmt.Errorf("unknown pagination type %q", p.Type)
+    }
+
+    if p.PageSize < 0 || p.MaxPages < 0 {
+        return p, fmt.Errorf("page_size and max_pages must not be negative")
+    }
+    if p.PageSize > 0 && p.SizeParam == "" {
+        p.SizeParam = "limit"
+    }
+    if p.MaxPages == 0 {
+        p.MaxPages = DefaultMaxPages
+    }
+    return p, nil
+}
+
+// fetchPages recorre las páginas de un upstream. Cada respuesta se decodifica y
+// se entrega a emit antes de pedir la siguiente, de modo que nunca se retiene
+// más de una página.
+func fetchPages(ctx context.Context, fetch Fetcher, baseURL string, p config.PaginationConfig, decode DecodeFunc, emit func(*Records) error) error {
+    pageURL, err := firstPageURL(baseURL, p)
+    if err != nil {
+        return err
+    }
+
+    offset := 0
+    seen := make(map[string]bool)
+    for page := 0; ; page++ {
+        if page >= p.MaxPages {
+            return fmt.Errorf("max pages (%d) reached before the last page", p.MaxPages)
+        }
+        if seen[pageURL] {
+            return fmt.Errorf("pagination did not advance: %s requested twice", pageURL)
+        }
+        seen[pageURL] = true
+
+        body, err := fetch(ctx, pageURL)
+        if err != nil {
+            return err
+        }
+        records, err := decode(body)
+        if err != nil {
+            return fmt.Errorf("page %d: %v", page+1, err)
+        }
+        count := len(records.Ads) + len(records.CRM) + len(records.Rejected)
+        if err := emit(records); err != nil {
+            return err
+        }
+
+        var next string
+        switch p.Type {
+        case PaginationPage:
+            if lastPage(count, p.PageSize) {
+                return nil
+            }
+            next, err = withQuery(pageURL, p.PageParam, strconv.Itoa(p.StartPage+page+1))
+        case PaginationOffset:
+            if lastPage(count, p.PageSize) {
+                return nil
+            }
+            offset += count
+            next, err = withQuery(pageURL, p.OffsetParam, strconv.Itoa(offset))
+        case PaginationCursor:
+            cursor, found, lookupErr := lookupPath(body, p.CursorPath)
+            if lookupErr != nil || !found {
+                return lookupErr
+            }
+            next, err = withQuery(pageURL, p.CursorParam, cursor)
+        case PaginationNextLink:
+            link, found, lookupErr := lookupPath(body, p.NextPath)
+            if lookupErr != nil || !found {
+                return lookupErr
+            }
+            next, err = resolveURL(pageURL, link)
+        }
+        if err != nil {
+            return err
+        }
+        pageURL = next
+    }
+}
+
+// lastPage indica si una página con count registros es la última: vacía o,
+// con tamaño de página conocido, incompleta
+func lastPage(count, pageSize int) bool {
+    return count == 0 || (pageSize > 0 && count < pageSize)
+}
+
+func firstPageURL(baseURL string, p config.PaginationConfig) (string, error) {
+    pageURL := baseURL
+    var err error
+    switch p.Type {
+    case PaginationPage:
+        pageURL, err = withQuery(pageURL, p.PageParam, strconv.Itoa(p.StartPage))
+    case PaginationOffset:
+        pageURL, err = withQuery(pageURL, p.OffsetParam, "0")
+    }
+    if err != nil || p.PageSize == 0 || p.Type == PaginationNextLink {
+        return pageURL, err
+    }
+    return withQuery(pageURL, p.SizeParam, strconv.Itoa(p.PageSize))
+}
+
+// withQuery devuelve rawURL con el parámetro de consulta key fijado a value
+func withQuery(rawURL, key, value string) (string, error) {
+    u, err := url.Parse(rawURL)
+    if err != nil {
+        return "", err
+    }
+    query := u.Query()
+    query.Set(key, value)
+    u.RawQuery = query.Encode()
+    return u.String(), nil
+}
+
+// resolveURL resuelve un enlace de la respuesta, que puede ser relativo a la página actual
+func resolveURL(pageURL, link string) (string, error) {
+    base, err := url.Parse(pageURL)
+    if err != nil {
+        return "", err
+    }
+    ref, err := url.Parse(link)
+    if err != nil {
+        return "", fmt.Errorf("invalid next link %q: %v", link, err)
+    }
+    return base.ResolveReference(ref).String(), nil
+}
+
+// lookupPath busca en un JSON el valor de una ruta separada por puntos. Un
+// valor ausente, nulo o vacío se considera el final de la paginación.
+func lookupPath(body []byte, path string) (string, bool, error) {
+    decoder := json.NewDecoder(bytes.NewReader(body))
+    decoder.UseNumber()
+
+    var value interface{}
+    if err := decoder.Decode(&value); err != nil {
+        return "", false, err
+    }
+    for _, key := range strings.Split(path, ".") {
+        object, ok := value.(map[string]interface{})
+        if !ok {
+            return "", false, nil
+        }
+        value = object[key]
+    }
+
+    switch v := value.(type) {
+    case string:
+        return v, v != "", nil
+    case json.Number:
+        return v.String(), true, nil
+    case nil:
+        return "", false, nil
+    default:
+        return "", false, fmt.Errorf("pagination field %s is not a string or number", path)
+    }
+}
@@ -0,0 +1,189 @@
+package etl
+
+import (
+    "fmt"
+    "sort"
+    "strings"
+    "time"
+
+    "admira-etl/internal/models"
+)
+
+// Dimension es un campo de models.Metrics por el que se puede filtrar o agrupar
+type Dimension string
+
+const (
+    DimensionDate        Dimension = "date"
+    DimensionChannel     Dimension = "channel"
+    DimensionCampaignID  Dimension = "campaign_id"
+    DimensionUTMCampaign Dimension = "utm_campaign"
+    DimensionUTMSource   Dimension = "utm_source"
+    DimensionUTMMedium   Dimension = "utm_medium"
+)
+
+// Dimensions devuelve todas las dimensiones en el orden de MetricKey
+func Dimensions() []Dimension {
+    return []Dimension{
+        DimensionDate,
+        DimensionChannel,
+        DimensionCampaignID,
+        DimensionUTMCampaign,
+        DimensionUTMSource,
+        DimensionUTMMedium,
+    }
+}
+
+func ParseDimension(value string) (Dimension, error) {
+    for _, d := range Dimensions() {
+        if string(d) == value {
+            return d, nil
+        }
+    }
+    return "", fmt.Errorf("unknown dimension %q", value)
+}
+
+// ParseDimensions acepta una lista de dimensiones sin repetir
+func ParseDimensions(values []string) ([]Dimension, error) {
+    var result []Dimension
+    seen := make(map[Dimension]bool)
+    for _, value := range values {
+        d, err := ParseDimension(value)
+        if err != nil {
+            return nil, err
+        }
+        if seen[d] {
+            return nil, fmt.Errorf("duplicated dimension %q", value)
+        }
+        seen[d] = true
+        result = append(result, d)
+    }
+    return result, nil
+}
+
+// Value devuelve el valor de la dimensión en una fila de métricas
+func (d Dimension) Value(m models.Metrics) string {
+    switch d {
+    case DimensionDate:
+        return m.Date
+    case DimensionChannel:
+        return m.Channel
+    case DimensionCampaignID:
+        return m.CampaignID
+    case DimensionUTMCampaign:
+        return m.UTMCampaign
+    case DimensionUTMSource:
+        return m.UTMSource
+    case DimensionUTMMedium:
+        return m.UTMMedium
+    }
+    return ""
+}
+
+func (d Dimension) set(m *models.Metrics, value string) {
+    switch d {
+    case DimensionDate:
+        m.Date = value
+    case DimensionChannel:
+        m.Channel = value
+    case DimensionCampaignID:
+        m.CampaignID = value
+    case DimensionUTMCampaign:
+        m.UTMCampaign = value
+    case DimensionUTMSource:
+        m.UTMSource = value
+    case DimensionUTMMedium:
+        m.UTMMedium = value
+    }
+}
+
+// MetricsQuery describe una consulta de métricas: filtros por dimensión y
+// rango de fechas, y las dimensiones por las que agregar
+type MetricsQuery struct {
+    // From y To delimitan el rango de fechas (inclusive); cero no limita
+    From time.Time
+    To   time.Time
+    // Filters admite varios valores por dimensión (OR dentro, AND entre dimensiones)
+    Filters map[Dimension][]string
+    GroupBy []Dimension
+}
+
+// Match indica si una fila cumple los filtros de la consulta
+func (q MetricsQuery) Match(m models.Metrics) bool {
+    if !q.From.IsZero() || !q.To.IsZero() {
+        date, err := time.Parse("2006-01-02", m.Date)
+        if err != nil {
+            return false
+        }
+        if !q.From.IsZero() && date.Before(q.From) {
+            return false
+        }
+        if !q.To.IsZero() && date.After(q.To) {
+            return false
+        }
+    }
+
+    for dimension, values := range q.Filters {
+        if len(values) == 0 {
+            continue
+        }
+        value := dimension.Value(m)
+        matched := false
+        for _, v := range values {
+            if v == value {
+                matched = true
+                break
+            }
+        }
+        if !matched {
+            return false
+        }
+    }
+    return true
+}
+
+// Aggregate suma los contadores base por las dimensiones de groupBy y recalcula
+// las métricas derivadas sobre los totales. Las dimensiones no agrupadas quedan
+// vacías; sin groupBy devuelve una única fila con el total.
+func Aggregate(metrics []models.Metrics, groupBy []Dimension) []models.Metrics {
+    groups := make(map[string]*models.Metrics)
+    var keys []string
+
+    for _, metric := range metrics {
+        values := make([]string, len(groupBy))
+        for i, d := range groupBy {
+            values[i] = d.Value(metric)
+        }
+        key := strings.Join(values, "\x00")
+
+        group, exists := groups[key]
+        if !exists {
+            group = &models.Metrics{}
+            for i, d := range groupBy {
+                d.set(group, values[i])
+            }
+            groups[key] = group
+            keys = append(keys, key)
+        }
+        AddCounters(group, metric)
+    }
+
+    sort.Strings(keys)
+    result := make([]models.Metrics, 0, len(keys))
+    for _, key := range keys {
+        group := groups[key]
+        CalculateDerivedMetrics(group)
+        result = append(result, *group)
+    }
+    return result
+}
+
+// AddCounters suma los contadores base de src en dst sin tocar las derivadas
+func AddCounters(dst *models.Metrics, src models.Metrics) {
+    dst.Clicks += src.Clicks
+    dst.Impressions += src.Impressions
+    dst.Cost += src.Cost
+    dst.Leads += src.Leads
+    dst.Opportunities += src.Opportunities
+    dst.ClosedWon += src.ClosedWon
+    dst.Revenue += src.Revenue
+}
@@ -0,0 +1,340 @@
+package etl
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "sync"
+
+    "admira-etl/internal/models"
+    "admira-etl/internal/retry"
+    "admira-etl/pkg/config"
+)
+
+// Tipos de registro que produce un Source
+const (
+    RecordKindAds = "ads"
+    RecordKindCRM = "crm"
+)
+
+// Records agrupa los registros normalizados que produce un Source
+type Records struct {
+    Ads []models.AdsPerformance
+    CRM []models.CRMOpportunity
+    // Rejected son los registros que no pasaron la validación y van a cuarentena
+    Rejected []RejectedRecord
+    // Fetches son las descargas hechas para obtener los registros
+    Fetches []FetchReport
+    // Sources resume la extracción de cada origen y Partial indica que alguno
+    // falló o se canceló y los registros están incompletos
+    Sources []SourceReport
+    Partial bool
+}
+
+// Políticas ante el fallo de un origen en ExtractAll
+const (
+    // OnErrorAbort cancela la extracción del resto de orígenes y falla
+    OnErrorAbort = "abort"
+    // OnErrorContinue sigue con el resto de orígenes y marca el resultado como parcial
+    OnErrorContinue = "continue"
+)
+
+// Estados de un origen en ExtractAll
+const (
+    SourceSucceeded = "succeeded"
+    SourceFailed    = "failed"
+    SourceCanceled  = "canceled"
+)
+
+// SourceReport es el resultado de la extracción de un origen
+type SourceReport struct {
+    Source     string `json:"source"`
+    Status     string `json:"status"`
+    OnError    string `json:"on_error"`
+    Pages      int    `json:"pages"`
+    Records    int    `json:"records"`
+    DurationMs int64  `json:"duration_ms"`
+    Error      string `json:"error,omitempty"`
+    // Watermark es la marca enviada al upstream y NextWatermark la alcanzada,
+    // que se guarda solo si el origen termina bien
+    Watermark     string `json:"watermark,omitempty"`
+    NextWatermark string `json:"next_watermark,omitempty"`
+}
+
+// FetchReport recoge los intentos de descarga de una URL
+type FetchReport struct {
+    Source   string          `json:"source"`
+    URL      string          `json:"url"`
+    Attempts []retry.Attempt `json:"attempts"`
+}
+
+// RejectedRecord conserva el JSON original de un registro inválido y el motivo
+type RejectedRecord struct {
+    Source string
+    Kind   string
+    Raw    json.RawMessage
+    Reason string
+}
+
+// Merge añade los registros de otro lote
+func (r *Records) Merge(other *Records) {
+    if other == nil {
+        return
+    }
+    r.Ads = append(r.Ads, other.Ads...)
+    r.CRM = append(r.CRM, other.CRM...)
+    r.Rejected = append(r.Rejected, other.Rejected...)
+    r.Fetches = append(r.Fetches, other.Fetches...)
+    r.Sources = append(r.Sources, other.Sources...)
+    r.Partial = r.Partial || other.Partial
+}
+
+// Fetcher descarga el contenido de una URL upstream
+type Fetcher func(ctx context.Context, url string) ([]byte, error)
+
+// Source representa un origen de datos upstream (plataforma de ads, CRM, ...)
+type Source interface {
+    Name() string
+    Fetch(ctx context.Context, fetch Fetcher) ([]byte, error)
+    Decode(body []byte) (*Records, error)
+}
+
+// PagedSource es un Source cuyos registros llegan en varias páginas. FetchPages
+// decodifica cada página y la entrega a emit antes de pedir la siguiente, de
+// modo que no se retienen todas las respuestas a la vez.
+type PagedSource interface {
+    Source
+    FetchPages(ctx context.Context, fetch Fetcher, emit func(*Records) error) error
+}
+
+// DecodeFunc convierte la respuesta cruda de un upstream en registros normalizados
+type DecodeFunc func(body []byte) (*Records, error)
+
+var (
+    decodersMu sync.RWMutex
+    decoders   = map[string]DecodeFunc{
+        "ads": DecodeAdsResponse,
+        "crm": DecodeCRMResponse,
+    }
+)
+
+// RegisterDecoder permite añadir formatos de respuesta para nuevos orígenes
+func RegisterDecoder(format string, fn DecodeFunc) {
+    decodersMu.Lock()
+    defer decodersMu.Unlock()
+    decoders[format] = fn
+}
+
+func lookupDecoder(format string) (DecodeFunc, bool) {
+    decodersMu.RLock()
+    defer decodersMu.RUnlock()
+    fn, ok := decoders[format]
+    return fn, ok
+}
+
+// DecodeAdsResponse decodifica el formato de respuesta de la API de Ads.
+// Cada fila se decodifica por separado para que una inválida no tumbe el lote.
+func DecodeAdsResponse(body []byte) (*Records, error) {
+    var response struct {
+        External struct {
+            Ads struct {
+                Performance []json.RawMessage `json:"performance"`
+            } `json:"ads"`
+        } `json:"external"`
+    }
+    if err := json.Unmarshal(body, &response); err != nil {
+        return nil, err
+    }
+    return decodeRawRecords(RecordKindAds, response.External.Ads.Performance), nil
+}
+
+// DecodeCRMResponse decodifica el formato de respuesta de la API de CRM
+func DecodeCRMResponse(body []byte) (*Records, error) {
+    var response struct {
+        External struct {
+            CRM struct {
+                Opportunities []json.RawMessage `json:"opportunities"`
+            } `json:"crm"`
+        } `json:"external"`
+    }
+    if err := json.Unmarshal(body, &response); err != nil {
+        return nil, err
+    }
+    return decodeRawRecords(RecordKindCRM, response.External.CRM.Opportunities), nil
+}
+
+func decodeRawRecords(kind string, raws []json.RawMessage) *Records {
+    records := &Records{}
+    for _, raw := range raws {
+        decoded, err := DecodeRecord(kind, raw)
+        if err != nil {
+            records.Rejected = append(records.Rejected, RejectedRecord{
+                Kind:   kind,
+                Raw:    raw,
+                Reason: err.Error(),
+            })
+            continue
+        }
+        records.Merge(decoded)
+    }
+    return records
+}
+
+// DecodeRecord decodifica y valida un único registro del tipo indicado
+func DecodeRecord(kind string, raw json.RawMessage) (*Records, error) {
+    switch kind {
+    case RecordKindAds:
+        var ad models.AdsPerformance
+        if err := json.Unmarshal(raw, &ad); err != nil {
+            return nil, err
+        }
+        if err := ad.Validate(); err != nil {
+            return nil, err
+        }
+        return &Records{Ads: []models.AdsPerformance{ad}}, nil
+    case RecordKindCRM:
+        var crm models.CRMOpportunity
+        if err := json.Unmarshal(raw, &crm); err != nil {
+            return nil, err
+        }
+        return &Records{CRM: []models.CRMOpportunity{crm}}, nil
+    default:
+        return nil, fmt.Errorf("unknown record kind: %s", kind)
+    }
+}
+
+// HTTPSource es un Source que descarga una URL y la decodifica. Con paginación
+// recorre las páginas del upstream en lugar de hacer una única petición.
+type HTTPSource struct {
+    name       string
+    url        string
+    decode     DecodeFunc
+    pagination *config.PaginationConfig
+}
+
+func NewHTTPSource(name, url string, decode DecodeFunc) *HTTPSource {
+    return &HTTPSource{name: name, url: url, decode: decode}
+}
+
+// NewPagedHTTPSource crea un HTTPSource que recorre las páginas del upstream
+func NewPagedHTTPSource(name, url string, decode DecodeFunc, pagination config.PaginationConfig) (*HTTPSource, error) {
+    normalized, err := normalizePagination(pagination)
+    if err != nil {
+        return nil, err
+    }
+    return &HTTPSource{name: name, url: url, decode: decode, pagination: &normalized}, nil
+}
+
+func (s *HTTPSource) Name() string {
+    return s.name
+}
+
+func (s *HTTPSource) Fetch(ctx context.Context, fetch Fetcher) ([]byte, error) {
+    return fetch(ctx, s.url)
+}
+
+func (s *HTTPSource) Decode(body []byte) (*Records, error) {
+    return s.decode(body)
+}
+
+func (s *HTTPSource) FetchPages(ctx context.Context, fetch Fetcher, emit func(*Records) error) error {
+    if s.pagination == nil {
+        return fetchOnce(ctx, s, fetch, emit)
+    }
+    return fetchPages(ctx, fetch, s.url, *s.pagination, s.decode, emit)
+}
+
+// Registry mantiene los orígenes registrados en orden de registro
+type Registry struct {
+    mu      sync.RWMutex
+    sources map[string]Source
+    order   []string
+}
+
+func NewRegistry() *Registry {
+    return &Registry{
+        sources: make(map[string]Source),
+    }
+}
+
+// NewRegistryFromConfig registra un HTTPSource por cada origen configurado
+func NewRegistryFromConfig(cfg *config.Config) (*Registry, error) {
+    if !validOnError(cfg.SourceOnError) {
+        return nil, fmt.Errorf("unknown SOURCE_ON_ERROR policy %q", cfg.SourceOnError)
+    }
+
+    registry := NewRegistry()
+    for _, sc := range cfg.Sources {
+        decode, ok := lookupDecoder(sc.Format)
+        if !ok {
+            return nil, fmt.Errorf("source %s: unknown format %q", sc.Name, sc.Format)
+        }
+        if !validOnError(sc.OnError) {
+            return nil, fmt.Errorf("source %s: unknown on_error policy %q", sc.Name, sc.OnError)
+        }
+        src := NewHTTPSource(sc.Name, sc.URL, decode)
+        if sc.Pagination != nil {
+            pagination := *sc.Pagination
+            if pagination.MaxPages == 0 {
+                pagination.MaxPages = cfg.ExtractMaxPages
+            }
+            var err error
+            if src, err = NewPagedHTTPSource(sc.Name, sc.URL, decode, pagination); err != nil {
+                return nil, fmt.Errorf("source %s: %v", sc.Name, err)
+            }
+        }
+        if sc.Incremental != nil {
+            if _, err := normalizeIncremental(*sc.Incremental); err != nil {
+                return nil, fmt.Errorf("source %s: %v", sc.Name, err)
+            }
+        }
+        if err := registry.Register(src); err != nil {
+            return nil, err
+        }
+    }
+    return registry, nil
+}
+
+func validOnError(policy string) bool {
+    switch policy {
+    case "", OnErrorAbort, OnErrorContinue:
+        return true
+    }
+    return false
+}
+
+func (r *Registry) Register(src Source) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    name := src.Name()
+    if name == "" {
+        return fmt.Errorf("source name is required")
+    }
+    if _, exists := r.sources[name]; exists {
+        return fmt.Errorf("source %s already registered", name)
+    }
+
+    r.sources[name] = src
+    r.order = append(r.order, name)
+    return nil
+}
+
+func (r *Registry) Get(name string) (Source, bool) {
+    r.mu.RLock()
+    defer r.mu.RUnlock()
+    src, ok := r.sources[name]
+    return src, ok
+}
+
+// Sources devuelve los orígenes en orden de registro
+func (r *Registry) Sources() []Source {
+    r.mu.RLock()
+    defer r.mu.RUnlock()
+
+    result := make([]Source, 0, len(r.order))
+    for _, name := range r.order {
+        result = append(result, r.sources[name])
+    }
+    return result
+}
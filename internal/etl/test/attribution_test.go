@@ -0,0 +1,91 @@
+package test
+
+import (
+    "math"
+    "testing"
+    "time"
+    "admira-etl/internal/etl"
+    "admira-etl/internal/models"
+)
+
+func attributionData() ([]models.AdsPerformance, []models.CRMOpportunity) {
+    created, _ := time.Parse("2006-01-02", "2024-01-05")
+
+    adsData := []models.AdsPerformance{
+        {Date: "2024-01-01", CampaignID: "C-1", Channel: "google_ads", Clicks: 100, Cost: 50, UTMCampaign: "spring", UTMSource: "google", UTMMedium: "cpc"},
+        {Date: "2024-01-04", CampaignID: "C-1", Channel: "google_ads", Clicks: 100, Cost: 50, UTMCampaign: "spring", UTMSource: "google", UTMMedium: "cpc"},
+        // Posterior al registro CRM: nunca recibe crédito
+        {Date: "2024-01-06", CampaignID: "C-1", Channel: "google_ads", Clicks: 100, Cost: 50, UTMCampaign: "spring", UTMSource: "google", UTMMedium: "cpc"},
+    }
+    crmData := []models.CRMOpportunity{
+        {OpportunityID: "O-1", Stage: "closed_won", Amount: 1000, CreatedAt: created, UTMCampaign: "spring", UTMSource: "google", UTMMedium: "cpc"},
+    }
+    return adsData, crmData
+}
+
+func transformWithModel(t *testing.T, model etl.AttributionModel, lookback int) map[string]models.Metrics {
+    adsData, crmData := attributionData()
+
+    options := etl.DefaultTransformOptions()
+    options.Attribution.Model = model
+    options.Attribution.LookbackDays = lookback
+
+    result, err := etl.NewTransformer().TransformWithOptions(adsData, crmData, options)
+    if err != nil {
+        t.Fatalf("Transform failed: %v", err)
+    }
+
+    byDate := make(map[string]models.Metrics)
+    for _, m := range result.Metrics {
+        byDate[m.Date+"/"+m.CampaignID] = m
+    }
+    return byDate
+}
+
+func TestAttribution_LastAndFirstTouch(t *testing.T) {
+    last := transformWithModel(t, etl.AttributionLastTouch, 30)
+    if last["2024-01-04/C-1"].Revenue != 1000 || last["2024-01-01/C-1"].Revenue != 0 {
+        t.Errorf("last_touch should credit 2024-01-04 only, got %+v", last)
+    }
+
+    first := transformWithModel(t, etl.AttributionFirstTouch, 30)
+    if first["2024-01-01/C-1"].Revenue != 1000 || first["2024-01-04/C-1"].Revenue != 0 {
+        t.Errorf("first_touch should credit 2024-01-01 only, got %+v", first)
+    }
+}
+
+func TestAttribution_LinearSplitsCredit(t *testing.T) {
+    metrics := transformWithModel(t, etl.AttributionLinear, 30)
+
+    for _, key := range []string{"2024-01-01/C-1", "2024-01-04/C-1"} {
+        if metrics[key].ClosedWon != 0.5 || metrics[key].Revenue != 500 {
+            t.Errorf("linear should split credit in halves for %s, got %+v", key, metrics[key])
+        }
+    }
+    if metrics["2024-01-06/C-1"].Revenue != 0 {
+        t.Errorf("ads after the CRM record must not receive credit")
+    }
+}
+
+func TestAttribution_TimeDecayFavoursRecentTouches(t *testing.T) {
+    metrics := transformWithModel(t, etl.AttributionTimeDecay, 30)
+
+    older := metrics["2024-01-01/C-1"].Revenue
+    recent := metrics["2024-01-04/C-1"].Revenue
+    if recent <= older {
+        t.Errorf("time_decay should favour recent touches: older %.2f, recent %.2f", older, recent)
+    }
+    if math.Abs(older+recent-1000) > 1e-9 {
+        t.Errorf("time_decay should distribute the full revenue, got %.2f", older+recent)
+    }
+}
+
+func TestAttribution_FallbackOutsideLookback(t *testing.T) {
+    metrics := transformWithModel(t, etl.AttributionLinear, 0)
+
+    // Con ventana 0 no hay touchpoints el mismo día: se crea la fila CRM inferida
+    fallback, ok := metrics["2024-01-05/"]
+    if !ok || fallback.Revenue != 1000 || fallback.Channel != "google_ads" {
+        t.Errorf("Expected unattributed CRM row for 2024-01-05, got %+v", metrics)
+    }
+}
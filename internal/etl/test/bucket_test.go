@@ -0,0 +1,95 @@
+package test
+
+import (
+    "errors"
+    "testing"
+    "time"
+    "admira-etl/internal/etl"
+    "admira-etl/internal/models"
+)
+
+func mustDate(t *testing.T, value string) time.Time {
+    date, err := time.Parse("2006-01-02", value)
+    if err != nil {
+        t.Fatalf("invalid date %s: %v", value, err)
+    }
+    return date
+}
+
+func TestBucketFor_WeeksMonthsQuarters(t *testing.T) {
+    date := mustDate(t, "2025-08-03") // domingo
+
+    cases := []struct {
+        options etl.BucketOptions
+        want    etl.Bucket
+    }{
+        {etl.BucketOptions{Granularity: etl.GranularityDay}, etl.Bucket{Period: "2025-08-03", Start: "2025-08-03", End: "2025-08-03"}},
+        {etl.BucketOptions{Granularity: etl.GranularityWeek, WeekStart: time.Monday}, etl.Bucket{Period: "2025-W31", Start: "2025-07-28", End: "2025-08-03"}},
+        {etl.BucketOptions{Granularity: etl.GranularityWeek, WeekStart: time.Sunday}, etl.Bucket{Period: "2025-08-03", Start: "2025-08-03", End: "2025-08-09"}},
+        {etl.BucketOptions{Granularity: etl.GranularityMonth}, etl.Bucket{Period: "2025-08", Start: "2025-08-01", End: "2025-08-31"}},
+        {etl.BucketOptions{Granularity: etl.GranularityQuarter}, etl.Bucket{Period: "2025-Q3", Start: "2025-07-01", End: "2025-09-30"}},
+    }
+
+    for _, tc := range cases {
+        if got := tc.options.BucketFor(date); got != tc.want {
+            t.Errorf("%s/%s: expected %+v, got %+v", tc.options.Granularity, tc.options.WeekStart, tc.want, got)
+        }
+    }
+}
+
+func TestTimeSeries_ZeroFillsAndRecomputesRatios(t *testing.T) {
+    metrics := []models.Metrics{
+        {Date: "2025-08-04", Channel: "google_ads", Clicks: 100, Cost: 50, Leads: 5},
+        {Date: "2025-08-06", Channel: "google_ads", Clicks: 300, Cost: 150, Leads: 5},
+        {Date: "2025-08-20", Channel: "google_ads", Clicks: 10, Cost: 10, Leads: 1},
+    }
+
+    options := etl.BucketOptions{Granularity: etl.GranularityWeek, WeekStart: time.Monday}
+    series, err := etl.TimeSeries(metrics, []etl.Dimension{etl.DimensionChannel}, mustDate(t, "2025-08-04"), mustDate(t, "2025-08-24"), options)
+    if err != nil {
+        t.Fatalf("TimeSeries failed: %v", err)
+    }
+    if len(series) != 3 {
+        t.Fatalf("Expected 3 weekly buckets, got %d", len(series))
+    }
+
+    first := series[0]
+    if first.Period != "2025-W32" || first.Clicks != 400 || first.CPC != 0.5 || first.CPA != 20 {
+        t.Errorf("Unexpected first bucket: %+v", first)
+    }
+    empty := series[1]
+    if empty.Period != "2025-W33" || empty.Clicks != 0 || empty.Channel != "google_ads" || empty.Date != "2025-08-11" {
+        t.Errorf("Expected zero-filled bucket for W33, got %+v", empty)
+    }
+}
+
+func TestTimeSeries_ZeroFillsFromWithoutMetrics(t *testing.T) {
+    from := time.Now().UTC().AddDate(0, 0, -2)
+    from = time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC)
+
+    series, err := etl.TimeSeries(nil, nil, from, time.Time{}, etl.BucketOptions{Granularity: etl.GranularityDay})
+    if err != nil {
+        t.Fatalf("TimeSeries failed: %v", err)
+    }
+    if len(series) != 3 {
+        t.Fatalf("Expected 3 zero-filled days up to today, got %d", len(series))
+    }
+    if series[0].Date != from.Format("2006-01-02") || series[0].Clicks != 0 {
+        t.Errorf("Unexpected first bucket: %+v", series[0])
+    }
+}
+
+func TestTimeSeries_RejectsTooManyBuckets(t *testing.T) {
+    options := etl.BucketOptions{Granularity: etl.GranularityDay}
+    _, err := etl.TimeSeries(nil, nil, mustDate(t, "2000-01-01"), mustDate(t, "2025-01-01"), options)
+    if !errors.Is(err, etl.ErrTooManyBuckets) {
+        t.Fatalf("Expected ErrTooManyBuckets, got %v", err)
+    }
+
+    // El mismo rango por trimestres sí cabe
+    options.Granularity = etl.GranularityQuarter
+    series, err := etl.TimeSeries(nil, nil, mustDate(t, "2000-01-01"), mustDate(t, "2025-01-01"), options)
+    if err != nil || len(series) != 101 {
+        t.Fatalf("Expected 101 quarters, got %d (%v)", len(series), err)
+    }
+}
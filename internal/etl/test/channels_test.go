@@ -0,0 +1,55 @@
+package test
+
+import (
+    "path/filepath"
+    "testing"
+    "admira-etl/internal/etl"
+)
+
+func TestChannelRules_MatchTypes(t *testing.T) {
+    rules, err := etl.NewChannelRules([]etl.ChannelRule{
+        {Name: "google", Source: &etl.Condition{Type: etl.MatchCaseInsensitive, Value: "google"}, Channel: "google_ads"},
+        {Name: "google_domain", Source: &etl.Condition{Type: etl.MatchPrefix, Value: "google."}, Channel: "google_ads"},
+        {Name: "facebook", Source: &etl.Condition{Type: etl.MatchRegex, Value: "^(facebook|fb)$"}, Channel: "facebook_ads"},
+        {Name: "newsletter", Source: &etl.Condition{Type: etl.MatchExact, Value: "mailchimp"}, Medium: &etl.Condition{Type: etl.MatchExact, Value: "email"}, Channel: "email"},
+    })
+    if err != nil {
+        t.Fatalf("NewChannelRules failed: %v", err)
+    }
+
+    cases := []struct {
+        source, medium, channel, rule string
+    }{
+        {"Google", "cpc", "google_ads", "google"},
+        {"google.com", "referral", "google_ads", "google_domain"},
+        {"fb", "paid_social", "facebook_ads", "facebook"},
+        {"mailchimp", "email", "email", "newsletter"},
+        {"mailchimp", "social", "mailchimp_social", ""},
+    }
+
+    for _, tc := range cases {
+        match := rules.Match(tc.source, tc.medium)
+        if match.Channel != tc.channel || match.Rule != tc.rule {
+            t.Errorf("Match(%q, %q) = %+v, expected channel %s via rule %q", tc.source, tc.medium, match, tc.channel, tc.rule)
+        }
+    }
+}
+
+func TestChannelRules_InvalidRegex(t *testing.T) {
+    _, err := etl.NewChannelRules([]etl.ChannelRule{
+        {Name: "broken", Source: &etl.Condition{Type: etl.MatchRegex, Value: "("}, Channel: "x"},
+    })
+    if err == nil {
+        t.Error("Expected error for invalid regex")
+    }
+}
+
+func TestLoadChannelRules_ExampleFile(t *testing.T) {
+    rules, err := etl.LoadChannelRules(filepath.Join("..", "..", "..", "channel_rules.example.json"))
+    if err != nil {
+        t.Fatalf("LoadChannelRules failed: %v", err)
+    }
+    if channel := rules.Infer("FB", "cpc"); channel != "facebook_ads" {
+        t.Errorf("Expected facebook_ads for FB, got %s", channel)
+    }
+}
@@ -0,0 +1,45 @@
+package test
+
+import (
+    "testing"
+    "admira-etl/internal/etl"
+    "admira-etl/internal/models"
+)
+
+func TestComparisonRange(t *testing.T) {
+    from, to := mustDate(t, "2025-08-04"), mustDate(t, "2025-08-10")
+
+    prevFrom, prevTo := etl.ComparisonRange(from, to, etl.ComparePreviousPeriod)
+    if prevFrom.Format("2006-01-02") != "2025-07-28" || prevTo.Format("2006-01-02") != "2025-08-03" {
+        t.Errorf("Unexpected previous period: %s - %s", prevFrom, prevTo)
+    }
+
+    prevFrom, prevTo = etl.ComparisonRange(from, to, etl.ComparePreviousYear)
+    if prevFrom.Format("2006-01-02") != "2024-08-04" || prevTo.Format("2006-01-02") != "2024-08-10" {
+        t.Errorf("Unexpected previous year: %s - %s", prevFrom, prevTo)
+    }
+}
+
+func TestCompareMetrics_Deltas(t *testing.T) {
+    current := models.Metrics{Clicks: 150, Cost: 100, Leads: 10, Revenue: 0, CPA: 10}
+    previous := models.Metrics{Clicks: 100, Cost: 100, Leads: 0, Revenue: 0, CPA: 0}
+
+    deltas := etl.CompareMetrics(current, previous)
+
+    clicks := deltas["clicks"]
+    if clicks.Absolute != 50 || clicks.Percent == nil || *clicks.Percent != 50 {
+        t.Errorf("Unexpected clicks delta: %+v", clicks)
+    }
+    if deltas["cost"].Absolute != 0 || *deltas["cost"].Percent != 0 {
+        t.Errorf("Unexpected cost delta: %+v", deltas["cost"])
+    }
+    // Sin valor previo no hay porcentaje
+    if deltas["leads"].Percent != nil || deltas["leads"].Absolute != 10 {
+        t.Errorf("Unexpected leads delta: %+v", deltas["leads"])
+    }
+    for _, name := range []string{"clicks", "cost", "leads", "revenue", "cpa", "roas"} {
+        if _, ok := deltas[name]; !ok {
+            t.Errorf("Missing delta for %s", name)
+        }
+    }
+}
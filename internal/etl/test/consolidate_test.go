@@ -0,0 +1,41 @@
+package test
+
+import (
+    "testing"
+    "admira-etl/internal/etl"
+    "admira-etl/internal/models"
+)
+
+func consolidationData() []models.Metrics {
+    return []models.Metrics{
+        {Date: "2025-08-01", Channel: "google_ads", CampaignID: "C-1", UTMCampaign: "bts", UTMSource: "google", UTMMedium: "cpc", Clicks: 10, Cost: 5, Leads: 1},
+        {Date: "2025-08-01", Channel: "google_ads", CampaignID: "C-1", UTMCampaign: "bts", UTMSource: "google", UTMMedium: "display", Clicks: 20, Cost: 15, Leads: 1},
+        {Date: "2025-08-01", Channel: "google_ads", CampaignID: "C-1", UTMCampaign: "bts", UTMSource: "google", UTMMedium: "cpc", Clicks: 5, Cost: 5},
+    }
+}
+
+func TestConsolidate_DefaultKeepsEveryDimension(t *testing.T) {
+    rows, groupBy := etl.Consolidate(consolidationData(), nil)
+
+    if len(groupBy) != len(etl.Dimensions()) {
+        t.Errorf("Expected every MetricKey dimension by default, got %v", groupBy)
+    }
+    // Los mediums distintos no se mezclan; las filas con la misma clave sí
+    if len(rows) != 2 {
+        t.Fatalf("Expected 2 rows, got %+v", rows)
+    }
+    if rows[0].UTMMedium != "cpc" || rows[0].Clicks != 15 || rows[0].CPC != 10.0/15 {
+        t.Errorf("Unexpected cpc row: %+v", rows[0])
+    }
+}
+
+func TestConsolidate_CustomGroupingKeepsDate(t *testing.T) {
+    rows, groupBy := etl.Consolidate(consolidationData(), []etl.Dimension{etl.DimensionChannel})
+
+    if len(groupBy) != 2 || groupBy[0] != etl.DimensionDate || groupBy[1] != etl.DimensionChannel {
+        t.Errorf("Expected date to be prepended, got %v", groupBy)
+    }
+    if len(rows) != 1 || rows[0].Date != "2025-08-01" || rows[0].Clicks != 35 || rows[0].UTMMedium != "" || rows[0].CPA != 12.5 {
+        t.Errorf("Unexpected consolidated row: %+v", rows)
+    }
+}
@@ -0,0 +1,104 @@
+package test
+
+import (
+    "context"
+    "net/http"
+    "net/http/httptest"
+    "testing"
+    "time"
+    "admira-etl/internal/etl"
+    "admira-etl/pkg/config"
+)
+
+const adsBody = `{"external":{"ads":{"performance":[
+    {"date":"2025-08-01","campaign_id":"C-1","channel":"google_ads","clicks":10,"impressions":100,"cost":5,"utm_campaign":"bts","utm_source":"google","utm_medium":"cpc"}
+]}}}`
+
+// upstream responde tras delay con el cuerpo indicado o con status si no es 200
+func upstream(t *testing.T, delay time.Duration, status int) *httptest.Server {
+    server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        select {
+        case <-time.After(delay):
+        case <-r.Context().Done():
+            return
+        }
+        if status != http.StatusOK {
+            w.WriteHeader(status)
+            return
+        }
+        w.Write([]byte(adsBody))
+    }))
+    t.Cleanup(server.Close)
+    return server
+}
+
+func newExtractor(t *testing.T, sources ...config.SourceConfig) *etl.Extractor {
+    cfg := &config.Config{
+        Timeout:                 time.Second,
+        MaxRetries:              1,
+        ExtractConcurrency:      4,
+        BreakerFailureThreshold: 5,
+        Sources:                 sources,
+    }
+    registry, err := etl.NewRegistryFromConfig(cfg)
+    if err != nil {
+        t.Fatalf("NewRegistryFromConfig failed: %v", err)
+    }
+    return etl.NewExtractor(cfg, registry)
+}
+
+func TestExtractAll_FetchesSourcesInParallel(t *testing.T) {
+    first := upstream(t, 150*time.Millisecond, http.StatusOK)
+    second := upstream(t, 150*time.Millisecond, http.StatusOK)
+    extractor := newExtractor(t,
+        config.SourceConfig{Name: "first", Format: "ads", URL: first.URL},
+        config.SourceConfig{Name: "second", Format: "ads", URL: second.URL},
+    )
+
+    start := time.Now()
+    records, err := extractor.ExtractAll(context.Background())
+    if err != nil {
+        t.Fatalf("ExtractAll failed: %v", err)
+    }
+    if elapsed := time.Since(start); elapsed > 280*time.Millisecond {
+        t.Errorf("Expected sources to be fetched in parallel, took %v", elapsed)
+    }
+    if len(records.Ads) != 2 || records.Partial || records.Sources[0].Source != "first" {
+        t.Errorf("Unexpected records: %+v", records)
+    }
+}
+
+func TestExtractAll_ContinuePolicyFlagsPartialData(t *testing.T) {
+    ok := upstream(t, 0, http.StatusOK)
+    broken := upstream(t, 0, http.StatusNotFound)
+    extractor := newExtractor(t,
+        config.SourceConfig{Name: "ok", Format: "ads", URL: ok.URL},
+        config.SourceConfig{Name: "broken", Format: "ads", URL: broken.URL, OnError: etl.OnErrorContinue},
+    )
+
+    records, err := extractor.ExtractAll(context.Background())
+    if err != nil {
+        t.Fatalf("Expected the run to continue, got %v", err)
+    }
+    if !records.Partial || len(records.Ads) != 1 || records.Sources[1].Status != etl.SourceFailed {
+        t.Errorf("Expected partial data flagged, got %+v", records)
+    }
+}
+
+func TestExtractAll_AbortPolicyCancelsOtherSources(t *testing.T) {
+    slow := upstream(t, 5*time.Second, http.StatusOK)
+    broken := upstream(t, 0, http.StatusNotFound)
+    extractor := newExtractor(t,
+        config.SourceConfig{Name: "slow", Format: "ads", URL: slow.URL},
+        config.SourceConfig{Name: "broken", Format: "ads", URL: broken.URL, OnError: etl.OnErrorAbort},
+    )
+
+    start := time.Now()
+    records, err := extractor.ExtractAll(context.Background())
+    if err == nil {
+        t.Fatalf("Expected the run to abort")
+    }
+    if time.Since(start) > time.Second || records.Sources[0].Status != etl.SourceCanceled {
+        t.Errorf("Expected the slow source to be canceled, got %+v after %v", records.Sources, time.Since(start))
+    }
+}
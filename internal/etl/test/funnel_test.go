@@ -0,0 +1,41 @@
+package test
+
+import (
+    "testing"
+    "admira-etl/internal/etl"
+    "admira-etl/internal/models"
+)
+
+func TestBuildFunnel_StepAndCumulativeRates(t *testing.T) {
+    funnel := etl.BuildFunnel(models.Metrics{
+        Impressions: 10000, Clicks: 500, Leads: 50, Opportunities: 10, ClosedWon: 0, Revenue: 0, Cost: 100,
+    })
+
+    expected := []etl.FunnelStep{
+        {Name: etl.FunnelImpressions, Count: 10000, StepRate: 1, CumulativeRate: 1},
+        {Name: etl.FunnelClicks, Count: 500, StepRate: 0.05, CumulativeRate: 0.05},
+        {Name: etl.FunnelLeads, Count: 50, StepRate: 0.1, CumulativeRate: 0.005},
+        {Name: etl.FunnelOpportunities, Count: 10, StepRate: 0.2, CumulativeRate: 0.001},
+        {Name: etl.FunnelClosedWon, Count: 0, StepRate: 0, CumulativeRate: 0},
+    }
+    if len(funnel.Steps) != len(expected) {
+        t.Fatalf("Expected %d steps, got %d", len(expected), len(funnel.Steps))
+    }
+    for i, step := range funnel.Steps {
+        if step != expected[i] {
+            t.Errorf("Step %d: expected %+v, got %+v", i, expected[i], step)
+        }
+    }
+    if funnel.ROAS != 0 || funnel.Cost != 100 {
+        t.Errorf("Unexpected totals: %+v", funnel)
+    }
+}
+
+func TestBuildFunnel_EmptyMetrics(t *testing.T) {
+    funnel := etl.BuildFunnel(models.Metrics{})
+    for _, step := range funnel.Steps {
+        if step.StepRate != 0 || step.CumulativeRate != 0 {
+            t.Errorf("Expected zero rates for empty funnel, got %+v", step)
+        }
+    }
+}
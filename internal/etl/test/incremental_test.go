@@ -0,0 +1,69 @@
+package test
+
+import (
+    "context"
+    "net/http"
+    "strings"
+    "testing"
+    "admira-etl/internal/etl"
+    "admira-etl/pkg/config"
+)
+
+func TestExtractPages_SendsDateWatermarkWithOverlap(t *testing.T) {
+    server, requests := pagedUpstream(t, func(r *http.Request) string {
+        return adsPage(1, "")
+    })
+    extractor := newExtractor(t, config.SourceConfig{
+        Name: "ads", Format: "ads", URL: server.URL,
+        Incremental: &config.IncrementalConfig{Type: etl.IncrementalDate, Param: "since", OverlapDays: 1},
+    })
+
+    summary, err := extractor.ExtractPages(context.Background(), map[string]string{"ads": "2025-07-31T00:00:00Z"}, func(string, *etl.Records) error { return nil })
+    if err != nil {
+        t.Fatalf("ExtractPages failed: %v", err)
+    }
+    if (*requests)[0] != "since=2025-07-30" {
+        t.Errorf("Expected the watermark minus the overlap, got %v", *requests)
+    }
+    report := summary.Sources[0]
+    if report.Watermark != "2025-07-30" || report.NextWatermark != "2025-08-01T00:00:00Z" {
+        t.Errorf("Unexpected watermarks in report: %+v", report)
+    }
+}
+
+func TestExtractPages_CursorWatermarkAcrossPages(t *testing.T) {
+    server, requests := pagedUpstream(t, func(r *http.Request) string {
+        if r.URL.Query().Get("page") == "1" {
+            return adsPage(2, "")
+        }
+        return adsPage(1, `,"sync":{"token":"tok-2"}`)
+    })
+    extractor := newExtractor(t, config.SourceConfig{
+        Name: "ads", Format: "ads", URL: server.URL,
+        Pagination:  &config.PaginationConfig{Type: etl.PaginationPage, PageSize: 2},
+        Incremental: &config.IncrementalConfig{Type: etl.IncrementalCursor, Param: "sync_token", CursorPath: "sync.token"},
+    })
+
+    summary, err := extractor.ExtractPages(context.Background(), map[string]string{"ads": "tok-1"}, func(string, *etl.Records) error { return nil })
+    if err != nil {
+        t.Fatalf("ExtractPages failed: %v", err)
+    }
+    for _, query := range *requests {
+        if !strings.Contains(query, "sync_token=tok-1") {
+            t.Errorf("Expected every page to carry the cursor, got %s", query)
+        }
+    }
+    if summary.Sources[0].NextWatermark != "tok-2" {
+        t.Errorf("Expected next cursor tok-2, got %+v", summary.Sources[0])
+    }
+}
+
+func TestNewRegistryFromConfig_RejectsInvalidIncremental(t *testing.T) {
+    cfg := &config.Config{Sources: []config.SourceConfig{{
+        Name: "ads", Format: "ads", URL: "http://upstream",
+        Incremental: &config.IncrementalConfig{Type: etl.IncrementalCursor, Param: "sync_token"},
+    }}}
+    if _, err := etl.NewRegistryFromConfig(cfg); err == nil {
+        t.Errorf("Expected a cursor watermark without cursor_path to be rejected")
+    }
+}
@@ -0,0 +1,121 @@
+package test
+
+import (
+    "context"
+    "fmt"
+    "net/http"
+    "net/http/httptest"
+    "strconv"
+    "strings"
+    "testing"
+    "admira-etl/internal/etl"
+    "admira-etl/pkg/config"
+)
+
+// adsPage genera una página de Ads con n filas y los campos extra indicados
+func adsPage(n int, extra string) string {
+    rows := make([]string, n)
+    for i := range rows {
+        rows[i] = `{"date":"2025-08-01","campaign_id":"C-1","channel":"google_ads","clicks":1,"impressions":10,"cost":1,"utm_campaign":"bts","utm_source":"google","utm_medium":"cpc"}`
+    }
+    return fmt.Sprintf(`{"external":{"ads":{"performance":[%s]}}%s}`, strings.Join(rows, ","), extra)
+}
+
+func pagedUpstream(t *testing.T, handler func(r *http.Request) string) (*httptest.Server, *[]string) {
+    var requests []string
+    server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        requests = append(requests, r.URL.RawQuery)
+        w.Write([]byte(handler(r)))
+    }))
+    t.Cleanup(server.Close)
+    return server, &requests
+}
+
+func TestExtractPages_PageNumberStopsOnShortPage(t *testing.T) {
+    server, requests := pagedUpstream(t, func(r *http.Request) string {
+        page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+        if page < 3 {
+            return adsPage(2, "")
+        }
+        return adsPage(1, "")
+    })
+    extractor := newExtractor(t, config.SourceConfig{
+        Name: "ads", Format: "ads", URL: server.URL,
+        Pagination: &config.PaginationConfig{Type: etl.PaginationPage, PageSize: 2},
+    })
+
+    var pages []int
+    summary, err := extractor.ExtractPages(context.Background(), nil, func(source string, page *etl.Records) error {
+        pages = append(pages, len(page.Ads))
+        return nil
+    })
+    if err != nil {
+        t.Fatalf("ExtractPages failed: %v", err)
+    }
+    if len(pages) != 3 || pages[2] != 1 {
+        t.Errorf("Expected pages of 2, 2 and 1 records, got %v", pages)
+    }
+    if (*requests)[0] != "limit=2&page=1" || summary.Sources[0].Pages != 3 || summary.Sources[0].Records != 5 {
+        t.Errorf("Unexpected requests %v or report %+v", *requests, summary.Sources[0])
+    }
+}
+
+func TestExtractAll_FollowsCursorAndNextLink(t *testing.T) {
+    cursor, _ := pagedUpstream(t, func(r *http.Request) string {
+        switch r.URL.Query().Get("cursor") {
+        case "":
+            return adsPage(1, `,"meta":{"next_cursor":"abc"}`)
+        case "abc":
+            return adsPage(1, `,"meta":{"next_cursor":null}`)
+        }
+        return adsPage(0, "")
+    })
+    next, _ := pagedUpstream(t, func(r *http.Request) string {
+        if r.URL.Query().Get("after") == "" {
+            return adsPage(1, `,"links":{"next":"/ads?after=1"}`)
+        }
+        return adsPage(1, `,"links":{"next":""}`)
+    })
+    extractor := newExtractor(t,
+        config.SourceConfig{Name: "cursor", Format: "ads", URL: cursor.URL,
+            Pagination: &config.PaginationConfig{Type: etl.PaginationCursor, CursorPath: "meta.next_cursor"}},
+        config.SourceConfig{Name: "next", Format: "ads", URL: next.URL,
+            Pagination: &config.PaginationConfig{Type: etl.PaginationNextLink, NextPath: "links.next"}},
+    )
+
+    records, err := extractor.ExtractAll(context.Background())
+    if err != nil {
+        t.Fatalf("ExtractAll failed: %v", err)
+    }
+    if len(records.Ads) != 4 || len(records.Fetches) != 4 {
+        t.Errorf("Expected 4 records from 4 pages, got %d records and %d fetches", len(records.Ads), len(records.Fetches))
+    }
+}
+
+func TestExtractPages_MaxPagesFailsTheSource(t *testing.T) {
+    server, requests := pagedUpstream(t, func(r *http.Request) string {
+        return adsPage(2, "")
+    })
+    extractor := newExtractor(t, config.SourceConfig{
+        Name: "ads", Format: "ads", URL: server.URL,
+        Pagination: &config.PaginationConfig{Type: etl.PaginationOffset, PageSize: 2, MaxPages: 3},
+    })
+
+    _, err := extractor.ExtractPages(context.Background(), nil, func(string, *etl.Records) error { return nil })
+    if err == nil || !strings.Contains(err.Error(), "max pages") {
+        t.Fatalf("Expected max pages error, got %v", err)
+    }
+    if len(*requests) != 3 || (*requests)[2] != "limit=2&offset=4" {
+        t.Errorf("Expected 3 requests ending at offset 4, got %v", *requests)
+    }
+}
+
+func TestNewRegistryFromConfig_RejectsUnknownPagination(t *testing.T) {
+    cfg := &config.Config{Sources: []config.SourceConfig{{
+        Name: "ads", Format: "ads", URL: "http://upstream",
+        Pagination: &config.PaginationConfig{Type: "scroll"},
+    }}}
+    if _, err := etl.NewRegistryFromConfig(cfg); err == nil {
+        t.Errorf("Expected unknown pagination type to be rejected")
+    }
+}
@@ -0,0 +1,60 @@
+package test
+
+import (
+    "testing"
+    "time"
+    "admira-etl/internal/etl"
+    "admira-etl/internal/models"
+)
+
+func queryData() []models.Metrics {
+    return []models.Metrics{
+        {Date: "2024-01-01", Channel: "google_ads", CampaignID: "C-1", Clicks: 100, Cost: 50, Leads: 5, Opportunities: 2, ClosedWon: 1, Revenue: 500, UTMCampaign: "spring"},
+        {Date: "2024-01-02", Channel: "google_ads", CampaignID: "C-1", Clicks: 300, Cost: 150, Leads: 5, Opportunities: 3, ClosedWon: 0, Revenue: 0, UTMCampaign: "spring"},
+        {Date: "2024-01-02", Channel: "facebook_ads", CampaignID: "C-2", Clicks: 50, Cost: 100, Leads: 10, Opportunities: 1, ClosedWon: 1, Revenue: 400, UTMCampaign: "summer"},
+    }
+}
+
+func TestAggregate_RecomputesDerivedOnTotals(t *testing.T) {
+    rows := etl.Aggregate(queryData(), []etl.Dimension{etl.DimensionChannel})
+    if len(rows) != 2 {
+        t.Fatalf("Expected 2 rows, got %d", len(rows))
+    }
+
+    google := rows[1]
+    if google.Channel != "google_ads" || google.Date != "" || google.CampaignID != "" {
+        t.Errorf("Expected only channel to be set, got %+v", google)
+    }
+    if google.Clicks != 400 || google.Cost != 200 || google.Leads != 10 {
+        t.Errorf("Unexpected counters: %+v", google)
+    }
+    // Sobre los totales, no la media de las filas
+    if google.CPC != 0.5 || google.CPA != 20 || google.CVRLeadToOpp != 0.5 || google.ROAS != 2.5 {
+        t.Errorf("Unexpected derived metrics: %+v", google)
+    }
+}
+
+func TestAggregate_NoGroupByReturnsTotal(t *testing.T) {
+    rows := etl.Aggregate(queryData(), nil)
+    if len(rows) != 1 || rows[0].Clicks != 450 || rows[0].Revenue != 900 {
+        t.Errorf("Expected a single total row, got %+v", rows)
+    }
+}
+
+func TestMetricsQuery_Match(t *testing.T) {
+    from, _ := time.Parse("2006-01-02", "2024-01-02")
+    query := etl.MetricsQuery{
+        From:    from,
+        Filters: map[etl.Dimension][]string{etl.DimensionUTMCampaign: {"spring", "summer"}, etl.DimensionChannel: {"google_ads"}},
+    }
+
+    var matched []models.Metrics
+    for _, m := range queryData() {
+        if query.Match(m) {
+            matched = append(matched, m)
+        }
+    }
+    if len(matched) != 1 || matched[0].Date != "2024-01-02" || matched[0].Channel != "google_ads" {
+        t.Errorf("Unexpected matches: %+v", matched)
+    }
+}
@@ -0,0 +1,65 @@
+package test
+
+import (
+    "testing"
+    "admira-etl/internal/etl"
+    "admira-etl/pkg/config"
+)
+
+func TestRegistry_RegistersSourcesInOrder(t *testing.T) {
+    registry := etl.NewRegistry()
+    for _, name := range []string{"crm", "ads", "hubspot"} {
+        if err := registry.Register(etl.NewHTTPSource(name, "http://upstream/"+name, etl.DecodeAdsResponse)); err != nil {
+            t.Fatalf("Register %s failed: %v", name, err)
+        }
+    }
+
+    sources := registry.Sources()
+    if len(sources) != 3 || sources[0].Name() != "crm" || sources[1].Name() != "ads" || sources[2].Name() != "hubspot" {
+        t.Errorf("Expected sources in registration order, got %+v", sources)
+    }
+    if src, ok := registry.Get("ads"); !ok || src.Name() != "ads" {
+        t.Errorf("Expected to find the ads source, got %v %v", src, ok)
+    }
+}
+
+func TestRegistry_RejectsDuplicateAndUnnamedSources(t *testing.T) {
+    registry := etl.NewRegistry()
+    registry.Register(etl.NewHTTPSource("ads", "http://upstream/ads", etl.DecodeAdsResponse))
+
+    if err := registry.Register(etl.NewHTTPSource("ads", "http://other/ads", etl.DecodeAdsResponse)); err == nil {
+        t.Error("Expected an error registering a duplicate source name")
+    }
+    if err := registry.Register(etl.NewHTTPSource("", "http://upstream/x", etl.DecodeAdsResponse)); err == nil {
+        t.Error("Expected an error registering a source without a name")
+    }
+    if sources := registry.Sources(); len(sources) != 1 {
+        t.Errorf("Expected only the first ads source, got %+v", sources)
+    }
+}
+
+func TestRegistry_UnknownSource(t *testing.T) {
+    registry := etl.NewRegistry()
+    if src, ok := registry.Get("missing"); ok || src != nil {
+        t.Errorf("Expected no source for an unknown name, got %v", src)
+    }
+}
+
+func TestNewRegistryFromConfig_RejectsUnknownFormat(t *testing.T) {
+    cfg := &config.Config{Sources: []config.SourceConfig{
+        {Name: "ads", Format: "ads", URL: "http://upstream/ads"},
+        {Name: "hubspot", Format: "hubspot", URL: "http://upstream/hubspot"},
+    }}
+    if _, err := etl.NewRegistryFromConfig(cfg); err == nil {
+        t.Error("Expected an error for a source with an unknown format")
+    }
+
+    cfg.Sources = cfg.Sources[:1]
+    registry, err := etl.NewRegistryFromConfig(cfg)
+    if err != nil {
+        t.Fatalf("NewRegistryFromConfig failed: %v", err)
+    }
+    if _, ok := registry.Get("ads"); !ok {
+        t.Error("Expected the configured ads source to be registered")
+    }
+}
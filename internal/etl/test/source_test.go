@@ -0,0 +1,50 @@
+package test
+
+import (
+    "testing"
+    "admira-etl/internal/etl"
+    "admira-etl/internal/models"
+)
+
+func TestDecodeCRMResponse_RejectsInvalidDates(t *testing.T) {
+    body := []byte(`{"external":{"crm":{"opportunities":[
+        {"opportunity_id":"O-1","stage":"lead","amount":0,"created_at":"2024-01-05T10:00:00Z","utm_campaign":"spring"},
+        {"opportunity_id":"O-2","stage":"lead","amount":0,"created_at":"not-a-date","utm_campaign":"spring"},
+        {"opportunity_id":"O-3","stage":"lead","amount":0,"utm_campaign":"spring"}
+    ]}}}`)
+
+    records, err := etl.DecodeCRMResponse(body)
+    if err != nil {
+        t.Fatalf("Decode failed: %v", err)
+    }
+    if len(records.CRM) != 1 || records.CRM[0].OpportunityID != "O-1" {
+        t.Errorf("Expected only O-1 to be decoded, got %+v", records.CRM)
+    }
+    if len(records.Rejected) != 2 {
+        t.Fatalf("Expected 2 rejected records, got %d", len(records.Rejected))
+    }
+    for _, r := range records.Rejected {
+        if r.Kind != etl.RecordKindCRM || r.Reason == "" {
+            t.Errorf("Unexpected rejected record: %+v", r)
+        }
+    }
+}
+
+func TestDecodeRecord_ExtraDateFormats(t *testing.T) {
+    raw := []byte(`{"opportunity_id":"O-4","stage":"lead","created_at":"05/01/2024"}`)
+
+    if _, err := etl.DecodeRecord(etl.RecordKindCRM, raw); err == nil {
+        t.Fatalf("Expected error for unsupported date format")
+    }
+
+    models.SetExtraDateFormats([]string{"02/01/2006"})
+    defer models.SetExtraDateFormats(nil)
+
+    records, err := etl.DecodeRecord(etl.RecordKindCRM, raw)
+    if err != nil {
+        t.Fatalf("Expected record to decode with extra format: %v", err)
+    }
+    if got := records.CRM[0].CreatedAt.Format("2006-01-02"); got != "2024-01-05" {
+        t.Errorf("Expected 2024-01-05, got %s", got)
+    }
+}
@@ -1,6 +1,8 @@
 ﻿package test
 
 import (
+    "os"
+    "path/filepath"
     "testing"
     "time"
     "admira-etl/internal/etl"
@@ -93,3 +95,86 @@ func TestTransformer_FilterByDate(t *testing.T) {
         t.Errorf("Expected 2 metrics after filtering, got %d", len(filtered))
     }
 }
+
+func TestTransformer_NormalizesUTMsBeforeGrouping(t *testing.T) {
+    options := etl.DefaultTransformOptions()
+    options.Normalizer.Aliases = map[string]map[string]string{
+        etl.FieldUTMCampaign: {"bts": "back_to_school"},
+    }
+    transformer := etl.NewTransformerWithOptions(options)
+    
+    adsData := []models.AdsPerformance{
+        {Date: "2024-01-01", CampaignID: "C-1", Channel: "google_ads", Clicks: 10, UTMCampaign: "back_to_school", UTMSource: "google", UTMMedium: "cpc"},
+        {Date: "2024-01-01", CampaignID: "C-1", Channel: "google_ads", Clicks: 20, UTMCampaign: " Back_To_School ", UTMSource: "Google", UTMMedium: "cpc"},
+        {Date: "2024-01-01", CampaignID: "C-1", Channel: "google_ads", Clicks: 30, UTMCampaign: "back%5Fto%5Fschool", UTMSource: "google", UTMMedium: "cpc"},
+        {Date: "2024-01-01", CampaignID: "C-1", Channel: "google_ads", Clicks: 40, UTMCampaign: "BTS", UTMSource: "google", UTMMedium: "cpc"},
+    }
+    
+    result, err := transformer.TransformWithOptions(adsData, nil, options)
+    if err != nil {
+        t.Fatalf("Transform failed: %v", err)
+    }
+    
+    if len(result.Metrics) != 1 || result.Metrics[0].Clicks != 100 {
+        t.Fatalf("Expected a single row with 100 clicks, got %+v", result.Metrics)
+    }
+    
+    expected := etl.NormalizationStats{etl.RuleTrim: 1, etl.RuleLowercase: 3, etl.RuleURLDecode: 1, etl.RuleAlias: 1}
+    for rule, count := range expected {
+        if result.Normalization[rule] != count {
+            t.Errorf("Expected %d rewrites for %s, got %d", count, rule, result.Normalization[rule])
+        }
+    }
+}
+
+func TestAccumulator_MatchesTransformAcrossPages(t *testing.T) {
+    adsData, crmData := attributionData()
+    transformer := etl.NewTransformer()
+    options := etl.DefaultTransformOptions()
+
+    want, err := transformer.TransformWithOptions(adsData, crmData, options)
+    if err != nil {
+        t.Fatalf("Transform failed: %v", err)
+    }
+
+    acc, err := transformer.NewAccumulator(options)
+    if err != nil {
+        t.Fatalf("NewAccumulator failed: %v", err)
+    }
+    // CRM llega antes que la última página de Ads: la atribución debe esperar
+    acc.Add(&etl.Records{Ads: adsData[:1], CRM: crmData})
+    acc.Add(&etl.Records{Ads: adsData[1:]})
+    got := acc.Result()
+
+    if len(got.Metrics) != len(want.Metrics) || got.Attribution != want.Attribution {
+        t.Fatalf("Expected %+v, got %+v", want, got)
+    }
+    byKey := make(map[etl.MetricKey]models.Metrics)
+    for _, m := range want.Metrics {
+        byKey[etl.MetricKeyOf(m)] = m
+    }
+    for _, m := range got.Metrics {
+        if byKey[etl.MetricKeyOf(m)] != m {
+            t.Errorf("Metric mismatch for %+v", etl.MetricKeyOf(m))
+        }
+    }
+}
+
+func TestUTMNormalizer_AliasKeysAreNormalizedOnLoad(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "aliases.json")
+    os.WriteFile(path, []byte(`{"utm_campaign": {" BTS ": "Back_To_School"}, "utm_source": {"FB%20Ads": "facebook"}}`), 0o644)
+
+    aliases, err := etl.LoadUTMAliases(path)
+    if err != nil {
+        t.Fatalf("LoadUTMAliases failed: %v", err)
+    }
+    normalizer := etl.DefaultUTMNormalizer()
+    normalizer.SetAliases(aliases)
+
+    if got := normalizer.Normalize(etl.FieldUTMCampaign, "bts", nil); got != "back_to_school" {
+        t.Errorf("Expected mixed-case alias key to match, got %q", got)
+    }
+    if got := normalizer.Normalize(etl.FieldUTMSource, "fb ads", nil); got != "facebook" {
+        t.Errorf("Expected URL-encoded alias key to match, got %q", got)
+    }
+}
@@ -7,27 +7,50 @@ import (
     "admira-etl/internal/models"
 )
 
-type Transformer struct{}
+type Transformer struct {
+    options TransformOptions
+}
 
-func NewTransformer() *Transformer {
-    return &Transformer{}
+// TransformOptions son los parámetros de una ejecución de Transform
+type TransformOptions struct {
+    Attribution AttributionConfig
+    Channels    *ChannelRules
+    // Normalizer limpia los UTMs antes de agregar; nil desactiva la etapa
+    Normalizer  *UTMNormalizer
+}
+
+// TransformResult contiene las métricas generadas y estadísticas del proceso
+type TransformResult struct {
+    Metrics       []models.Metrics
+    Attribution   AttributionStats
+    Normalization NormalizationStats
 }
 
-func inferChannelFromUTM(utmSource, utmMedium string) string {
-    switch utmSource {
-    case "google":
-        return "google_ads"
-    case "facebook":
-        return "facebook_ads" 
-    case "tiktok":
-        return "tiktok_ads"
-    case "linkedin":
-        return "linkedin_ads"
-    default:
-        return fmt.Sprintf("%s_%s", utmSource, utmMedium)
+func DefaultTransformOptions() TransformOptions {
+    return TransformOptions{
+        Attribution: AttributionConfig{
+            Model:        AttributionLastTouch,
+            LookbackDays: 30,
+            HalfLifeDays: 7,
+        },
+        Channels:   DefaultChannelRules(),
+        Normalizer: DefaultUTMNormalizer(),
     }
 }
 
+func NewTransformer() *Transformer {
+    return NewTransformerWithOptions(DefaultTransformOptions())
+}
+
+func NewTransformerWithOptions(options TransformOptions) *Transformer {
+    return &Transformer{options: options}
+}
+
+// Options devuelve las opciones por defecto del transformer
+func (t *Transformer) Options() TransformOptions {
+    return t.options
+}
+
 // Clave única para agrupar métricas
 type MetricKey struct {
     Date        string
@@ -38,11 +61,85 @@ type MetricKey struct {
     UTMMedium   string
 }
 
+// MetricKeyOf devuelve la clave única de una métrica ya consolidada
+func MetricKeyOf(m models.Metrics) MetricKey {
+    return MetricKey{
+        Date:        m.Date,
+        Channel:     m.Channel,
+        CampaignID:  m.CampaignID,
+        UTMCampaign: m.UTMCampaign,
+        UTMSource:   m.UTMSource,
+        UTMMedium:   m.UTMMedium,
+    }
+}
+
 func (t *Transformer) Transform(adsData []models.AdsPerformance, crmData []models.CRMOpportunity) ([]models.Metrics, error) {
-    fmt.Printf("Debug: Transformando %d registros Ads y %d registros CRM\n", len(adsData), len(crmData))
-    
+    result, err := t.TransformWithOptions(adsData, crmData, t.options)
+    if err != nil {
+        return nil, err
+    }
+    return result.Metrics, nil
+}
+
+// TransformWithOptions consolida Ads y CRM atribuyendo cada registro CRM a las
+// filas de Ads de su campaña según el modelo de atribución indicado
+func (t *Transformer) TransformWithOptions(adsData []models.AdsPerformance, crmData []models.CRMOpportunity, options TransformOptions) (*TransformResult, error) {
+    acc, err := t.NewAccumulator(options)
+    if err != nil {
+        return nil, err
+    }
+    acc.AddAds(adsData)
+    acc.AddCRM(crmData)
+    return acc.Result(), nil
+}
+
+// Accumulator consolida registros a medida que llegan, p. ej. página a página
+// desde el extractor. Las filas de Ads se agregan al llegar; los registros CRM
+// se guardan normalizados hasta Result porque la atribución necesita todas
+// las filas de Ads.
+type Accumulator struct {
+    transformer   *Transformer
+    options       TransformOptions
+    channels      *ChannelRules
+    normalization NormalizationStats
     // Map para consolidar métricas por clave única
-    metricsMap := make(map[MetricKey]*models.Metrics)
+    metricsMap map[MetricKey]*models.Metrics
+    crmData    []models.CRMOpportunity
+    adsCount   int
+}
+
+// NewAccumulator prepara una consolidación incremental con las opciones indicadas
+func (t *Transformer) NewAccumulator(options TransformOptions) (*Accumulator, error) {
+    if _, err := ParseAttributionModel(string(options.Attribution.Model)); err != nil {
+        return nil, err
+    }
+    channels := options.Channels
+    if channels == nil {
+        channels = DefaultChannelRules()
+    }
+    return &Accumulator{
+        transformer:   t,
+        options:       options,
+        channels:      channels,
+        normalization: make(NormalizationStats),
+        metricsMap:    make(map[MetricKey]*models.Metrics),
+    }, nil
+}
+
+// Add incorpora una página de registros extraídos
+func (a *Accumulator) Add(records *Records) {
+    a.AddAds(records.Ads)
+    a.AddCRM(records.CRM)
+}
+
+// AddAds normaliza y agrega filas de Ads
+func (a *Accumulator) AddAds(adsData []models.AdsPerformance) {
+    // Normalizar UTMs antes de agrupar para no partir las claves
+    if a.options.Normalizer != nil {
+        adsData = a.options.Normalizer.NormalizeAds(adsData, a.normalization)
+    }
+    a.adsCount += len(adsData)
+    metricsMap := a.metricsMap
 
     // Procesar datos de Ads
     for _, ad := range adsData {
@@ -76,11 +173,51 @@ func (t *Transformer) Transform(adsData []models.AdsPerformance, crmData []model
         }
         fmt.Printf("Debug: Procesado Ads - Date: %s, Channel: %s, Clicks: %d, Cost: %.2f\n", ad.Date, ad.Channel, ad.Clicks, ad.Cost)
     }
+}
+
+// AddCRM normaliza y guarda registros CRM para atribuirlos en Result
+func (a *Accumulator) AddCRM(crmData []models.CRMOpportunity) {
+    if a.options.Normalizer != nil {
+        crmData = a.options.Normalizer.NormalizeCRM(crmData, a.normalization)
+    }
+    a.crmData = append(a.crmData, crmData...)
+}
 
-    // Procesar datos de CRM - INFERIR channel desde UTM
+// Result atribuye los registros CRM y devuelve las métricas consolidadas.
+// Solo debe llamarse una vez, cuando ya no quedan registros por añadir.
+func (a *Accumulator) Result() *TransformResult {
+    options := a.options
+    channels := a.channels
+    crmData := a.crmData
+    metricsMap := a.metricsMap
+    normalization := a.normalization
+
+    fmt.Printf("Debug: Transformando %d registros Ads y %d registros CRM\n", a.adsCount, len(crmData))
+    if options.Normalizer != nil {
+        fmt.Printf("Debug: Normalización UTM - reescrituras por regla: %v\n", normalization)
+    }
+    
+    // Procesar datos de CRM - atribuir a filas de Ads o, si no hay ninguna
+    // en la ventana, INFERIR channel desde UTM
+    stats := AttributionStats{Model: options.Attribution.Model}
+    touchpoints := newTouchpointIndex(metricsMap)
+    
     for _, crm := range crmData {
         date := crm.CreatedAt.Format("2006-01-02")
-        channel := inferChannelFromUTM(crm.UTMSource, crm.UTMMedium)
+        createdDate, _ := time.Parse("2006-01-02", date)
+        
+        if tps := touchpoints.candidates(crm, createdDate, options.Attribution.LookbackDays); len(tps) > 0 {
+            weights := attributionWeights(options.Attribution, tps, createdDate)
+            for i, tp := range tps {
+                applyCRMCredit(tp.metric, crm, weights[i])
+            }
+            stats.Attributed++
+            fmt.Printf("Debug: Atribuido CRM %s (%s) a %d filas de Ads con modelo %s\n", crm.OpportunityID, crm.Stage, len(tps), options.Attribution.Model)
+            continue
+        }
+        stats.Unattributed++
+        
+        channel := channels.Infer(crm.UTMSource, crm.UTMMedium)
         
         key := MetricKey{
             Date:        date,
@@ -93,15 +230,7 @@ func (t *Transformer) Transform(adsData []models.AdsPerformance, crmData []model
         
         if existing, exists := metricsMap[key]; exists {
             // Consolidar datos de CRM
-            switch crm.Stage {
-            case "lead":
-                existing.Leads += 1
-            case "opportunity":
-                existing.Opportunities += 1
-            case "closed_won":
-                existing.ClosedWon += 1
-                existing.Revenue += crm.Amount
-            }
+            applyCRMCredit(existing, crm, 1)
         } else {
             // Crear nueva métrica
             metric := &models.Metrics{
@@ -112,16 +241,7 @@ func (t *Transformer) Transform(adsData []models.AdsPerformance, crmData []model
                 UTMSource:   crm.UTMSource,
                 UTMMedium:   crm.UTMMedium,
             }
-            
-            switch crm.Stage {
-            case "lead":
-                metric.Leads = 1
-            case "opportunity":
-                metric.Opportunities = 1
-            case "closed_won":
-                metric.ClosedWon = 1
-                metric.Revenue = crm.Amount
-            }
+            applyCRMCredit(metric, crm, 1)
             
             metricsMap[key] = metric
         }
@@ -132,16 +252,24 @@ func (t *Transformer) Transform(adsData []models.AdsPerformance, crmData []model
     var metrics []models.Metrics
     for _, metric := range metricsMap {
         // Calcular métricas derivadas
-        t.calculateDerivedMetrics(metric)
+        a.transformer.calculateDerivedMetrics(metric)
         metrics = append(metrics, *metric)
     }
 
     fmt.Printf("Debug: Total métricas consolidadas generadas: %d\n", len(metrics))
-    return metrics, nil
+    return &TransformResult{Metrics: metrics, Attribution: stats, Normalization: normalization}
 }
 
-// Calcular métricas derivadas (CPC, CPA, CVR, ROAS)
 func (t *Transformer) calculateDerivedMetrics(metric *models.Metrics) {
+    CalculateDerivedMetrics(metric)
+    
+    fmt.Printf("Debug: Métricas calculadas - Date: %s, Channel: %s, CPC: %.3f, CPA: %.2f, CVR_Lead_Opp: %.3f, CVR_Opp_Won: %.3f, ROAS: %.2f\n", 
+        metric.Date, metric.Channel, metric.CPC, metric.CPA, metric.CVRLeadToOpp, metric.CVROppToWon, metric.ROAS)
+}
+
+// CalculateDerivedMetrics calcula las métricas derivadas (CPC, CPA, CVR, ROAS)
+// a partir de los contadores base. Se usa tanto en Transform como al agregar.
+func CalculateDerivedMetrics(metric *models.Metrics) {
     // CPC = cost / clicks (proteger división por cero)
     if metric.Clicks > 0 {
         metric.CPC = metric.Cost / float64(metric.Clicks)
@@ -176,9 +304,6 @@ func (t *Transformer) calculateDerivedMetrics(metric *models.Metrics) {
     } else {
         metric.ROAS = 0
     }
-    
-    fmt.Printf("Debug: Métricas calculadas - Date: %s, Channel: %s, CPC: %.3f, CPA: %.2f, CVR_Lead_Opp: %.3f, CVR_Opp_Won: %.3f, ROAS: %.2f\n", 
-        metric.Date, metric.Channel, metric.CPC, metric.CPA, metric.CVRLeadToOpp, metric.CVROppToWon, metric.ROAS)
 }
 
 func (t *Transformer) FilterByDate(metrics []models.Metrics, since time.Time) []models.Metrics {
@@ -0,0 +1,351 @@
+package jobs
+
+import (
+    "context"
+    "crypto/rand"
+    "encoding/hex"
+    "errors"
+    "fmt"
+    "sync"
+    "time"
+)
+
+// ErrQueueFull se devuelve cuando no caben más jobs en la cola
+var ErrQueueFull = errors.New("job queue is full")
+
+// ErrStopped se devuelve al encolar un job después de Stop
+var ErrStopped = errors.New("job manager is stopped")
+
+// historyLimit es el número de jobs terminados que se conservan para consulta
+const historyLimit = 200
+
+type Status string
+
+const (
+    StatusQueued    Status = "queued"
+    StatusRunning   Status = "running"
+    StatusSucceeded Status = "succeeded"
+    StatusFailed    Status = "failed"
+)
+
+// Stage registra la duración de una etapa del job (extract, transform, ...)
+type Stage struct {
+    Name       string    `json:"name"`
+    StartedAt  time.Time `json:"started_at"`
+    DurationMs int64     `json:"duration_ms"`
+    Error      string    `json:"error,omitempty"`
+}
+
+type Job struct {
+    ID         string                 `json:"id"`
+    Type       string                 `json:"type"`
+    Status     Status                 `json:"status"`
+    Params     map[string]string      `json:"params,omitempty"`
+    Stages     []Stage                `json:"stages"`
+    Counts     map[string]int         `json:"counts"`
+    Details    map[string]interface{} `json:"details,omitempty"`
+    Error      string                 `json:"error,omitempty"`
+    CreatedAt  time.Time              `json:"created_at"`
+    StartedAt  *time.Time             `json:"started_at,omitempty"`
+    FinishedAt *time.Time             `json:"finished_at,omitempty"`
+}
+
+// Func es el trabajo a ejecutar; usa el Tracker para reportar su progreso
+type Func func(ctx context.Context, t *Tracker) error
+
+type task struct {
+    id string
+    fn Func
+}
+
+// Manager ejecuta jobs en un pool de workers con concurrencia acotada
+type Manager struct {
+    mu     sync.RWMutex
+    jobs   map[string]*Job
+    done   map[string]chan struct{}
+    order  []string
+    queue  chan task
+    ctx    context.Context
+    cancel context.CancelFunc
+    wg     sync.WaitGroup
+}
+
+func NewManager(workers, queueSize int) *Manager {
+    if workers < 1 {
+        workers = 1
+    }
+    if queueSize < 1 {
+        queueSize = 1
+    }
+
+    ctx, cancel := context.WithCancel(context.Background())
+    m := &Manager{
+        jobs:   make(map[string]*Job),
+        done:   make(map[string]chan struct{}),
+        queue:  make(chan task, queueSize),
+        ctx:    ctx,
+        cancel: cancel,
+    }
+
+    for i := 0; i < workers; i++ {
+        m.wg.Add(1)
+        go m.worker()
+    }
+    return m
+}
+
+// Submit encola un job y devuelve una copia de su estado inicial
+func (m *Manager) Submit(jobType string, params map[string]string, fn Func) (Job, error) {
+    job := &Job{
+        ID:        newJobID(),
+        Type:      jobType,
+        Status:    StatusQueued,
+        Params:    params,
+        Stages:    []Stage{},
+        Counts:    make(map[string]int),
+        CreatedAt: time.Now().UTC(),
+    }
+
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    if m.ctx.Err() != nil {
+        return Job{}, ErrStopped
+    }
+    select {
+    case m.queue <- task{id: job.ID, fn: fn}:
+    default:
+        return Job{}, ErrQueueFull
+    }
+
+    m.jobs[job.ID] = job
+    m.done[job.ID] = make(chan struct{})
+    m.order = append(m.order, job.ID)
+    m.pruneLocked()
+    return copyJob(job), nil
+}
+
+// Get devuelve una copia del job
+func (m *Manager) Get(id string) (Job, bool) {
+    m.mu.RLock()
+    defer m.mu.RUnlock()
+
+    job, ok := m.jobs[id]
+    if !ok {
+        return Job{}, false
+    }
+    return copyJob(job), true
+}
+
+// Wait bloquea hasta que el job termina o se cancela el contexto
+func (m *Manager) Wait(ctx context.Context, id string) (Job, error) {
+    m.mu.RLock()
+    done, ok := m.done[id]
+    m.mu.RUnlock()
+    if !ok {
+        return Job{}, fmt.Errorf("job %s not found", id)
+    }
+
+    select {
+    case <-done:
+    case <-ctx.Done():
+        return Job{}, ctx.Err()
+    }
+
+    job, _ := m.Get(id)
+    return job, nil
+}
+
+// List devuelve los jobs más recientes primero, opcionalmente filtrados por tipo
+func (m *Manager) List(jobType string, limit int) []Job {
+    m.mu.RLock()
+    defer m.mu.RUnlock()
+
+    result := make([]Job, 0)
+    for i := len(m.order) - 1; i >= 0; i-- {
+        job := m.jobs[m.order[i]]
+        if jobType != "" && job.Type != jobType {
+            continue
+        }
+        result = append(result, copyJob(job))
+        if limit > 0 && len(result) >= limit {
+            break
+        }
+    }
+    return result
+}
+
+// Stop cancela los jobs en curso, espera a que terminen los workers y da por
+// fallidos los que seguían en cola para que nadie se quede esperándolos
+func (m *Manager) Stop() {
+    m.cancel()
+    m.wg.Wait()
+
+    for {
+        select {
+        case t := <-m.queue:
+            m.discard(t)
+        default:
+            return
+        }
+    }
+}
+
+func (m *Manager) worker() {
+    defer m.wg.Done()
+
+    for {
+        select {
+        case <-m.ctx.Done():
+            return
+        case t := <-m.queue:
+            // select no da prioridad a la cancelación
+            if m.ctx.Err() != nil {
+                m.discard(t)
+                return
+            }
+            m.run(t)
+        }
+    }
+}
+
+// discard da por fallido un job que no llegó a ejecutarse antes de Stop
+func (m *Manager) discard(t task) {
+    finished := time.Now().UTC()
+    m.update(t.id, func(job *Job) {
+        job.Status = StatusFailed
+        job.Error = m.ctx.Err().Error()
+        job.FinishedAt = &finished
+        close(m.done[t.id])
+    })
+}
+
+func (m *Manager) run(t task) {
+    now := time.Now().UTC()
+    m.update(t.id, func(job *Job) {
+        job.Status = StatusRunning
+        job.StartedAt = &now
+    })
+
+    tracker := &Tracker{manager: m, jobID: t.id}
+    err := safeRun(m.ctx, t.fn, tracker)
+
+    finished := time.Now().UTC()
+    m.update(t.id, func(job *Job) {
+        job.FinishedAt = &finished
+        if err != nil {
+            job.Status = StatusFailed
+            job.Error = err.Error()
+        } else {
+            job.Status = StatusSucceeded
+        }
+        close(m.done[t.id])
+    })
+}
+
+func safeRun(ctx context.Context, fn Func, t *Tracker) (err error) {
+    defer func() {
+        if r := recover(); r != nil {
+            err = fmt.Errorf("job panicked: %v", r)
+        }
+    }()
+    return fn(ctx, t)
+}
+
+func (m *Manager) update(id string, fn func(job *Job)) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    if job, ok := m.jobs[id]; ok {
+        fn(job)
+    }
+}
+
+// pruneLocked descarta los jobs terminados más antiguos por encima del límite
+func (m *Manager) pruneLocked() {
+    for len(m.order) > historyLimit {
+        removed := false
+        for i, id := range m.order {
+            status := m.jobs[id].Status
+            if status == StatusSucceeded || status == StatusFailed {
+                delete(m.jobs, id)
+                delete(m.done, id)
+                m.order = append(m.order[:i], m.order[i+1:]...)
+                removed = true
+                break
+            }
+        }
+        if !removed {
+            return
+        }
+    }
+}
+
+// Tracker permite a un job reportar etapas, contadores y detalles
+type Tracker struct {
+    manager *Manager
+    jobID   string
+}
+
+// Stage ejecuta fn midiendo su duración como una etapa del job
+func (t *Tracker) Stage(name string, fn func() error) error {
+    start := time.Now()
+    err := fn()
+
+    stage := Stage{
+        Name:       name,
+        StartedAt:  start.UTC(),
+        DurationMs: time.Since(start).Milliseconds(),
+    }
+    if err != nil {
+        stage.Error = err.Error()
+    }
+
+    t.manager.update(t.jobID, func(job *Job) {
+        job.Stages = append(job.Stages, stage)
+    })
+    return err
+}
+
+// JobID devuelve el ID del job en ejecución
+func (t *Tracker) JobID() string {
+    return t.jobID
+}
+
+func (t *Tracker) SetCount(name string, n int) {
+    t.manager.update(t.jobID, func(job *Job) {
+        job.Counts[name] = n
+    })
+}
+
+func (t *Tracker) SetDetail(key string, value interface{}) {
+    t.manager.update(t.jobID, func(job *Job) {
+        if job.Details == nil {
+            job.Details = make(map[string]interface{})
+        }
+        job.Details[key] = value
+    })
+}
+
+func copyJob(job *Job) Job {
+    c := *job
+    c.Stages = append([]Stage{}, job.Stages...)
+    c.Counts = make(map[string]int, len(job.Counts))
+    for k, v := range job.Counts {
+        c.Counts[k] = v
+    }
+    if job.Details != nil {
+        c.Details = make(map[string]interface{}, len(job.Details))
+        for k, v := range job.Details {
+            c.Details[k] = v
+        }
+    }
+    return c
+}
+
+func newJobID() string {
+    b := make([]byte, 8)
+    if _, err := rand.Read(b); err != nil {
+        return fmt.Sprintf("job_%d", time.Now().UnixNano())
+    }
+    return "job_" + hex.EncodeToString(b)
+}
@@ -0,0 +1,158 @@
+package test
+
+import (
+    "context"
+    "errors"
+    "testing"
+    "time"
+    "admira-etl/internal/jobs"
+)
+
+// blocking devuelve un job que avisa al empezar y espera a release o a la cancelación
+func blocking(started chan<- struct{}, release <-chan struct{}) jobs.Func {
+    return func(ctx context.Context, t *jobs.Tracker) error {
+        started <- struct{}{}
+        select {
+        case <-release:
+            return nil
+        case <-ctx.Done():
+            return ctx.Err()
+        }
+    }
+}
+
+func TestManager_StatusTransitions(t *testing.T) {
+    manager := jobs.NewManager(1, 4)
+    defer manager.Stop()
+
+    started := make(chan struct{}, 1)
+    release := make(chan struct{})
+    job, err := manager.Submit("ingest", nil, blocking(started, release))
+    if err != nil {
+        t.Fatalf("Submit failed: %v", err)
+    }
+    if job.Status != jobs.StatusQueued {
+        t.Errorf("Expected queued, got %s", job.Status)
+    }
+
+    <-started
+    if running, _ := manager.Get(job.ID); running.Status != jobs.StatusRunning || running.StartedAt == nil {
+        t.Errorf("Expected running with started_at, got %+v", running)
+    }
+
+    close(release)
+    done, err := manager.Wait(context.Background(), job.ID)
+    if err != nil || done.Status != jobs.StatusSucceeded || done.FinishedAt == nil {
+        t.Errorf("Expected succeeded with finished_at, got %+v (%v)", done, err)
+    }
+
+    failed, _ := manager.Submit("ingest", nil, func(ctx context.Context, t *jobs.Tracker) error {
+        return errors.New("upstream down")
+    })
+    failed, _ = manager.Wait(context.Background(), failed.ID)
+    if failed.Status != jobs.StatusFailed || failed.Error != "upstream down" {
+        t.Errorf("Expected failed with error, got %+v", failed)
+    }
+}
+
+func TestManager_RejectsWhenQueueIsFull(t *testing.T) {
+    manager := jobs.NewManager(1, 1)
+    defer manager.Stop()
+
+    started := make(chan struct{}, 1)
+    release := make(chan struct{})
+    defer close(release)
+
+    // El primero ocupa el worker y el segundo la única plaza de la cola
+    if _, err := manager.Submit("ingest", nil, blocking(started, release)); err != nil {
+        t.Fatalf("Submit failed: %v", err)
+    }
+    <-started
+    if _, err := manager.Submit("ingest", nil, blocking(started, release)); err != nil {
+        t.Fatalf("Submit failed: %v", err)
+    }
+    if _, err := manager.Submit("ingest", nil, blocking(started, release)); !errors.Is(err, jobs.ErrQueueFull) {
+        t.Errorf("Expected ErrQueueFull, got %v", err)
+    }
+}
+
+func TestManager_StopCancelsRunningJobs(t *testing.T) {
+    manager := jobs.NewManager(1, 1)
+
+    started := make(chan struct{}, 1)
+    job, _ := manager.Submit("ingest", nil, blocking(started, make(chan struct{})))
+    <-started
+
+    stopped := make(chan struct{})
+    go func() {
+        manager.Stop()
+        close(stopped)
+    }()
+    select {
+    case <-stopped:
+    case <-time.After(time.Second):
+        t.Fatalf("Stop did not cancel the running job")
+    }
+
+    if canceled, _ := manager.Get(job.ID); canceled.Status != jobs.StatusFailed || canceled.Error != context.Canceled.Error() {
+        t.Errorf("Expected the job to fail with context canceled, got %+v", canceled)
+    }
+}
+
+func TestManager_StopFailsQueuedJobs(t *testing.T) {
+    manager := jobs.NewManager(1, 2)
+
+    started := make(chan struct{}, 1)
+    manager.Submit("ingest", nil, blocking(started, make(chan struct{})))
+    <-started
+    queued, err := manager.Submit("ingest", nil, func(ctx context.Context, t *jobs.Tracker) error {
+        return nil
+    })
+    if err != nil {
+        t.Fatalf("Submit failed: %v", err)
+    }
+
+    waited := make(chan jobs.Job, 1)
+    go func() {
+        job, _ := manager.Wait(context.Background(), queued.ID)
+        waited <- job
+    }()
+    manager.Stop()
+
+    select {
+    case job := <-waited:
+        if job.Status != jobs.StatusFailed || job.Error != context.Canceled.Error() || job.FinishedAt == nil {
+            t.Errorf("Expected the queued job to fail with context canceled, got %+v", job)
+        }
+    case <-time.After(time.Second):
+        t.Fatalf("Waiting on a queued job did not return after Stop")
+    }
+    if _, err := manager.Submit("ingest", nil, nil); !errors.Is(err, jobs.ErrStopped) {
+        t.Errorf("Expected ErrStopped after Stop, got %v", err)
+    }
+}
+
+func TestManager_PrunesOldestFinishedJobs(t *testing.T) {
+    manager := jobs.NewManager(4, 16)
+    defer manager.Stop()
+
+    noop := func(ctx context.Context, t *jobs.Tracker) error { return nil }
+    first, _ := manager.Submit("ingest", nil, noop)
+    manager.Wait(context.Background(), first.ID)
+
+    var last jobs.Job
+    for i := 0; i < 200; i++ {
+        last, _ = manager.Submit("ingest", nil, noop)
+        manager.Wait(context.Background(), last.ID)
+    }
+
+    if _, ok := manager.Get(first.ID); ok {
+        t.Errorf("Expected the oldest finished job to be pruned")
+    }
+    if _, ok := manager.Get(last.ID); !ok {
+        t.Errorf("Expected the latest job to be kept")
+    }
+    if all := manager.List("ingest", 0); len(all) != 200 {
+        t.Errorf("Expected 200 jobs to be kept, got %d", len(all))
+    }
+}
@@ -1,6 +1,9 @@
 ﻿package models
 
-import "time"
+import (
+    "fmt"
+    "time"
+)
 
 type AdsPerformance struct {
     Date         string    `json:"date"`
@@ -15,6 +18,14 @@ type AdsPerformance struct {
     IngestedAt   time.Time `json:"ingested_at"`
 }
 
+// Validate comprueba los campos sin los que la fila no se puede agregar
+func (a AdsPerformance) Validate() error {
+    if _, err := time.Parse("2006-01-02", a.Date); err != nil {
+        return fmt.Errorf("invalid date %q: expected YYYY-MM-DD", a.Date)
+    }
+    return nil
+}
+
 type AdsResponse struct {
     External struct {
         Ads struct {
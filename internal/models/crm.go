@@ -2,11 +2,17 @@
 
 import (
     "encoding/json"
+    "errors"
     "fmt"
     "strings"
+    "sync"
     "time"
 )
 
+// ErrInvalidCreatedAt indica que el created_at de un registro CRM falta o no se
+// pudo interpretar; el registro debe ir a cuarentena en lugar de fecharse hoy
+var ErrInvalidCreatedAt = errors.New("invalid created_at")
+
 type CRMOpportunity struct {
     OpportunityID string    `json:"opportunity_id"`
     ContactEmail  string    `json:"contact_email"`
@@ -33,20 +39,33 @@ func (c *CRMOpportunity) UnmarshalJSON(data []byte) error {
         return err
     }
     
-    // Parsear la fecha si está como string
-    if aux.CreatedAtString != "" {
-        parsedTime, err := parseDateTime(aux.CreatedAtString)
-        if err != nil {
-            // Si falla el parsing, usar fecha actual en lugar de fallar
-            c.CreatedAt = time.Now()
-        } else {
-            c.CreatedAt = parsedTime
-        }
+    // Sin fecha válida no se puede asignar el registro a un día
+    if strings.TrimSpace(aux.CreatedAtString) == "" {
+        return fmt.Errorf("%w: missing created_at", ErrInvalidCreatedAt)
+    }
+    
+    parsedTime, err := parseDateTime(aux.CreatedAtString)
+    if err != nil {
+        return fmt.Errorf("%w: %v", ErrInvalidCreatedAt, err)
     }
+    c.CreatedAt = parsedTime
     
     return nil
 }
 
+var (
+    extraDateFormatsMu sync.RWMutex
+    extraDateFormats   []string
+)
+
+// SetExtraDateFormats añade layouts de fecha (formato Go) a los que se prueban
+// al interpretar created_at, p.ej. para reprocesar registros en cuarentena
+func SetExtraDateFormats(formats []string) {
+    extraDateFormatsMu.Lock()
+    defer extraDateFormatsMu.Unlock()
+    extraDateFormats = append([]string{}, formats...)
+}
+
 func parseDateTime(dateStr string) (time.Time, error) {
     // Limpiar y normalizar el string de fecha
     dateStr = strings.TrimSpace(dateStr)
@@ -72,6 +91,14 @@ func parseDateTime(dateStr string) (time.Time, error) {
         }
     }
     
+    extraDateFormatsMu.RLock()
+    defer extraDateFormatsMu.RUnlock()
+    for _, format := range extraDateFormats {
+        if t, err := time.Parse(format, dateStr); err == nil {
+            return t, nil
+        }
+    }
+    
     return time.Time{}, fmt.Errorf("unable to parse date: %s", dateStr)
 }
 
@@ -1,5 +1,11 @@
 ﻿package models
 
+import "strconv"
+
+// Leads, Opportunities y ClosedWon son float64 porque los modelos de atribución
+// multi-touch reparten fracciones de un registro CRM entre varias filas de Ads.
+// Los valores enteros (todos con last_touch y first_touch) se escriben sin
+// decimales en JSON y CSV, igual que cuando eran int.
 type Metrics struct {
     Date           string  `json:"date"`
     Channel        string  `json:"channel"`
@@ -7,9 +13,9 @@ type Metrics struct {
     Clicks         int     `json:"clicks"`
     Impressions    int     `json:"impressions"`
     Cost           float64 `json:"cost"`
-    Leads          int     `json:"leads"`
-    Opportunities  int     `json:"opportunities"`
-    ClosedWon      int     `json:"closed_won"`
+    Leads          float64 `json:"leads"`
+    Opportunities  float64 `json:"opportunities"`
+    ClosedWon      float64 `json:"closed_won"`
     Revenue        float64 `json:"revenue"`
     CPC            float64 `json:"cpc"`
     CPA            float64 `json:"cpa"`
@@ -20,3 +26,40 @@ type Metrics struct {
     UTMSource      string  `json:"utm_source"`
     UTMMedium      string  `json:"utm_medium"`
 }
+
+// MetricsCSVHeader devuelve las columnas CSV en el mismo orden que los campos de Metrics
+func MetricsCSVHeader() []string {
+    return []string{
+        "date", "channel", "campaign_id", "clicks", "impressions", "cost",
+        "leads", "opportunities", "closed_won", "revenue", "cpc", "cpa",
+        "cvr_lead_to_opp", "cvr_opp_to_won", "roas", "utm_campaign", "utm_source", "utm_medium",
+    }
+}
+
+// CSVRecord devuelve la fila CSV correspondiente a MetricsCSVHeader
+func (m Metrics) CSVRecord() []string {
+    return []string{
+        m.Date,
+        m.Channel,
+        m.CampaignID,
+        strconv.Itoa(m.Clicks),
+        strconv.Itoa(m.Impressions),
+        formatFloat(m.Cost),
+        formatFloat(m.Leads),
+        formatFloat(m.Opportunities),
+        formatFloat(m.ClosedWon),
+        formatFloat(m.Revenue),
+        formatFloat(m.CPC),
+        formatFloat(m.CPA),
+        formatFloat(m.CVRLeadToOpp),
+        formatFloat(m.CVROppToWon),
+        formatFloat(m.ROAS),
+        m.UTMCampaign,
+        m.UTMSource,
+        m.UTMMedium,
+    }
+}
+
+func formatFloat(v float64) string {
+    return strconv.FormatFloat(v, 'f', -1, 64)
+}
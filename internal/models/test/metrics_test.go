@@ -0,0 +1,47 @@
+package test
+
+import (
+    "encoding/json"
+    "reflect"
+    "strings"
+    "testing"
+    "admira-etl/internal/models"
+)
+
+func TestMetricsCSVHeader_MatchesStructFields(t *testing.T) {
+    metricsType := reflect.TypeOf(models.Metrics{})
+    header := models.MetricsCSVHeader()
+
+    if len(header) != metricsType.NumField() {
+        t.Fatalf("Expected %d columns, got %d", metricsType.NumField(), len(header))
+    }
+    for i, column := range header {
+        tag := strings.Split(metricsType.Field(i).Tag.Get("json"), ",")[0]
+        if column != tag {
+            t.Errorf("Column %d: expected %s, got %s", i, tag, column)
+        }
+    }
+}
+
+func TestMetricsCSVRecord(t *testing.T) {
+    record := models.Metrics{Date: "2025-08-01", Channel: "google_ads", Clicks: 10, Cost: 2.5, Leads: 0.5}.CSVRecord()
+
+    if len(record) != len(models.MetricsCSVHeader()) {
+        t.Fatalf("Record and header lengths differ: %d", len(record))
+    }
+    if record[0] != "2025-08-01" || record[3] != "10" || record[5] != "2.5" || record[6] != "0.5" {
+        t.Errorf("Unexpected record: %v", record)
+    }
+}
+
+func TestMetrics_WholeCountsKeepIntegerOutput(t *testing.T) {
+    metric := models.Metrics{Date: "2025-08-01", Leads: 2, Opportunities: 1, ClosedWon: 0.5}
+
+    encoded, _ := json.Marshal(metric)
+    if !strings.Contains(string(encoded), `"leads":2,"opportunities":1,"closed_won":0.5`) {
+        t.Errorf("Expected whole counts without decimals in JSON, got %s", encoded)
+    }
+    if record := metric.CSVRecord(); record[6] != "2" || record[7] != "1" || record[8] != "0.5" {
+        t.Errorf("Expected whole counts without decimals in CSV, got %v", record)
+    }
+}
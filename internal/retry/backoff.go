@@ -0,0 +1,44 @@
+package retry
+
+import (
+    "math"
+    "math/rand"
+    "time"
+)
+
+// Backoff calcula esperas exponenciales con jitter completo: la espera del
+// intento n es un valor aleatorio en [0, min(Max, Base*Multiplier^n)]
+type Backoff struct {
+    Base       time.Duration
+    Max        time.Duration
+    Multiplier float64
+}
+
+// Ceiling devuelve la espera máxima del intento (0 = primer reintento), sin jitter
+func (b Backoff) Ceiling(attempt int) time.Duration {
+    multiplier := b.Multiplier
+    if multiplier < 1 {
+        multiplier = 2
+    }
+    if attempt < 0 {
+        attempt = 0
+    }
+
+    ceiling := float64(b.Base) * math.Pow(multiplier, float64(attempt))
+    if b.Max > 0 && ceiling > float64(b.Max) {
+        ceiling = float64(b.Max)
+    }
+    if ceiling > math.MaxInt64 {
+        ceiling = math.MaxInt64
+    }
+    return time.Duration(ceiling)
+}
+
+// Delay devuelve la espera con jitter del intento indicado
+func (b Backoff) Delay(attempt int) time.Duration {
+    ceiling := b.Ceiling(attempt)
+    if ceiling <= 0 {
+        return 0
+    }
+    return time.Duration(rand.Int63n(int64(ceiling) + 1))
+}
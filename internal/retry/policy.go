@@ -0,0 +1,186 @@
+package retry
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "io"
+    "net"
+    "net/http"
+    "strconv"
+    "strings"
+    "syscall"
+    "time"
+)
+
+// Attempt describe un intento de una operación con reintentos
+type Attempt struct {
+    Number     int       `json:"attempt"`
+    StartedAt  time.Time `json:"started_at"`
+    DurationMs int64     `json:"duration_ms"`
+    StatusCode int       `json:"status_code,omitempty"`
+    Error      string    `json:"error,omitempty"`
+    Retryable  bool      `json:"retryable"`
+    // RetryAfterMs es la espera pedida por el servidor con Retry-After
+    RetryAfterMs int64 `json:"retry_after_ms,omitempty"`
+    // WaitMs es la espera antes del siguiente intento (0 si fue el último)
+    WaitMs int64 `json:"wait_ms,omitempty"`
+}
+
+// Policy reintenta una operación con backoff exponencial y jitter completo.
+// Solo se reintentan los errores transitorios (ver Retryable) y, si el
+// servidor envía Retry-After, se espera lo que pide en lugar del backoff.
+type Policy struct {
+    // MaxAttempts incluye el primer intento
+    MaxAttempts int
+    Backoff     Backoff
+    // MaxRetryAfter limita el Retry-After que se respeta; si el servidor pide
+    // esperar más se deja de reintentar (0 = sin límite)
+    MaxRetryAfter time.Duration
+}
+
+// Do ejecuta fn hasta que tiene éxito, falla con un error no transitorio, se
+// agotan los intentos o se cancela el contexto. Devuelve todos los intentos.
+func (p Policy) Do(ctx context.Context, fn func(ctx context.Context) error) ([]Attempt, error) {
+    maxAttempts := p.MaxAttempts
+    if maxAttempts < 1 {
+        maxAttempts = 1
+    }
+
+    var attempts []Attempt
+    for n := 1; ; n++ {
+        start := time.Now()
+        err := fn(ctx)
+        attempt := Attempt{
+            Number:     n,
+            StartedAt:  start.UTC(),
+            DurationMs: time.Since(start).Milliseconds(),
+        }
+        if err == nil {
+            return append(attempts, attempt), nil
+        }
+
+        attempt.Error = err.Error()
+        attempt.StatusCode = StatusCode(err)
+        attempt.Retryable = Retryable(err) && ctx.Err() == nil
+        if !attempt.Retryable {
+            return append(attempts, attempt), err
+        }
+        if n >= maxAttempts {
+            return append(attempts, attempt), fmt.Errorf("failed after %d attempts: %w", n, err)
+        }
+
+        wait := p.Backoff.Delay(n - 1)
+        var httpErr *HTTPError
+        if errors.As(err, &httpErr) && httpErr.RetryAfter > 0 {
+            attempt.RetryAfterMs = httpErr.RetryAfter.Milliseconds()
+            if p.MaxRetryAfter > 0 && httpErr.RetryAfter > p.MaxRetryAfter {
+                return append(attempts, attempt), fmt.Errorf("retry-after of %s exceeds the limit of %s: %w", httpErr.RetryAfter, p.MaxRetryAfter, err)
+            }
+            wait = httpErr.RetryAfter
+        }
+        attempt.WaitMs = wait.Milliseconds()
+        attempts = append(attempts, attempt)
+
+        if err := Sleep(ctx, wait); err != nil {
+            return attempts, err
+        }
+    }
+}
+
+// Sleep espera d o hasta que se cancele el contexto
+func Sleep(ctx context.Context, d time.Duration) error {
+    if d <= 0 {
+        return ctx.Err()
+    }
+
+    timer := time.NewTimer(d)
+    defer timer.Stop()
+    select {
+    case <-ctx.Done():
+        return ctx.Err()
+    case <-timer.C:
+        return nil
+    }
+}
+
+// HTTPError es una respuesta HTTP que no fue 2xx
+type HTTPError struct {
+    StatusCode int
+    Status     string
+    // RetryAfter es la espera indicada en la cabecera Retry-After (0 = ninguna)
+    RetryAfter time.Duration
+}
+
+func (e *HTTPError) Error() string {
+    return fmt.Sprintf("HTTP error: %s", e.Status)
+}
+
+// CheckResponse devuelve un *HTTPError si la respuesta no es 2xx
+func CheckResponse(resp *http.Response) error {
+    if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+        return nil
+    }
+    retryAfter, _ := ParseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+    return &HTTPError{StatusCode: resp.StatusCode, Status: resp.Status, RetryAfter: retryAfter}
+}
+
+// ParseRetryAfter interpreta Retry-After en segundos o como fecha HTTP
+func ParseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+    value = strings.TrimSpace(value)
+    if value == "" {
+        return 0, false
+    }
+    if seconds, err := strconv.Atoi(value); err == nil {
+        if seconds < 0 {
+            return 0, false
+        }
+        return time.Duration(seconds) * time.Second, true
+    }
+    if at, err := http.ParseTime(value); err == nil {
+        if wait := at.Sub(now); wait > 0 {
+            return wait, true
+        }
+        return 0, true
+    }
+    return 0, false
+}
+
+// Retryable indica si un error es transitorio: timeouts, errores de red
+// (conexión rechazada o reiniciada, respuesta cortada), 429 y 5xx. Los demás
+// (4xx, cancelación) se devuelven sin reintentar.
+func Retryable(err error) bool {
+    if err == nil || errors.Is(err, context.Canceled) {
+        return false
+    }
+
+    var httpErr *HTTPError
+    if errors.As(err, &httpErr) {
+        return httpErr.StatusCode == http.StatusTooManyRequests || httpErr.StatusCode >= 500
+    }
+
+    var netErr net.Error
+    if errors.As(err, &netErr) && netErr.Timeout() {
+        return true
+    }
+    var opErr *net.OpError
+    if errors.As(err, &opErr) {
+        return true
+    }
+    switch {
+    case errors.Is(err, syscall.ECONNREFUSED), errors.Is(err, syscall.ECONNRESET):
+        return true
+    case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+        return true
+    }
+    return errors.Is(err, context.DeadlineExceeded)
+}
+
+// StatusCode devuelve el código HTTP de un *HTTPError o 0
+func StatusCode(err error) int {
+    var httpErr *HTTPError
+    if errors.As(err, &httpErr) {
+        return httpErr.StatusCode
+    }
+    return 0
+}
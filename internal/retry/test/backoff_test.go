@@ -0,0 +1,28 @@
+package test
+
+import (
+    "testing"
+    "time"
+    "admira-etl/internal/retry"
+)
+
+func TestBackoff_CeilingIsExponentialAndCapped(t *testing.T) {
+    b := retry.Backoff{Base: 100 * time.Millisecond, Max: time.Second}
+
+    expected := []time.Duration{100, 200, 400, 800, 1000, 1000}
+    for attempt, want := range expected {
+        if got := b.Ceiling(attempt); got != want*time.Millisecond {
+            t.Errorf("attempt %d: expected %v, got %v", attempt, want*time.Millisecond, got)
+        }
+    }
+}
+
+func TestBackoff_DelayHasFullJitter(t *testing.T) {
+    b := retry.Backoff{Base: 100 * time.Millisecond, Max: time.Second}
+
+    for i := 0; i < 100; i++ {
+        if d := b.Delay(3); d < 0 || d > 800*time.Millisecond {
+            t.Fatalf("Delay out of range: %v", d)
+        }
+    }
+}
@@ -0,0 +1,126 @@
+package test
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "io"
+    "net"
+    "net/http"
+    "net/url"
+    "os"
+    "syscall"
+    "testing"
+    "time"
+    "admira-etl/internal/retry"
+)
+
+func TestPolicy_RetriesTransientErrorsHonouringRetryAfter(t *testing.T) {
+    policy := retry.Policy{MaxAttempts: 3, Backoff: retry.Backoff{Base: time.Millisecond, Max: time.Millisecond}}
+
+    calls := 0
+    attempts, err := policy.Do(context.Background(), func(ctx context.Context) error {
+        calls++
+        if calls == 1 {
+            return &retry.HTTPError{StatusCode: http.StatusTooManyRequests, Status: "429 Too Many Requests", RetryAfter: 20 * time.Millisecond}
+        }
+        if calls == 2 {
+            return &retry.HTTPError{StatusCode: http.StatusBadGateway, Status: "502 Bad Gateway"}
+        }
+        return nil
+    })
+    if err != nil {
+        t.Fatalf("Expected success on the third attempt, got %v", err)
+    }
+    if len(attempts) != 3 || attempts[0].StatusCode != 429 || attempts[0].WaitMs != 20 || attempts[1].WaitMs > 1 || attempts[2].Error != "" {
+        t.Errorf("Unexpected attempts: %+v", attempts)
+    }
+}
+
+func TestPolicy_DoesNotRetryClientErrors(t *testing.T) {
+    policy := retry.Policy{MaxAttempts: 5, Backoff: retry.Backoff{Base: time.Millisecond}}
+
+    attempts, err := policy.Do(context.Background(), func(ctx context.Context) error {
+        return &retry.HTTPError{StatusCode: http.StatusNotFound, Status: "404 Not Found"}
+    })
+    if err == nil || len(attempts) != 1 || attempts[0].Retryable {
+        t.Errorf("Expected a single non-retryable attempt, got %+v (%v)", attempts, err)
+    }
+}
+
+func TestPolicy_WaitStopsOnCancel(t *testing.T) {
+    policy := retry.Policy{MaxAttempts: 3, Backoff: retry.Backoff{Base: time.Hour, Max: time.Hour}}
+
+    ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+    defer cancel()
+
+    start := time.Now()
+    _, err := policy.Do(ctx, func(ctx context.Context) error {
+        return &retry.HTTPError{StatusCode: http.StatusServiceUnavailable, Status: "503 Service Unavailable", RetryAfter: time.Hour}
+    })
+    if !errors.Is(err, context.DeadlineExceeded) || time.Since(start) > time.Second {
+        t.Errorf("Expected the wait to stop with the context, got %v after %v", err, time.Since(start))
+    }
+}
+
+func TestParseRetryAfter(t *testing.T) {
+    now := time.Date(2025, 8, 1, 12, 0, 0, 0, time.UTC)
+
+    if wait, ok := retry.ParseRetryAfter("120", now); !ok || wait != 2*time.Minute {
+        t.Errorf("Expected 2m from seconds, got %v %v", wait, ok)
+    }
+    if wait, ok := retry.ParseRetryAfter("Fri, 01 Aug 2025 12:00:30 GMT", now); !ok || wait != 30*time.Second {
+        t.Errorf("Expected 30s from HTTP date, got %v %v", wait, ok)
+    }
+    if _, ok := retry.ParseRetryAfter("soon", now); ok {
+        t.Errorf("Expected invalid Retry-After to be ignored")
+    }
+}
+
+func TestRetryable_ClassifiesTransientErrors(t *testing.T) {
+    cases := []struct {
+        name string
+        err  error
+        want bool
+    }{
+        {"503", &retry.HTTPError{StatusCode: http.StatusServiceUnavailable}, true},
+        {"404", &retry.HTTPError{StatusCode: http.StatusNotFound}, false},
+        {"op error", &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("no route to host")}, true},
+        {"connection refused", &url.Error{Op: "Get", URL: "http://upstream", Err: &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}}, true},
+        {"connection reset", fmt.Errorf("read body: %w", syscall.ECONNRESET), true},
+        {"eof", &url.Error{Op: "Get", URL: "http://upstream", Err: io.EOF}, true},
+        {"unexpected eof", fmt.Errorf("read body: %w", io.ErrUnexpectedEOF), true},
+        {"deadline", context.DeadlineExceeded, true},
+        {"canceled", &url.Error{Op: "Get", URL: "http://upstream", Err: context.Canceled}, false},
+        {"decode", errors.New("invalid character"), false},
+    }
+
+    for _, tc := range cases {
+        if got := retry.Retryable(tc.err); got != tc.want {
+            t.Errorf("%s: expected Retryable=%v, got %v", tc.name, tc.want, got)
+        }
+    }
+}
+
+func TestPolicy_RetriesRefusedConnections(t *testing.T) {
+    // Un puerto sin nadie escuchando rechaza la conexión
+    listener, err := net.Listen("tcp", "127.0.0.1:0")
+    if err != nil {
+        t.Fatalf("Listen failed: %v", err)
+    }
+    addr := listener.Addr().String()
+    listener.Close()
+
+    policy := retry.Policy{MaxAttempts: 2, Backoff: retry.Backoff{Base: time.Millisecond, Max: time.Millisecond}}
+    attempts, err := policy.Do(context.Background(), func(ctx context.Context) error {
+        req, _ := http.NewRequestWithContext(ctx, "GET", "http://"+addr, nil)
+        resp, err := http.DefaultClient.Do(req)
+        if err == nil {
+            resp.Body.Close()
+        }
+        return err
+    })
+    if err == nil || len(attempts) != 2 || !attempts[0].Retryable {
+        t.Errorf("Expected the refused connection to be retried, got %+v (%v)", attempts, err)
+    }
+}
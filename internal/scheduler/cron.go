@@ -0,0 +1,158 @@
+package scheduler
+
+import (
+    "fmt"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// Schedule es una expresión cron de 5 campos: minuto hora día-mes mes día-semana
+type Schedule struct {
+    minute, hour, dom, month, dow uint64
+    domAny, dowAny                bool
+}
+
+type fieldRange struct {
+    min, max int
+}
+
+var (
+    minuteRange = fieldRange{0, 59}
+    hourRange   = fieldRange{0, 23}
+    domRange    = fieldRange{1, 31}
+    monthRange  = fieldRange{1, 12}
+    dowRange    = fieldRange{0, 7}
+)
+
+var descriptors = map[string]string{
+    "@hourly":   "0 * * * *",
+    "@daily":    "0 0 * * *",
+    "@midnight": "0 0 * * *",
+    "@weekly":   "0 0 * * 0",
+    "@monthly":  "0 0 1 * *",
+    "@yearly":   "0 0 1 1 *",
+}
+
+// ParseCron interpreta expresiones como "0 * * * *", "*/15 8-18 * * 1-5" o "@daily"
+func ParseCron(spec string) (*Schedule, error) {
+    spec = strings.TrimSpace(spec)
+    if expanded, ok := descriptors[spec]; ok {
+        spec = expanded
+    }
+
+    fields := strings.Fields(spec)
+    if len(fields) != 5 {
+        return nil, fmt.Errorf("invalid cron expression %q: expected 5 fields", spec)
+    }
+
+    s := &Schedule{}
+    var err error
+    if s.minute, err = parseField(fields[0], minuteRange); err != nil {
+        return nil, fmt.Errorf("invalid minute field: %v", err)
+    }
+    if s.hour, err = parseField(fields[1], hourRange); err != nil {
+        return nil, fmt.Errorf("invalid hour field: %v", err)
+    }
+    if s.dom, err = parseField(fields[2], domRange); err != nil {
+        return nil, fmt.Errorf("invalid day-of-month field: %v", err)
+    }
+    if s.month, err = parseField(fields[3], monthRange); err != nil {
+        return nil, fmt.Errorf("invalid month field: %v", err)
+    }
+    if s.dow, err = parseField(fields[4], dowRange); err != nil {
+        return nil, fmt.Errorf("invalid day-of-week field: %v", err)
+    }
+
+    // 7 también es domingo
+    if s.dow&(1<<7) != 0 {
+        s.dow |= 1
+    }
+    s.domAny = fields[2] == "*"
+    s.dowAny = fields[4] == "*"
+    return s, nil
+}
+
+func parseField(field string, r fieldRange) (uint64, error) {
+    var bits uint64
+    for _, part := range strings.Split(field, ",") {
+        step := 1
+        if i := strings.Index(part, "/"); i >= 0 {
+            n, err := strconv.Atoi(part[i+1:])
+            if err != nil || n <= 0 {
+                return 0, fmt.Errorf("invalid step in %q", part)
+            }
+            step = n
+            part = part[:i]
+        }
+
+        lo, hi := r.min, r.max
+        switch {
+        case part == "*":
+        case strings.Contains(part, "-"):
+            bounds := strings.SplitN(part, "-", 2)
+            var err error
+            if lo, err = strconv.Atoi(bounds[0]); err != nil {
+                return 0, fmt.Errorf("invalid range %q", part)
+            }
+            if hi, err = strconv.Atoi(bounds[1]); err != nil {
+                return 0, fmt.Errorf("invalid range %q", part)
+            }
+        default:
+            n, err := strconv.Atoi(part)
+            if err != nil {
+                return 0, fmt.Errorf("invalid value %q", part)
+            }
+            lo, hi = n, n
+            if step > 1 {
+                hi = r.max
+            }
+        }
+
+        if lo < r.min || hi > r.max || lo > hi {
+            return 0, fmt.Errorf("value out of range [%d-%d] in %q", r.min, r.max, part)
+        }
+        for v := lo; v <= hi; v += step {
+            bits |= 1 << uint(v)
+        }
+    }
+    return bits, nil
+}
+
+// Next devuelve el primer instante estrictamente posterior a t que cumple la expresión
+func (s *Schedule) Next(t time.Time) time.Time {
+    t = t.Truncate(time.Minute).Add(time.Minute)
+    limit := t.AddDate(5, 0, 0)
+
+    for t.Before(limit) {
+        if s.month&(1<<uint(t.Month())) == 0 {
+            t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+            continue
+        }
+        if !s.dayMatches(t) {
+            t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+            continue
+        }
+        if s.hour&(1<<uint(t.Hour())) == 0 {
+            t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+            continue
+        }
+        if s.minute&(1<<uint(t.Minute())) == 0 {
+            t = t.Add(time.Minute)
+            continue
+        }
+        return t
+    }
+    return time.Time{}
+}
+
+// dayMatches sigue la semántica clásica de cron: si ambos campos de día están
+// restringidos basta con que coincida uno de los dos
+func (s *Schedule) dayMatches(t time.Time) bool {
+    domMatch := s.dom&(1<<uint(t.Day())) != 0
+    dowMatch := s.dow&(1<<uint(t.Weekday())) != 0
+    if s.domAny || s.dowAny {
+        return domMatch && dowMatch
+    }
+    return domMatch || dowMatch
+}
@@ -0,0 +1,382 @@
+package scheduler
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "sync"
+    "time"
+
+    "admira-etl/internal/storage"
+)
+
+// MissedPolicy decide qué hacer con las ejecuciones que no se lanzaron a su hora
+// (servicio caído o ejecución anterior todavía en curso)
+type MissedPolicy string
+
+const (
+    MissedSkip    MissedPolicy = "skip"
+    MissedCatchUp MissedPolicy = "catch_up"
+)
+
+// maxCatchUp limita cuántas ejecuciones atrasadas se recuperan de una vez
+const maxCatchUp = 24
+
+// runHistoryLimit es el número de ejecuciones que se conservan para consulta
+const runHistoryLimit = 500
+
+type RunStatus string
+
+const (
+    RunSucceeded RunStatus = "succeeded"
+    RunFailed    RunStatus = "failed"
+    RunSkipped   RunStatus = "skipped"
+)
+
+// Task ejecuta el trabajo programado para el instante scheduledFor
+type Task func(ctx context.Context, scheduledFor time.Time) (map[string]interface{}, error)
+
+// Run es el resultado de una ejecución programada
+type Run struct {
+    ID           string                 `json:"id"`
+    Entry        string                 `json:"entry"`
+    ScheduledFor time.Time              `json:"scheduled_for"`
+    StartedAt    time.Time              `json:"started_at"`
+    FinishedAt   time.Time              `json:"finished_at"`
+    Status       RunStatus              `json:"status"`
+    Error        string                 `json:"error,omitempty"`
+    Details      map[string]interface{} `json:"details,omitempty"`
+}
+
+// EntryStatus describe una tarea programada para la API
+type EntryStatus struct {
+    Name          string       `json:"name"`
+    Spec          string       `json:"spec"`
+    MissedPolicy  MissedPolicy `json:"missed_policy"`
+    Running       bool         `json:"running"`
+    NextRun       *time.Time   `json:"next_run,omitempty"`
+    LastScheduled *time.Time   `json:"last_scheduled,omitempty"`
+    LastRun       *Run         `json:"last_run,omitempty"`
+}
+
+type entry struct {
+    name          string
+    spec          string
+    schedule      *Schedule
+    missed        MissedPolicy
+    task          Task
+    running       bool
+    next          time.Time
+    lastScheduled time.Time
+}
+
+// Scheduler lanza tareas según expresiones cron. Cada tarea corre en su propia
+// goroutine y de forma secuencial, por lo que nunca se solapan consigo mismas.
+type Scheduler struct {
+    mu       sync.RWMutex
+    location *time.Location
+    entries  []*entry
+    runs     []Run
+    journal  *storage.Journal
+    seq      int
+    ctx      context.Context
+    cancel   context.CancelFunc
+    wg       sync.WaitGroup
+}
+
+// New crea un scheduler. Si journal no es nil, las ejecuciones se persisten y
+// al arrancar se recuperan las ejecuciones perdidas mientras el servicio estuvo
+// caído; el scheduler pasa a ser su dueño y lo cierra en Stop.
+func New(location *time.Location, journal *storage.Journal) (*Scheduler, error) {
+    if location == nil {
+        location = time.UTC
+    }
+
+    ctx, cancel := context.WithCancel(context.Background())
+    s := &Scheduler{
+        location: location,
+        journal:  journal,
+        ctx:      ctx,
+        cancel:   cancel,
+    }
+
+    if journal != nil {
+        err := journal.Replay(func(line []byte) error {
+            var run Run
+            if err := json.Unmarshal(line, &run); err != nil {
+                return err
+            }
+            s.runs = append(s.runs, run)
+            return nil
+        })
+        if err != nil {
+            cancel()
+            return nil, fmt.Errorf("failed to restore schedule runs: %v", err)
+        }
+
+        if len(s.runs) > runHistoryLimit {
+            s.runs = s.runs[len(s.runs)-runHistoryLimit:]
+            entries := make([]interface{}, len(s.runs))
+            for i := range s.runs {
+                entries[i] = s.runs[i]
+            }
+            if err := journal.Rewrite(entries); err != nil {
+                cancel()
+                return nil, err
+            }
+        }
+        s.seq = len(s.runs)
+    }
+
+    return s, nil
+}
+
+// Add registra una tarea; debe llamarse antes de Start
+func (s *Scheduler) Add(name, spec string, missed MissedPolicy, task Task) error {
+    schedule, err := ParseCron(spec)
+    if err != nil {
+        return fmt.Errorf("schedule %s: %v", name, err)
+    }
+    if missed == "" {
+        missed = MissedSkip
+    }
+    if missed != MissedSkip && missed != MissedCatchUp {
+        return fmt.Errorf("schedule %s: unknown missed policy %q", name, missed)
+    }
+
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    e := &entry{
+        name:     name,
+        spec:     spec,
+        schedule: schedule,
+        missed:   missed,
+        task:     task,
+    }
+
+    // Retomar desde la última ejecución persistida; si no hay, empezar ahora
+    e.lastScheduled = time.Now().In(s.location)
+    for _, run := range s.runs {
+        if run.Entry == name && !run.ScheduledFor.IsZero() {
+            e.lastScheduled = run.ScheduledFor.In(s.location)
+        }
+    }
+    e.next = schedule.Next(e.lastScheduled)
+
+    s.entries = append(s.entries, e)
+    return nil
+}
+
+func (s *Scheduler) Start() {
+    s.mu.RLock()
+    defer s.mu.RUnlock()
+
+    for _, e := range s.entries {
+        s.wg.Add(1)
+        go s.loop(e)
+    }
+}
+
+// Stop cancela las ejecuciones en curso, espera a que terminen y cierra el journal
+func (s *Scheduler) Stop() {
+    s.cancel()
+    s.wg.Wait()
+    if s.journal != nil {
+        s.journal.Close()
+    }
+}
+
+func (s *Scheduler) loop(e *entry) {
+    defer s.wg.Done()
+
+    for {
+        s.mu.RLock()
+        next := e.next
+        s.mu.RUnlock()
+        if next.IsZero() {
+            return
+        }
+
+        timer := time.NewTimer(time.Until(next))
+        select {
+        case <-s.ctx.Done():
+            timer.Stop()
+            return
+        case <-timer.C:
+        }
+
+        for _, scheduledFor := range s.due(e) {
+            if s.ctx.Err() != nil {
+                return
+            }
+            s.execute(e, scheduledFor)
+        }
+    }
+}
+
+// due calcula las ejecuciones pendientes hasta ahora y aplica la política de
+// ejecuciones perdidas: catch_up las devuelve todas (hasta maxCatchUp) y skip
+// solo la más reciente, registrando las demás como omitidas
+func (s *Scheduler) due(e *entry) []time.Time {
+    now := time.Now().In(s.location)
+
+    s.mu.Lock()
+    var pending []time.Time
+    for t := e.next; !t.IsZero() && !t.After(now); t = e.schedule.Next(t) {
+        pending = append(pending, t)
+    }
+    if len(pending) > 0 {
+        e.lastScheduled = pending[len(pending)-1]
+    }
+    e.next = e.schedule.Next(maxTime(e.lastScheduled, now))
+    s.mu.Unlock()
+
+    var skipped []time.Time
+    if e.missed == MissedCatchUp {
+        if len(pending) > maxCatchUp {
+            skipped = pending[:len(pending)-maxCatchUp]
+            pending = pending[len(pending)-maxCatchUp:]
+        }
+    } else if len(pending) > 1 {
+        skipped = pending[:len(pending)-1]
+        pending = pending[len(pending)-1:]
+    }
+
+    if len(skipped) > 0 {
+        s.record(Run{
+            Entry:        e.name,
+            ScheduledFor: skipped[len(skipped)-1],
+            StartedAt:    now.UTC(),
+            FinishedAt:   now.UTC(),
+            Status:       RunSkipped,
+            Error:        fmt.Sprintf("%d missed run(s) skipped since %s", len(skipped), skipped[0].Format(time.RFC3339)),
+        })
+    }
+    return pending
+}
+
+func (s *Scheduler) execute(e *entry, scheduledFor time.Time) {
+    s.mu.Lock()
+    e.running = true
+    s.mu.Unlock()
+
+    run := Run{
+        Entry:        e.name,
+        ScheduledFor: scheduledFor.UTC(),
+        StartedAt:    time.Now().UTC(),
+    }
+    details, err := safeRun(s.ctx, e.task, scheduledFor)
+    run.FinishedAt = time.Now().UTC()
+    run.Details = details
+    if err != nil {
+        run.Status = RunFailed
+        run.Error = err.Error()
+    } else {
+        run.Status = RunSucceeded
+    }
+
+    s.mu.Lock()
+    e.running = false
+    s.mu.Unlock()
+
+    s.record(run)
+    fmt.Printf("Debug: Ejecución programada %s (%s) terminada: %s\n", e.name, scheduledFor.Format(time.RFC3339), run.Status)
+}
+
+func safeRun(ctx context.Context, task Task, scheduledFor time.Time) (details map[string]interface{}, err error) {
+    defer func() {
+        if r := recover(); r != nil {
+            err = fmt.Errorf("scheduled task panicked: %v", r)
+        }
+    }()
+    return task(ctx, scheduledFor)
+}
+
+func (s *Scheduler) record(run Run) {
+    s.mu.Lock()
+    s.seq++
+    run.ID = fmt.Sprintf("run_%d_%d", run.StartedAt.Unix(), s.seq)
+    s.runs = append(s.runs, run)
+    if len(s.runs) > runHistoryLimit {
+        s.runs = s.runs[len(s.runs)-runHistoryLimit:]
+    }
+    s.mu.Unlock()
+
+    if s.journal != nil {
+        if err := s.journal.Append(run); err != nil {
+            fmt.Printf("Debug: Error persistiendo ejecución %s: %v\n", run.ID, err)
+        }
+    }
+}
+
+// Entries devuelve el estado de las tareas registradas
+func (s *Scheduler) Entries() []EntryStatus {
+    s.mu.RLock()
+    defer s.mu.RUnlock()
+
+    result := make([]EntryStatus, 0, len(s.entries))
+    for _, e := range s.entries {
+        status := EntryStatus{
+            Name:         e.name,
+            Spec:         e.spec,
+            MissedPolicy: e.missed,
+            Running:      e.running,
+        }
+        if !e.next.IsZero() {
+            next := e.next.UTC()
+            status.NextRun = &next
+        }
+        if !e.lastScheduled.IsZero() {
+            last := e.lastScheduled.UTC()
+            status.LastScheduled = &last
+        }
+        for i := len(s.runs) - 1; i >= 0; i-- {
+            if s.runs[i].Entry == e.name {
+                run := s.runs[i]
+                status.LastRun = &run
+                break
+            }
+        }
+        result = append(result, status)
+    }
+    return result
+}
+
+// Runs devuelve las ejecuciones más recientes primero, opcionalmente de una sola tarea
+func (s *Scheduler) Runs(entryName string, limit int) []Run {
+    s.mu.RLock()
+    defer s.mu.RUnlock()
+
+    result := make([]Run, 0)
+    for i := len(s.runs) - 1; i >= 0; i-- {
+        if entryName != "" && s.runs[i].Entry != entryName {
+            continue
+        }
+        result = append(result, s.runs[i])
+        if limit > 0 && len(result) >= limit {
+            break
+        }
+    }
+    return result
+}
+
+// Run busca una ejecución por ID
+func (s *Scheduler) Run(id string) (Run, bool) {
+    s.mu.RLock()
+    defer s.mu.RUnlock()
+
+    for _, run := range s.runs {
+        if run.ID == id {
+            return run, true
+        }
+    }
+    return Run{}, false
+}
+
+func maxTime(a, b time.Time) time.Time {
+    if a.After(b) {
+        return a
+    }
+    return b
+}
@@ -0,0 +1,41 @@
+package test
+
+import (
+    "testing"
+    "time"
+    "admira-etl/internal/scheduler"
+)
+
+func TestParseCron_Next(t *testing.T) {
+    base := time.Date(2024, 5, 3, 10, 30, 0, 0, time.UTC) // viernes
+
+    cases := []struct {
+        spec     string
+        expected time.Time
+    }{
+        {"0 * * * *", time.Date(2024, 5, 3, 11, 0, 0, 0, time.UTC)},
+        {"0 2 * * *", time.Date(2024, 5, 4, 2, 0, 0, 0, time.UTC)},
+        {"*/15 * * * *", time.Date(2024, 5, 3, 10, 45, 0, 0, time.UTC)},
+        {"0 9 * * 1-5", time.Date(2024, 5, 6, 9, 0, 0, 0, time.UTC)},
+        {"0 0 1 * *", time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)},
+        {"@daily", time.Date(2024, 5, 4, 0, 0, 0, 0, time.UTC)},
+    }
+
+    for _, tc := range cases {
+        schedule, err := scheduler.ParseCron(tc.spec)
+        if err != nil {
+            t.Fatalf("ParseCron(%q) failed: %v", tc.spec, err)
+        }
+        if next := schedule.Next(base); !next.Equal(tc.expected) {
+            t.Errorf("Next(%q) = %s, expected %s", tc.spec, next, tc.expected)
+        }
+    }
+}
+
+func TestParseCron_Invalid(t *testing.T) {
+    for _, spec := range []string{"", "* * * *", "60 * * * *", "0 25 * * *", "*/0 * * * *"} {
+        if _, err := scheduler.ParseCron(spec); err == nil {
+            t.Errorf("Expected error for %q", spec)
+        }
+    }
+}
@@ -0,0 +1,148 @@
+package test
+
+import (
+    "context"
+    "path/filepath"
+    "strings"
+    "sync"
+    "testing"
+    "time"
+    "admira-etl/internal/scheduler"
+    "admira-etl/internal/storage"
+)
+
+// schedulerWithMissedRuns crea un scheduler horario cuya última ejecución
+// persistida fue hace cuatro horas, de modo que al arrancar tiene cuatro pendientes
+func schedulerWithMissedRuns(t *testing.T, missed scheduler.MissedPolicy, task scheduler.Task) *scheduler.Scheduler {
+    journal, err := storage.OpenJournal(filepath.Join(t.TempDir(), "schedule_runs.jsonl"))
+    if err != nil {
+        t.Fatalf("OpenJournal failed: %v", err)
+    }
+    last := time.Now().UTC().Truncate(time.Hour).Add(-4 * time.Hour)
+    journal.Append(scheduler.Run{ID: "run_0", Entry: "ingest", ScheduledFor: last, Status: scheduler.RunSucceeded})
+
+    sched, err := scheduler.New(time.UTC, journal)
+    if err != nil {
+        t.Fatalf("New failed: %v", err)
+    }
+    if err := sched.Add("ingest", "0 * * * *", missed, task); err != nil {
+        t.Fatalf("Add failed: %v", err)
+    }
+    return sched
+}
+
+// waitForRuns espera a que haya n ejecuciones registradas además de la inicial
+func waitForRuns(t *testing.T, sched *scheduler.Scheduler, n int) []scheduler.Run {
+    deadline := time.Now().Add(2 * time.Second)
+    for time.Now().Before(deadline) {
+        if runs := sched.Runs("ingest", 0); len(runs) >= n+1 {
+            return runs[:len(runs)-1]
+        }
+        time.Sleep(10 * time.Millisecond)
+    }
+    t.Fatalf("Timed out waiting for %d runs, got %+v", n, sched.Runs("ingest", 0))
+    return nil
+}
+
+func TestScheduler_SkipRunsOnlyTheLatestMissedRun(t *testing.T) {
+    var mu sync.Mutex
+    var executed []time.Time
+    sched := schedulerWithMissedRuns(t, scheduler.MissedSkip, func(ctx context.Context, scheduledFor time.Time) (map[string]interface{}, error) {
+        mu.Lock()
+        executed = append(executed, scheduledFor)
+        mu.Unlock()
+        return nil, nil
+    })
+    sched.Start()
+    defer sched.Stop()
+
+    runs := waitForRuns(t, sched, 2)
+    // Más recientes primero: la ejecución de la última hora y el registro de las omitidas
+    if runs[0].Status != scheduler.RunSucceeded || runs[1].Status != scheduler.RunSkipped {
+        t.Fatalf("Expected one skipped record and one run, got %+v", runs)
+    }
+    if !strings.HasPrefix(runs[1].Error, "3 missed run(s) skipped") {
+        t.Errorf("Expected 3 skipped runs, got %q", runs[1].Error)
+    }
+
+    mu.Lock()
+    defer mu.Unlock()
+    if latest := time.Now().UTC().Truncate(time.Hour); len(executed) != 1 || !executed[0].Equal(latest) {
+        t.Errorf("Expected only %s to run, got %v", latest, executed)
+    }
+}
+
+func TestScheduler_CatchUpRunsEveryMissedRunWithoutOverlap(t *testing.T) {
+    var (
+        mu        sync.Mutex
+        running   int
+        overlaps  int
+        scheduled []time.Time
+    )
+    sched := schedulerWithMissedRuns(t, scheduler.MissedCatchUp, func(ctx context.Context, scheduledFor time.Time) (map[string]interface{}, error) {
+        mu.Lock()
+        running++
+        if running > 1 {
+            overlaps++
+        }
+        scheduled = append(scheduled, scheduledFor)
+        mu.Unlock()
+
+        time.Sleep(20 * time.Millisecond)
+
+        mu.Lock()
+        running--
+        mu.Unlock()
+        return nil, nil
+    })
+    sched.Start()
+    defer sched.Stop()
+
+    waitForRuns(t, sched, 4)
+
+    mu.Lock()
+    defer mu.Unlock()
+    if len(scheduled) != 4 || overlaps != 0 {
+        t.Fatalf("Expected 4 sequential runs, got %v with %d overlaps", scheduled, overlaps)
+    }
+    for i := 1; i < len(scheduled); i++ {
+        if !scheduled[i].After(scheduled[i-1]) {
+            t.Errorf("Expected missed runs in order, got %v", scheduled)
+        }
+    }
+}
+
+func TestScheduler_RunningEntryIsNotStartedTwice(t *testing.T) {
+    release := make(chan struct{})
+    var mu sync.Mutex
+    started := 0
+    sched := schedulerWithMissedRuns(t, scheduler.MissedSkip, func(ctx context.Context, scheduledFor time.Time) (map[string]interface{}, error) {
+        mu.Lock()
+        started++
+        mu.Unlock()
+        select {
+        case <-release:
+        case <-ctx.Done():
+        }
+        return nil, nil
+    })
+    sched.Start()
+    defer sched.Stop()
+
+    deadline := time.Now().Add(2 * time.Second)
+    for time.Now().Before(deadline) && !sched.Entries()[0].Running {
+        time.Sleep(10 * time.Millisecond)
+    }
+    if !sched.Entries()[0].Running {
+        t.Fatalf("Expected the entry to be running")
+    }
+
+    // Mientras la ejecución sigue en curso no se lanza otra
+    time.Sleep(100 * time.Millisecond)
+    mu.Lock()
+    if started != 1 {
+        t.Errorf("Expected a single execution while running, got %d", started)
+    }
+    mu.Unlock()
+    close(release)
+}
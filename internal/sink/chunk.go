@@ -0,0 +1,151 @@
+package sink
+
+import (
+    "bytes"
+    "crypto/rand"
+    "encoding/csv"
+    "encoding/hex"
+    "encoding/json"
+    "fmt"
+    "strconv"
+    "time"
+
+    "admira-etl/internal/models"
+)
+
+// ChunkOptions limita el tamaño de cada chunk; 0 no limita
+type ChunkOptions struct {
+    MaxRecords int
+    // MaxBytes se mide sobre el cuerpo que se envía, sobre incluido, en cada
+    // uno de Formats (JSON si no se indica ninguno): un chunk debe caber en
+    // todos los formatos de los sinks que lo reciben
+    MaxBytes int
+    Formats  []string
+}
+
+// Day son las métricas consolidadas de un día de la exportación
+type Day struct {
+    Date    string
+    Metrics []models.Metrics
+}
+
+// NewBatchID genera el identificador común a todos los chunks de una exportación
+func NewBatchID() string {
+    b := make([]byte, 8)
+    if _, err := rand.Read(b); err != nil {
+        return fmt.Sprintf("batch_%d", time.Now().UnixNano())
+    }
+    return "batch_" + hex.EncodeToString(b)
+}
+
+// Chunk divide una exportación en lotes: uno o más por día según los límites.
+// Cada lote copia de template el ID, el rango, GroupBy y ExportedAt; se
+// numeran de 1 a Total en orden de fecha.
+func Chunk(template Batch, days []Day, options ChunkOptions) []*Batch {
+    formats := options.Formats
+    if len(formats) == 0 {
+        formats = []string{FormatJSON}
+    }
+    total := 0
+    for _, day := range days {
+        total += len(day.Metrics)
+    }
+
+    var batches []*Batch
+    for _, day := range days {
+        if len(day.Metrics) == 0 {
+            continue
+        }
+
+        var envelope []int
+        if options.MaxBytes > 0 {
+            envelope = envelopeSizes(template, day.Date, total, formats)
+        }
+
+        var parts []*Batch
+        current := &Batch{Date: day.Date}
+        sizes := make([]int, len(formats))
+        copy(sizes, envelope)
+        for _, m := range day.Metrics {
+            full := options.MaxRecords > 0 && len(current.Metrics) >= options.MaxRecords
+            var recordSizes []int
+            if options.MaxBytes > 0 {
+                recordSizes = make([]int, len(formats))
+                for i, format := range formats {
+                    recordSizes[i] = recordSize(format, m)
+                    if len(current.Metrics) > 0 && sizes[i]+recordSizes[i] > options.MaxBytes {
+                        full = true
+                    }
+                }
+            }
+            if full {
+                parts = append(parts, current)
+                current = &Batch{Date: day.Date}
+                copy(sizes, envelope)
+            }
+
+            current.Metrics = append(current.Metrics, m)
+            for i := range recordSizes {
+                sizes[i] += recordSizes[i]
+            }
+        }
+        parts = append(parts, current)
+
+        for i, part := range parts {
+            part.Part = i + 1
+            part.Parts = len(parts)
+        }
+        batches = append(batches, parts...)
+    }
+
+    for i, batch := range batches {
+        batch.ID = template.ID
+        batch.Sequence = i + 1
+        batch.Total = len(batches)
+        batch.From, batch.To = template.From, template.To
+        batch.GroupBy = template.GroupBy
+        batch.ExportedAt = template.ExportedAt
+    }
+    return batches
+}
+
+// envelopeSizes mide en cada formato el cuerpo de un lote vacío del día. Los
+// contadores se fijan a total, su máximo posible, para no quedarse cortos.
+func envelopeSizes(template Batch, date string, total int, formats []string) []int {
+    probe := template
+    probe.Date = date
+    probe.Sequence, probe.Total = total, total
+    probe.Part, probe.Parts = total, total
+    probe.Metrics = nil
+
+    sizes := make([]int, len(formats))
+    for i, format := range formats {
+        if body, _, err := Encode(format, &probe); err == nil {
+            sizes[i] = len(body)
+        }
+        if format == FormatJSON {
+            // record_count tiene como mucho los dígitos de total
+            sizes[i] += len(strconv.Itoa(total))
+        }
+    }
+    return sizes
+}
+
+// recordSize es lo que añade un registro al cuerpo en el formato indicado,
+// separador incluido
+func recordSize(format string, m models.Metrics) int {
+    switch format {
+    case FormatCSV:
+        var buf bytes.Buffer
+        writer := csv.NewWriter(&buf)
+        writer.Write(m.CSVRecord())
+        writer.Flush()
+        return buf.Len()
+    default:
+        encoded, err := json.Marshal(m)
+        if err != nil {
+            return 0
+        }
+        return len(encoded) + 1
+    }
+}
@@ -0,0 +1,424 @@
+package sink
+
+import (
+    "context"
+    "fmt"
+    "sync"
+    "time"
+
+    "admira-etl/internal/retry"
+    "admira-etl/internal/storage"
+)
+
+// DispatcherOptions configura los reintentos del outbox
+type DispatcherOptions struct {
+    // MaxAttempts es el número de intentos antes de pasar a dead-letter
+    MaxAttempts  int
+    Backoff      retry.Backoff
+    PollInterval time.Duration
+    // PruneInterval es cada cuánto se purgan del outbox las entregas
+    // terminadas y se compacta su journal
+    PruneInterval time.Duration
+}
+
+// Dispatcher entrega los lotes del outbox a los sinks. Cada exportación se
+// escribe primero en el outbox y después se intenta entregar; los fallos se
+// reintentan en segundo plano con backoff exponencial y jitter. Cada intento
+// queda en el historial de exportaciones.
+type Dispatcher struct {
+    outbox  *storage.Outbox
+    history *storage.ExportHistory
+    sinks   []Sink
+    byName  map[string]Sink
+    options DispatcherOptions
+
+    // inflight son las entradas con un intento en curso o reservadas por una
+    // exportación que aún no las ha intentado
+    mu       sync.Mutex
+    inflight map[string]bool
+
+    wake   chan struct{}
+    ctx    context.Context
+    cancel context.CancelFunc
+    wg     sync.WaitGroup
+}
+
+// NewDispatcher crea el dispatcher; con history nil no se registran los intentos
+func NewDispatcher(outbox *storage.Outbox, history *storage.ExportHistory, sinks []Sink, options DispatcherOptions) *Dispatcher {
+    if options.MaxAttempts < 1 {
+        options.MaxAttempts = 1
+    }
+    if options.PollInterval <= 0 {
+        options.PollInterval = time.Second
+    }
+    if options.PruneInterval <= 0 {
+        options.PruneInterval = time.Hour
+    }
+
+    ctx, cancel := context.WithCancel(context.Background())
+    byName := make(map[string]Sink)
+    for _, s := range sinks {
+        byName[s.Name()] = s
+    }
+    return &Dispatcher{
+        outbox:   outbox,
+        history:  history,
+        sinks:    sinks,
+        byName:   byName,
+        options:  options,
+        inflight: make(map[string]bool),
+        wake:     make(chan struct{}, 1),
+        ctx:      ctx,
+        cancel:   cancel,
+    }
+}
+
+func (d *Dispatcher) Sinks() []Sink {
+    return d.sinks
+}
+
+func (d *Dispatcher) Outbox() *storage.Outbox {
+    return d.outbox
+}
+
+func (d *Dispatcher) History() *storage.ExportHistory {
+    return d.history
+}
+
+// Start lanza el bucle de reintentos
+func (d *Dispatcher) Start() {
+    d.wg.Add(1)
+    go d.loop()
+}
+
+// Stop detiene el bucle y espera a que termine el intento en curso
+func (d *Dispatcher) Stop() {
+    d.cancel()
+    d.wg.Wait()
+}
+
+// Notify despierta el bucle para revisar las entradas vencidas
+func (d *Dispatcher) Notify() {
+    select {
+    case d.wake <- struct{}{}:
+    default:
+    }
+}
+
+// Export escribe en el outbox cada chunk para cada sink y hace el primer
+// intento de entrega: en orden de secuencia dentro de un sink y en paralelo
+// entre sinks. Los resultados mantienen el orden de configuración. Las entradas
+// quedan reservadas hasta su primer intento para que el bucle de reintentos no
+// las entregue mientras tanto.
+func (d *Dispatcher) Export(ctx context.Context, batches []*Batch) ([]Result, error) {
+    entries := make([][]storage.OutboxEntry, len(d.sinks))
+    var claimed []string
+    for i, s := range d.sinks {
+        for _, batch := range batches {
+            entry, err := d.outbox.Enqueue(storage.OutboxEntry{
+                Sink:       s.Name(),
+                BatchID:    batch.ID,
+                Sequence:   batch.Sequence,
+                Total:      batch.Total,
+                From:       batch.From,
+                To:         batch.To,
+                Date:       batch.Date,
+                Part:       batch.Part,
+                Parts:      batch.Parts,
+                GroupBy:    batch.GroupBy,
+                Metrics:    batch.Metrics,
+                ExportedAt: batch.ExportedAt,
+            })
+            if err != nil {
+                // Los sinks anteriores ya tienen sus chunks en el outbox y los
+                // entregará el bucle de reintentos; este y los siguientes no
+                d.release(claimed...)
+                err = fmt.Errorf("failed to write outbox: %v", err)
+                d.recordFailure(d.sinks[i:], *batch, StatusFailed, err.Error())
+                return nil, err
+            }
+            d.mu.Lock()
+            d.inflight[entry.ID] = true
+            d.mu.Unlock()
+            claimed = append(claimed, entry.ID)
+            entries[i] = append(entries[i], entry)
+        }
+    }
+
+    results := make([]Result, len(d.sinks))
+    var wg sync.WaitGroup
+    for i, s := range d.sinks {
+        wg.Add(1)
+        go func(i int, s Sink) {
+            defer wg.Done()
+
+            result := Result{Name: s.Name(), Type: s.Type(), Format: s.Format(), Chunks: len(entries[i])}
+            for _, entry := range entries[i] {
+                result.add(d.attempt(ctx, entry, triggerExport, true))
+            }
+            results[i] = result
+        }(i, s)
+    }
+    wg.Wait()
+    return results, nil
+}
+
+// RecordFailure registra en el historial una exportación que no llegó al
+// outbox: una entrada por sink o, sin sinks configurados, una sin sink. Del
+// batch se usan el ID, el rango, Date y las métricas preparadas.
+func (d *Dispatcher) RecordFailure(batch Batch, status, reason string) {
+    d.recordFailure(d.sinks, batch, status, reason)
+}
+
+func (d *Dispatcher) recordFailure(sinks []Sink, batch Batch, status, reason string) {
+    if d.history == nil {
+        return
+    }
+
+    attempt := storage.ExportAttempt{
+        BatchID:  batch.ID,
+        From:     batch.From,
+        To:       batch.To,
+        Date:     batch.Date,
+        Sequence: batch.Sequence,
+        Total:    batch.Total,
+        Records:  len(batch.Metrics),
+        Attempt:  1,
+        Trigger:  triggerExport,
+        Status:   status,
+        Error:    reason,
+    }
+    if len(sinks) == 0 {
+        sinks = []Sink{nil}
+    }
+    for _, s := range sinks {
+        if s != nil {
+            attempt.Sink, attempt.SinkType, attempt.Format = s.Name(), s.Type(), s.Format()
+        }
+        if _, err := d.history.Record(attempt); err != nil {
+            fmt.Printf("Debug: Error registrando el fallo del lote %s en el historial: %v\n", batch.ID, err)
+        }
+    }
+}
+
+// delivery es el resultado de un intento de entrega de una entrada del outbox
+type delivery struct {
+    outboxID    string
+    status      string
+    location    string
+    err         string
+    duration    time.Duration
+    nextAttempt *time.Time
+}
+
+// add acumula en el resumen del sink el resultado de un chunk
+func (r *Result) add(d delivery) {
+    r.DurationMs += d.duration.Milliseconds()
+    if d.err != "" {
+        r.Error = d.err
+    }
+
+    switch d.status {
+    case StatusDelivered:
+        r.Delivered++
+        r.Location = d.location
+    case StatusRetrying:
+        r.Retrying++
+        if d.nextAttempt != nil && (r.NextAttemptAt == nil || d.nextAttempt.Before(*r.NextAttemptAt)) {
+            r.NextAttemptAt = d.nextAttempt
+        }
+    case StatusDead:
+        r.Dead++
+    }
+    if d.status != StatusDelivered {
+        r.PendingOutboxIDs = append(r.PendingOutboxIDs, d.outboxID)
+    }
+
+    switch {
+    case r.Dead > 0:
+        r.Status = StatusDead
+    case r.Retrying > 0:
+        r.Status = StatusRetrying
+    default:
+        r.Status = StatusDelivered
+    }
+}
+
+// Replay devuelve una entrada de la dead-letter list a la cola
+func (d *Dispatcher) Replay(id string) (storage.OutboxEntry, error) {
+    entry, err := d.outbox.Requeue(id)
+    if err != nil {
+        return storage.OutboxEntry{}, err
+    }
+    d.Notify()
+    return entry, nil
+}
+
+func (d *Dispatcher) loop() {
+    defer d.wg.Done()
+
+    ticker := time.NewTicker(d.options.PollInterval)
+    defer ticker.Stop()
+    pruneTicker := time.NewTicker(d.options.PruneInterval)
+    defer pruneTicker.Stop()
+
+    for {
+        select {
+        case <-d.ctx.Done():
+            return
+        case <-ticker.C:
+        case <-d.wake:
+        case <-pruneTicker.C:
+            // Un fallo al compactar se reintenta en el siguiente ciclo
+            d.outbox.Prune(time.Now())
+            continue
+        }
+
+        for _, entry := range d.outbox.Due(time.Now()) {
+            if d.ctx.Err() != nil {
+                return
+            }
+            d.attempt(d.ctx, entry, triggerRetry, false)
+        }
+    }
+}
+
+// Origen de un intento en el historial
+const (
+    triggerExport = "export"
+    triggerRetry  = "retry"
+)
+
+// release libera las entradas reservadas
+func (d *Dispatcher) release(ids ...string) {
+    d.mu.Lock()
+    defer d.mu.Unlock()
+    for _, id := range ids {
+        delete(d.inflight, id)
+    }
+}
+
+// attempt hace un intento de entrega y actualiza el outbox y el historial.
+// Con claimed la entrada ya está reservada por quien llama (Export).
+func (d *Dispatcher) attempt(ctx context.Context, entry storage.OutboxEntry, trigger string, claimed bool) delivery {
+    result := delivery{outboxID: entry.ID}
+
+    // Evitar que el bucle y una exportación entreguen la misma entrada a la vez
+    if !claimed {
+        d.mu.Lock()
+        if d.inflight[entry.ID] {
+            d.mu.Unlock()
+            result.status = StatusRetrying
+            result.err = "delivery already in progress"
+            return result
+        }
+        d.inflight[entry.ID] = true
+        d.mu.Unlock()
+    }
+    defer d.release(entry.ID)
+
+    // La copia de quien llama puede estar desfasada: solo se entrega si la
+    // entrada sigue pendiente
+    current, ok := d.outbox.Get(entry.ID)
+    if !ok {
+        result.status = StatusDead
+        result.err = fmt.Sprintf("outbox entry %s not found", entry.ID)
+        return result
+    }
+    switch current.Status {
+    case storage.OutboxDelivered:
+        result.status = StatusDelivered
+        result.location = current.Location
+        return result
+    case storage.OutboxDead:
+        result.status = StatusDead
+        result.err = current.LastError
+        return result
+    }
+    entry = current
+
+    s, ok := d.byName[entry.Sink]
+    if !ok {
+        updated, _ := d.outbox.MarkFailed(entry.ID, fmt.Sprintf("sink %s is not configured", entry.Sink), time.Now(), true)
+        result.status = StatusDead
+        result.err = updated.LastError
+        d.record(entry, nil, trigger, Receipt{}, result)
+        return result
+    }
+
+    start := time.Now()
+    receipt, err := s.Deliver(ctx, &Batch{
+        ID:         entry.BatchID,
+        Sequence:   entry.Sequence,
+        Total:      entry.Total,
+        From:       entry.From,
+        To:         entry.To,
+        Date:       entry.Date,
+        Part:       entry.Part,
+        Parts:      entry.Parts,
+        GroupBy:    entry.GroupBy,
+        Metrics:    entry.Metrics,
+        ExportedAt: entry.ExportedAt,
+    })
+    result.duration = time.Since(start)
+
+    if err == nil {
+        result.status = StatusDelivered
+        result.location = receipt.Location
+        if _, markErr := d.outbox.MarkDelivered(entry.ID, receipt.Location); markErr != nil {
+            result.err = markErr.Error()
+        }
+        d.record(entry, s, trigger, receipt, result)
+        return result
+    }
+
+    attempts := entry.Attempts + 1
+    dead := attempts >= d.options.MaxAttempts
+    next := time.Now().Add(d.options.Backoff.Delay(attempts - 1))
+    d.outbox.MarkFailed(entry.ID, err.Error(), next, dead)
+
+    result.err = err.Error()
+    if dead {
+        result.status = StatusDead
+    } else {
+        result.status = StatusRetrying
+        result.nextAttempt = &next
+    }
+    d.record(entry, s, trigger, receipt, result)
+    return result
+}
+
+// record guarda el intento en el historial de exportaciones
+func (d *Dispatcher) record(entry storage.OutboxEntry, s Sink, trigger string, receipt Receipt, result delivery) {
+    if d.history == nil {
+        return
+    }
+
+    attempt := storage.ExportAttempt{
+        BatchID:     entry.BatchID,
+        OutboxID:    entry.ID,
+        Sink:        entry.Sink,
+        From:        entry.From,
+        To:          entry.To,
+        Date:        entry.Date,
+        Sequence:    entry.Sequence,
+        Total:       entry.Total,
+        Records:     len(entry.Metrics),
+        PayloadHash: receipt.PayloadHash,
+        KeyID:       receipt.KeyID,
+        HTTPStatus:  receipt.StatusCode,
+        LatencyMs:   result.duration.Milliseconds(),
+        Attempt:     entry.Attempts + 1,
+        Trigger:     trigger,
+        Status:      result.status,
+        Location:    receipt.Location,
+        Error:       result.err,
+    }
+    if s != nil {
+        attempt.SinkType = s.Type()
+        attempt.Format = s.Format()
+    }
+    if _, err := d.history.Record(attempt); err != nil {
+        fmt.Printf("Debug: Error registrando el intento de %s en el historial: %v\n", entry.ID, err)
+    }
+}
@@ -0,0 +1,63 @@
+package sink
+
+import (
+    "context"
+    "fmt"
+    "os"
+    "path/filepath"
+)
+
+// FileSink escribe cada lote en Dir/date=YYYY-MM-DD/metrics.<formato>.
+// Reexportar un día reemplaza el fichero de forma atómica y borra los chunks
+// que dejó una exportación anterior con otro número de chunks.
+type FileSink struct {
+    name   string
+    format string
+    dir    string
+}
+
+func NewFileSink(name, format, dir string) (*FileSink, error) {
+    if dir == "" {
+        return nil, fmt.Errorf("dir is required")
+    }
+    return &FileSink{name: name, format: format, dir: dir}, nil
+}
+
+func (s *FileSink) Name() string   { return s.name }
+func (s *FileSink) Type() string   { return "file" }
+func (s *FileSink) Format() string { return s.format }
+
+func (s *FileSink) Deliver(ctx context.Context, batch *Batch) (Receipt, error) {
+    body, _, err := Encode(s.format, batch)
+    if err != nil {
+        return Receipt{}, err
+    }
+
+    path := filepath.Join(s.dir, filepath.FromSlash(objectName(batch, s.format)))
+    receipt := Receipt{Location: path, PayloadHash: sha256Hex(body)}
+    if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+        return receipt, fmt.Errorf("failed to create partition: %v", err)
+    }
+
+    tmp := path + ".tmp"
+    if err := os.WriteFile(tmp, body, 0644); err != nil {
+        return receipt, fmt.Errorf("failed to write file: %v", err)
+    }
+    if err := os.Rename(tmp, path); err != nil {
+        os.Remove(tmp)
+        return receipt, fmt.Errorf("failed to write file: %v", err)
+    }
+
+    entries, err := os.ReadDir(filepath.Dir(path))
+    if err != nil {
+        return receipt, fmt.Errorf("failed to list partition: %v", err)
+    }
+    for _, entry := range entries {
+        if staleObject(batch, s.format, entry.Name()) {
+            if err := os.Remove(filepath.Join(filepath.Dir(path), entry.Name())); err != nil && !os.IsNotExist(err) {
+                return receipt, fmt.Errorf("failed to remove stale chunk: %v", err)
+            }
+        }
+    }
+    return receipt, nil
+}
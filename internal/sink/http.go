@@ -0,0 +1,74 @@
+package sink
+
+import (
+    "bytes"
+    "context"
+    "fmt"
+    "net/http"
+    "strconv"
+    "time"
+
+    "admira-etl/pkg/signature"
+)
+
+// HTTPSink envía el lote por POST a un webhook firmado con pkg/signature
+// (timestamp, nonce y key id, a prueba de reenvíos)
+type HTTPSink struct {
+    name   string
+    format string
+    url    string
+    signer *signature.Signer
+    client *http.Client
+}
+
+func NewHTTPSink(name, format, url string, key signature.Key, timeout time.Duration) (*HTTPSink, error) {
+    if url == "" {
+        return nil, fmt.Errorf("url is required")
+    }
+    if key.ID == "" || key.Secret == "" {
+        return nil, fmt.Errorf("signing key id and secret are required")
+    }
+    return &HTTPSink{
+        name:   name,
+        format: format,
+        url:    url,
+        signer: signature.NewSigner(key),
+        client: &http.Client{Timeout: timeout},
+    }, nil
+}
+func (s *HTTPSink) Name() string   { return s.name }
+func (s *HTTPSink) Type() string   { return "http" }
+func (s *HTTPSink) Format() string { return s.format }
+
+func (s *HTTPSink) Deliver(ctx context.Context, batch *Batch) (Receipt, error) {
+    body, contentType, err := Encode(s.format, batch)
+    if err != nil {
+        return Receipt{}, err
+    }
+    receipt := Receipt{Location: s.url, PayloadHash: sha256Hex(body), KeyID: s.signer.KeyID()}
+
+    req, err := http.NewRequestWithContext(ctx, "POST", s.url, bytes.NewReader(body))
+    if err != nil {
+        return receipt, fmt.Errorf("failed to create request: %v", err)
+    }
+    req.Header.Set("Content-Type", contentType)
+    req.Header.Set("X-Batch-Id", batch.ID)
+    req.Header.Set("X-Batch-Sequence", strconv.Itoa(batch.Sequence))
+    req.Header.Set("X-Batch-Total", strconv.Itoa(batch.Total))
+    if err := s.signer.SignRequest(req, body); err != nil {
+        return receipt, err
+    }
+    req.Header.Set("User-Agent", "Admira-ETL-Service/1.0")
+
+    resp, err := s.client.Do(req)
+    if err != nil {
+        return receipt, fmt.Errorf("failed to send request: %v", err)
+    }
+    defer resp.Body.Close()
+
+    receipt.StatusCode = resp.StatusCode
+    if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+        return receipt, fmt.Errorf("sink returned status %d", resp.StatusCode)
+    }
+    return receipt, nil
+}
@@ -0,0 +1,241 @@
+package sink
+
+import (
+    "bytes"
+    "context"
+    "crypto/hmac"
+    "crypto/sha256"
+    "encoding/hex"
+    "encoding/xml"
+    "fmt"
+    "io"
+    "net/http"
+    "net/url"
+    "os"
+    "path"
+    "strings"
+    "time"
+)
+
+// S3Options configura un object store compatible con S3 (AWS, MinIO, ...)
+type S3Options struct {
+    // Endpoint es la URL base, p. ej. http://localhost:9000
+    Endpoint  string
+    Bucket    string
+    Region    string
+    Prefix    string
+    AccessKey string
+    SecretKey string
+    // KeyID es un identificador no secreto de las credenciales que se anota en
+    // el historial de exportaciones; vacío si no se configura
+    KeyID   string
+    Timeout time.Duration
+}
+
+// S3Sink sube cada lote con PUT (path-style) firmado con AWS Signature V4 y
+// borra los chunks que dejó en la partición una exportación anterior del día
+// con otro número de chunks
+type S3Sink struct {
+    name    string
+    format  string
+    options S3Options
+    client  *http.Client
+}
+
+func NewS3Sink(name, format string, options S3Options) (*S3Sink, error) {
+    if options.Endpoint == "" || options.Bucket == "" {
+        return nil, fmt.Errorf("endpoint and bucket are required")
+    }
+    if _, err := url.Parse(options.Endpoint); err != nil {
+        return nil, fmt.Errorf("invalid endpoint: %v", err)
+    }
+    if options.Region == "" {
+        options.Region = "us-east-1"
+    }
+    // Las credenciales pueden venir del entorno estándar de AWS
+    options.AccessKey = firstNonEmpty(options.AccessKey, os.Getenv("AWS_ACCESS_KEY_ID"))
+    options.SecretKey = firstNonEmpty(options.SecretKey, os.Getenv("AWS_SECRET_ACCESS_KEY"))
+
+    return &S3Sink{
+        name:    name,
+        format:  format,
+        options: options,
+        client:  &http.Client{Timeout: options.Timeout},
+    }, nil
+}
+
+func (s *S3Sink) Name() string   { return s.name }
+func (s *S3Sink) Type() string   { return "s3" }
+func (s *S3Sink) Format() string { return s.format }
+
+func (s *S3Sink) Deliver(ctx context.Context, batch *Batch) (Receipt, error) {
+    body, contentType, err := Encode(s.format, batch)
+    if err != nil {
+        return Receipt{}, err
+    }
+
+    key := path.Join(s.options.Prefix, objectName(batch, s.format))
+    receipt := Receipt{
+        Location:    "s3://" + s.options.Bucket + "/" + key,
+        PayloadHash: sha256Hex(body),
+        KeyID:       s.options.KeyID,
+    }
+
+    resp, err := s.send(ctx, "PUT", key, "", contentType, body)
+    if resp != nil {
+        receipt.StatusCode = resp.StatusCode
+    }
+    if err != nil {
+        return receipt, err
+    }
+    resp.Body.Close()
+
+    if err := s.removeStale(ctx, batch, path.Dir(key)); err != nil {
+        return receipt, err
+    }
+    return receipt, nil
+}
+
+// listBucketResult es la parte que se usa de la respuesta de ListObjectsV2
+type listBucketResult struct {
+    Contents []struct {
+        Key string `xml:"Key"`
+    } `xml:"Contents"`
+    IsTruncated           bool   `xml:"IsTruncated"`
+    NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// removeStale lista la partición del lote y borra los chunks de exportaciones
+// anteriores con otro número de chunks
+func (s *S3Sink) removeStale(ctx context.Context, batch *Batch, partition string) error {
+    token := ""
+    for {
+        query := url.Values{"list-type": {"2"}, "prefix": {partition + "/"}}
+        if token != "" {
+            query.Set("continuation-token", token)
+        }
+        // Encode ordena los parámetros como exige la firma
+        resp, err := s.send(ctx, "GET", "", strings.ReplaceAll(query.Encode(), "+", "%20"), "", nil)
+        if err != nil {
+            return fmt.Errorf("failed to list partition: %v", err)
+        }
+        var result listBucketResult
+        err = xml.NewDecoder(resp.Body).Decode(&result)
+        resp.Body.Close()
+        if err != nil {
+            return fmt.Errorf("failed to list partition: %v", err)
+        }
+
+        for _, object := range result.Contents {
+            if !staleObject(batch, s.format, path.Base(object.Key)) {
+                continue
+            }
+            resp, err := s.send(ctx, "DELETE", object.Key, "", "", nil)
+            if err != nil {
+                return fmt.Errorf("failed to remove stale chunk: %v", err)
+            }
+            resp.Body.Close()
+        }
+        if !result.IsTruncated || result.NextContinuationToken == "" {
+            return nil
+        }
+        token = result.NextContinuationToken
+    }
+}
+
+// send hace una petición firmada sobre una clave del bucket (vacía para el
+// bucket). Con un estado que no es 2xx devuelve también la respuesta, ya
+// cerrada, para anotar el estado HTTP.
+func (s *S3Sink) send(ctx context.Context, method, key, rawQuery, contentType string, body []byte) (*http.Response, error) {
+    target := strings.TrimRight(s.options.Endpoint, "/") + "/" + s.options.Bucket
+    if key != "" {
+        target += "/" + escapePath(key)
+    }
+    if rawQuery != "" {
+        target += "?" + rawQuery
+    }
+
+    req, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(body))
+    if err != nil {
+        return nil, fmt.Errorf("failed to create request: %v", err)
+    }
+    req.Header.Set("Content-Type", contentType)
+    s.signV4(req, body, time.Now().UTC())
+
+    resp, err := s.client.Do(req)
+    if err != nil {
+        return nil, fmt.Errorf("failed to send request: %v", err)
+    }
+    if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+        defer resp.Body.Close()
+        message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+        return resp, fmt.Errorf("object store returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(message)))
+    }
+    return resp, nil
+}
+
+// signV4 añade las cabeceras de AWS Signature Version 4 para el servicio s3
+func (s *S3Sink) signV4(req *http.Request, body []byte, now time.Time) {
+    amzDate := now.Format("20060102T150405Z")
+    day := now.Format("20060102")
+    payloadHash := sha256Hex(body)
+
+    req.Header.Set("X-Amz-Date", amzDate)
+    req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+    if s.options.AccessKey == "" {
+        return
+    }
+
+    signedHeaders := "content-type;host;x-amz-content-sha256;x-amz-date"
+    canonicalHeaders := "content-type:" + req.Header.Get("Content-Type") + "\n" +
+        "host:" + req.URL.Host + "\n" +
+        "x-amz-content-sha256:" + payloadHash + "\n" +
+        "x-amz-date:" + amzDate + "\n"
+    canonicalRequest := strings.Join([]string{
+        req.Method,
+        req.URL.EscapedPath(),
+        req.URL.RawQuery,
+        canonicalHeaders,
+        signedHeaders,
+        payloadHash,
+    }, "\n")
+
+    scope := day + "/" + s.options.Region + "/s3/aws4_request"
+    stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+    key := hmacSHA256([]byte("AWS4"+s.options.SecretKey), day)
+    key = hmacSHA256(key, s.options.Region)
+    key = hmacSHA256(key, "s3")
+    key = hmacSHA256(key, "aws4_request")
+    signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+    req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+        s.options.AccessKey, scope, signedHeaders, signature))
+}
+
+// escapePath codifica cada segmento de la clave como exige SigV4: todo salvo
+// los caracteres no reservados (A-Z a-z 0-9 - _ . ~)
+func escapePath(key string) string {
+    var b strings.Builder
+    for _, c := range []byte(key) {
+        switch {
+        case c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z', c >= '0' && c <= '9',
+            c == '-', c == '_', c == '.', c == '~', c == '/':
+            b.WriteByte(c)
+        default:
+            fmt.Fprintf(&b, "%%%02X", c)
+        }
+    }
+    return b.String()
+}
+
+func sha256Hex(data []byte) string {
+    sum := sha256.Sum256(data)
+    return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+    h := hmac.New(sha256.New, key)
+    h.Write([]byte(data))
+    return h.Sum(nil)
+}
@@ -0,0 +1,235 @@
+package sink
+
+import (
+    "bytes"
+    "context"
+    "encoding/csv"
+    "encoding/json"
+    "fmt"
+    "time"
+
+    "admira-etl/internal/models"
+    "admira-etl/pkg/config"
+    "admira-etl/pkg/signature"
+)
+
+// Formatos de serialización de un lote
+const (
+    FormatJSON   = "json"
+    FormatCSV    = "csv"
+    FormatNDJSON = "ndjson"
+)
+
+// Estados de entrega de un sink
+const (
+    StatusDelivered = "delivered"
+    // StatusRetrying: el intento falló y el outbox lo reintentará
+    StatusRetrying = "retrying"
+    // StatusDead: se agotaron los intentos y el lote está en la dead-letter list
+    StatusDead = "dead"
+    // StatusFailed: la exportación falló antes de llegar al outbox (sin
+    // métricas en el rango, error al escribir el outbox)
+    StatusFailed = "failed"
+    // StatusSkipped: no hay sinks configurados y solo se prepararon los datos
+    StatusSkipped = "skipped"
+)
+
+// Batch es un chunk de una exportación. Todos los chunks de una exportación
+// comparten ID y se numeran de 1 a Total para que el receptor pueda
+// reensamblar el rango y descartar duplicados.
+type Batch struct {
+    ID       string
+    Sequence int
+    Total    int
+    // From y To son el rango de la exportación a la que pertenece el chunk
+    From string
+    To   string
+    Date string
+    // Part y Parts numeran los chunks dentro de Date
+    Part  int
+    Parts int
+    // GroupBy son las dimensiones con las que se consolidaron las métricas
+    GroupBy    []string
+    Metrics    []models.Metrics
+    ExportedAt time.Time
+}
+
+// Sink es un destino de exportación (webhook, sistema de ficheros, object store...)
+type Sink interface {
+    Name() string
+    Type() string
+    Format() string
+    // Deliver entrega el lote. El Receipt se rellena también si falla, en la
+    // medida de lo posible, para el historial de exportaciones.
+    Deliver(ctx context.Context, batch *Batch) (Receipt, error)
+}
+
+// Receipt describe cómo se envió un lote y dónde quedó
+type Receipt struct {
+    // Location es la URL, ruta o clave del destino
+    Location string
+    // PayloadHash es el sha256 en hexadecimal del cuerpo enviado
+    PayloadHash string
+    // KeyID identifica la clave con la que se firmó la petición
+    KeyID      string
+    StatusCode int
+}
+
+// Result resume la entrega de todos los chunks de una exportación a un sink.
+// Status es dead si algún chunk acabó en dead-letter, retrying si alguno se
+// reintentará y delivered si se entregaron todos.
+type Result struct {
+    Name      string `json:"name"`
+    Type      string `json:"type"`
+    Format    string `json:"format"`
+    Status    string `json:"status"`
+    Chunks    int    `json:"chunks"`
+    Delivered int    `json:"delivered"`
+    Retrying  int    `json:"retrying"`
+    Dead      int    `json:"dead"`
+    // Location es el destino del último chunk entregado
+    Location   string `json:"location,omitempty"`
+    Error      string `json:"error,omitempty"`
+    DurationMs int64  `json:"duration_ms"`
+    // PendingOutboxIDs son las entradas del outbox que no se entregaron
+    PendingOutboxIDs []string   `json:"pending_outbox_ids,omitempty"`
+    NextAttemptAt    *time.Time `json:"next_attempt_at,omitempty"`
+}
+
+// Open crea los sinks configurados; sin sinks la exportación solo prepara los datos
+func Open(cfg *config.Config) ([]Sink, error) {
+    var sinks []Sink
+    seen := make(map[string]bool)
+    for _, sc := range cfg.Sinks {
+        if sc.Name == "" {
+            return nil, fmt.Errorf("sink name is required")
+        }
+        if seen[sc.Name] {
+            return nil, fmt.Errorf("sink %s configured twice", sc.Name)
+        }
+        seen[sc.Name] = true
+
+        s, err := New(cfg, sc)
+        if err != nil {
+            return nil, fmt.Errorf("sink %s: %v", sc.Name, err)
+        }
+        sinks = append(sinks, s)
+    }
+    return sinks, nil
+}
+
+// New crea un sink a partir de su configuración
+func New(cfg *config.Config, sc config.SinkConfig) (Sink, error) {
+    format := sc.Format
+    if format == "" {
+        format = FormatJSON
+    }
+    switch format {
+    case FormatJSON, FormatCSV, FormatNDJSON:
+    default:
+        return nil, fmt.Errorf("unknown format %q", format)
+    }
+
+    switch sc.Type {
+    case "http":
+        // Un sink con secreto propio necesita también su key id
+        key := signature.Key{ID: cfg.SinkKeyID, Secret: cfg.SinkSecret}
+        if sc.Secret != "" {
+            key = signature.Key{ID: firstNonEmpty(sc.KeyID, sc.Name), Secret: sc.Secret}
+        }
+        return NewHTTPSink(sc.Name, format, sc.URL, key, cfg.Timeout)
+    case "file":
+        return NewFileSink(sc.Name, format, sc.Dir)
+    case "s3":
+        return NewS3Sink(sc.Name, format, S3Options{
+            Endpoint:  sc.Endpoint,
+            Bucket:    sc.Bucket,
+            Region:    sc.Region,
+            Prefix:    sc.Prefix,
+            AccessKey: sc.AccessKey,
+            SecretKey: sc.SecretKey,
+            KeyID:     sc.KeyID,
+            Timeout:   cfg.Timeout,
+        })
+    default:
+        return nil, fmt.Errorf("unknown sink type %q", sc.Type)
+    }
+}
+
+// Encode serializa el lote en el formato indicado y devuelve su content type
+func Encode(format string, batch *Batch) ([]byte, string, error) {
+    var buf bytes.Buffer
+    switch format {
+    case FormatJSON:
+        payload := map[string]interface{}{
+            "batch_id":     batch.ID,
+            "sequence":     batch.Sequence,
+            "total_chunks": batch.Total,
+            "date":         batch.Date,
+            "part":         batch.Part,
+            "parts":        batch.Parts,
+            "group_by":     batch.GroupBy,
+            "record_count": len(batch.Metrics),
+            "metrics":      batch.Metrics,
+            "exported_at":  batch.ExportedAt.UTC().Format(time.RFC3339),
+        }
+        if err := json.NewEncoder(&buf).Encode(payload); err != nil {
+            return nil, "", fmt.Errorf("failed to marshal payload: %v", err)
+        }
+        // Sin el salto de línea final, igual que json.Marshal
+        return bytes.TrimRight(buf.Bytes(), "\n"), "application/json", nil
+    case FormatCSV:
+        writer := csv.NewWriter(&buf)
+        writer.Write(models.MetricsCSVHeader())
+        for _, m := range batch.Metrics {
+            writer.Write(m.CSVRecord())
+        }
+        writer.Flush()
+        if err := writer.Error(); err != nil {
+            return nil, "", fmt.Errorf("failed to write CSV: %v", err)
+        }
+        return buf.Bytes(), "text/csv", nil
+    case FormatNDJSON:
+        encoder := json.NewEncoder(&buf)
+        for _, m := range batch.Metrics {
+            if err := encoder.Encode(m); err != nil {
+                return nil, "", fmt.Errorf("failed to marshal metric: %v", err)
+            }
+        }
+        return buf.Bytes(), "application/x-ndjson", nil
+    }
+    return nil, "", fmt.Errorf("unknown format %q", format)
+}
+
+// objectName es el nombre del fichero u objeto de un lote, particionado por
+// fecha; si el día se dividió en varios chunks cada uno lleva su número
+func objectName(batch *Batch, format string) string {
+    if batch.Parts > 1 {
+        return fmt.Sprintf("date=%s/metrics-%04d.%s", batch.Date, batch.Part, format)
+    }
+    return fmt.Sprintf("date=%s/metrics.%s", batch.Date, format)
+}
+
+// staleObject indica si name, un fichero u objeto de la partición del lote,
+// quedó de una exportación anterior del día con otro número de chunks: el
+// nombre sin número cuando ahora hay varios, o un número que ya no existe
+func staleObject(batch *Batch, format, name string) bool {
+    if name == "metrics."+format {
+        return batch.Parts > 1
+    }
+    var part int
+    var ext string
+    if n, _ := fmt.Sscanf(name, "metrics-%04d.%s", &part, &ext); n != 2 || ext != format || name != fmt.Sprintf("metrics-%04d.%s", part, format) {
+        return false
+    }
+    return batch.Parts <= 1 || part > batch.Parts
+}
+
+func firstNonEmpty(values ...string) string {
+    for _, v := range values {
+        if v != "" {
+            return v
+        }
+    }
+    return ""
+}
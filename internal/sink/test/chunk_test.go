@@ -0,0 +1,94 @@
+package test
+
+import (
+    "testing"
+    "time"
+    "admira-etl/internal/models"
+    "admira-etl/internal/sink"
+)
+
+func chunkDays() []sink.Day {
+    var first []models.Metrics
+    for i := 0; i < 5; i++ {
+        first = append(first, models.Metrics{Date: "2025-08-01", Channel: "google_ads", Clicks: i})
+    }
+    return []sink.Day{
+        {Date: "2025-08-01", Metrics: first},
+        {Date: "2025-08-02"},
+        {Date: "2025-08-03", Metrics: []models.Metrics{{Date: "2025-08-03", Channel: "google_ads"}}},
+    }
+}
+
+func TestChunk_SplitsByDayAndRecords(t *testing.T) {
+    batches := sink.Chunk(sink.Batch{ID: "batch_1", ExportedAt: time.Now()}, chunkDays(), sink.ChunkOptions{MaxRecords: 2})
+
+    // 5 registros del primer día en 3 chunks, el día vacío se omite y 1 chunk del tercero
+    if len(batches) != 4 {
+        t.Fatalf("Expected 4 chunks, got %d", len(batches))
+    }
+    for i, batch := range batches {
+        if batch.ID != "batch_1" || batch.Sequence != i+1 || batch.Total != 4 {
+            t.Errorf("Unexpected numbering for chunk %d: %+v", i, batch)
+        }
+    }
+    if batches[2].Date != "2025-08-01" || batches[2].Part != 3 || batches[2].Parts != 3 || len(batches[2].Metrics) != 1 {
+        t.Errorf("Unexpected last chunk of the first day: %+v", batches[2])
+    }
+    if batches[3].Date != "2025-08-03" || batches[3].Part != 1 || batches[3].Parts != 1 {
+        t.Errorf("Unexpected chunk for the third day: %+v", batches[3])
+    }
+}
+
+func TestChunk_SplitsByBytes(t *testing.T) {
+    // Cada registro ocupa más de 100 bytes en JSON: uno por chunk
+    template := sink.Batch{ID: "batch_1", ExportedAt: time.Now()}
+    batches := sink.Chunk(template, chunkDays()[:1], sink.ChunkOptions{MaxBytes: 100})
+    if len(batches) != 5 {
+        t.Fatalf("Expected one chunk per record, got %d", len(batches))
+    }
+
+    // Sin límites cada día es un único chunk
+    batches = sink.Chunk(template, chunkDays(), sink.ChunkOptions{})
+    if len(batches) != 2 || len(batches[0].Metrics) != 5 {
+        t.Errorf("Expected one chunk per day, got %d", len(batches))
+    }
+}
+
+func TestChunk_MaxBytesFitsEncodedPayload(t *testing.T) {
+    var metrics []models.Metrics
+    for i := 0; i < 40; i++ {
+        metrics = append(metrics, models.Metrics{Date: "2025-08-01", Channel: "google_ads", CampaignID: "C-1", Clicks: i, UTMCampaign: "back_to_school"})
+    }
+    days := []sink.Day{{Date: "2025-08-01", Metrics: metrics}}
+    template := sink.Batch{ID: "batch_1", From: "2025-08-01", To: "2025-08-01", GroupBy: []string{"date", "channel"}, ExportedAt: time.Now()}
+
+    for _, format := range []string{sink.FormatJSON, sink.FormatCSV, sink.FormatNDJSON} {
+        const maxBytes = 2048
+        batches := sink.Chunk(template, days, sink.ChunkOptions{MaxBytes: maxBytes, Formats: []string{format}})
+        if len(batches) < 2 {
+            t.Fatalf("%s: expected several chunks, got %d", format, len(batches))
+        }
+        records := 0
+        for _, batch := range batches {
+            body, _, err := sink.Encode(format, batch)
+            if err != nil {
+                t.Fatalf("%s: Encode failed: %v", format, err)
+            }
+            if len(body) > maxBytes {
+                t.Errorf("%s: chunk %d/%d is %d bytes, over the %d limit", format, batch.Part, batch.Parts, len(body), maxBytes)
+            }
+            records += len(batch.Metrics)
+        }
+        if records != len(metrics) {
+            t.Errorf("%s: expected %d records across chunks, got %d", format, len(metrics), records)
+        }
+    }
+
+    // Con varios formatos cada chunk debe caber en todos
+    batches := sink.Chunk(template, days, sink.ChunkOptions{MaxBytes: 2048, Formats: []string{sink.FormatCSV, sink.FormatJSON}})
+    for _, batch := range batches {
+        if body, _, _ := sink.Encode(sink.FormatJSON, batch); len(body) > 2048 {
+            t.Errorf("Chunk %d is %d bytes in JSON, over the limit", batch.Part, len(body))
+        }
+    }
+}
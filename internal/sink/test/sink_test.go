@@ -0,0 +1,477 @@
+package test
+
+import (
+    "context"
+    "fmt"
+    "net/http"
+    "net/http/httptest"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+    "sync"
+    "sync/atomic"
+    "testing"
+    "time"
+    "admira-etl/internal/models"
+    "admira-etl/internal/retry"
+    "admira-etl/internal/sink"
+    "admira-etl/internal/storage"
+    "admira-etl/pkg/config"
+    "admira-etl/pkg/signature"
+)
+
+func testBatch() *sink.Batch {
+    return &sink.Batch{
+        ID:         "batch_test",
+        Sequence:   1,
+        Total:      1,
+        Date:       "2025-08-01",
+        Part:       1,
+        Parts:      1,
+        ExportedAt: time.Date(2025, 8, 2, 0, 0, 0, 0, time.UTC),
+        Metrics: []models.Metrics{
+            {Date: "2025-08-01", Channel: "google_ads", CampaignID: "C-1", Clicks: 10, Cost: 5},
+            {Date: "2025-08-01", Channel: "facebook_ads", CampaignID: "C-2", Clicks: 20, Cost: 8},
+        },
+    }
+}
+
+func TestHTTPSink_SignsPayload(t *testing.T) {
+    verifier := signature.NewVerifier([]signature.Key{{ID: "k1", Secret: "secret"}})
+
+    var verifyErr error
+    var batchID, sequence string
+    server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        _, verifyErr = verifier.VerifyRequest(r)
+        batchID = r.Header.Get("X-Batch-Id")
+        sequence = r.Header.Get("X-Batch-Sequence") + "/" + r.Header.Get("X-Batch-Total")
+    }))
+    defer server.Close()
+
+    s, err := sink.NewHTTPSink("webhook", sink.FormatJSON, server.URL, signature.Key{ID: "k1", Secret: "secret"}, time.Second)
+    if err != nil {
+        t.Fatalf("NewHTTPSink failed: %v", err)
+    }
+    if _, err := s.Deliver(context.Background(), testBatch()); err != nil {
+        t.Fatalf("Deliver failed: %v", err)
+    }
+    if verifyErr != nil {
+        t.Errorf("Signature did not verify: %v", verifyErr)
+    }
+    if batchID != "batch_test" || sequence != "1/1" {
+        t.Errorf("Unexpected batch headers %s %s", batchID, sequence)
+    }
+}
+
+func TestFileSink_PartitionsByDate(t *testing.T) {
+    dir := t.TempDir()
+    s, _ := sink.NewFileSink("local", sink.FormatCSV, dir)
+
+    receipt, err := s.Deliver(context.Background(), testBatch())
+    if err != nil {
+        t.Fatalf("Deliver failed: %v", err)
+    }
+    location := receipt.Location
+    if location != filepath.Join(dir, "date=2025-08-01", "metrics.csv") {
+        t.Errorf("Unexpected location %s", location)
+    }
+
+    data, _ := os.ReadFile(location)
+    lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+    if len(lines) != 3 || !strings.HasPrefix(lines[0], "date,channel,campaign_id") {
+        t.Errorf("Unexpected CSV content: %q", data)
+    }
+}
+
+// parts devuelve los chunks de un día en orden, a partir de los nombres
+func parts(names []string) string {
+    sort.Strings(names)
+    return strings.Join(names, ",")
+}
+
+// reexport entrega un día en el número de chunks indicado
+func reexport(t *testing.T, s sink.Sink, parts int) {
+    for part := 1; part <= parts; part++ {
+        batch := testBatch()
+        batch.Sequence, batch.Total, batch.Part, batch.Parts = part, parts, part, parts
+        if _, err := s.Deliver(context.Background(), batch); err != nil {
+            t.Fatalf("Deliver failed: %v", err)
+        }
+    }
+}
+
+func TestFileSink_ReexportRemovesStaleParts(t *testing.T) {
+    dir := t.TempDir()
+    s, _ := sink.NewFileSink("local", sink.FormatJSON, dir)
+    partition := func() string {
+        entries, _ := os.ReadDir(filepath.Join(dir, "date=2025-08-01"))
+        var names []string
+        for _, entry := range entries {
+            names = append(names, entry.Name())
+        }
+        return parts(names)
+    }
+
+    reexport(t, s, 1)
+    reexport(t, s, 3)
+    if got := partition(); got != "metrics-0001.json,metrics-0002.json,metrics-0003.json" {
+        t.Errorf("Expected only the three new chunks, got %s", got)
+    }
+    reexport(t, s, 2)
+    if got := partition(); got != "metrics-0001.json,metrics-0002.json" {
+        t.Errorf("Expected only the two new chunks, got %s", got)
+    }
+    reexport(t, s, 1)
+    if got := partition(); got != "metrics.json" {
+        t.Errorf("Expected only the single file, got %s", got)
+    }
+}
+
+// objectStore es un bucket S3 mínimo: PUT, DELETE y ListObjectsV2 por prefijo
+type objectStore struct {
+    mu      sync.Mutex
+    objects map[string]bool
+    path    string
+    auth    string
+}
+
+func (o *objectStore) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+    o.mu.Lock()
+    defer o.mu.Unlock()
+
+    key := strings.TrimPrefix(r.URL.Path, "/metrics/")
+    switch {
+    case r.Method == http.MethodPut:
+        o.objects[key] = true
+        o.path = r.URL.EscapedPath()
+        o.auth = r.Header.Get("Authorization")
+    case r.Method == http.MethodDelete:
+        delete(o.objects, key)
+        w.WriteHeader(http.StatusNoContent)
+    case r.Method == http.MethodGet && r.URL.Query().Get("list-type") == "2":
+        if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 ") {
+            w.WriteHeader(http.StatusForbidden)
+            return
+        }
+        fmt.Fprint(w, "<ListBucketResult>")
+        for key := range o.objects {
+            if strings.HasPrefix(key, r.URL.Query().Get("prefix")) {
+                fmt.Fprintf(w, "<Contents><Key>%s</Key></Contents>", key)
+            }
+        }
+        fmt.Fprint(w, "<IsTruncated>false</IsTruncated></ListBucketResult>")
+    default:
+        w.WriteHeader(http.StatusMethodNotAllowed)
+    }
+}
+
+func (o *objectStore) keys() string {
+    o.mu.Lock()
+    defer o.mu.Unlock()
+    var names []string
+    for key := range o.objects {
+        names = append(names, key)
+    }
+    return parts(names)
+}
+
+func TestS3Sink_ReexportRemovesStaleParts(t *testing.T) {
+    store := &objectStore{objects: map[string]bool{"etl/date=2025-08-02/metrics.ndjson": true}}
+    server := httptest.NewServer(store)
+    defer server.Close()
+
+    s, _ := sink.NewS3Sink("lake", sink.FormatNDJSON, sink.S3Options{
+        Endpoint: server.URL, Bucket: "metrics", Prefix: "etl", AccessKey: "AKID", SecretKey: "SECRET",
+    })
+    reexport(t, s, 1)
+    reexport(t, s, 3)
+    reexport(t, s, 2)
+    // Los demás días no se tocan
+    if got := store.keys(); got != "etl/date=2025-08-01/metrics-0001.ndjson,etl/date=2025-08-01/metrics-0002.ndjson,etl/date=2025-08-02/metrics.ndjson" {
+        t.Errorf("Expected only the two new chunks of the day, got %s", got)
+    }
+}
+
+func TestS3Sink_PutsSignedObject(t *testing.T) {
+    store := &objectStore{objects: make(map[string]bool)}
+    server := httptest.NewServer(store)
+    defer server.Close()
+
+    s, err := sink.NewS3Sink("lake", sink.FormatNDJSON, sink.S3Options{
+        Endpoint: server.URL, Bucket: "metrics", Prefix: "etl", AccessKey: "AKID", SecretKey: "SECRET", KeyID: "lake-2025",
+    })
+    if err != nil {
+        t.Fatalf("NewS3Sink failed: %v", err)
+    }
+
+    receipt, err := s.Deliver(context.Background(), testBatch())
+    if err != nil {
+        t.Fatalf("Deliver failed: %v", err)
+    }
+    if receipt.StatusCode != http.StatusOK || receipt.KeyID != "lake-2025" || len(receipt.PayloadHash) != 64 {
+        t.Errorf("Unexpected receipt %+v", receipt)
+    }
+    if location := receipt.Location; location != "s3://metrics/etl/date=2025-08-01/metrics.ndjson" {
+        t.Errorf("Unexpected location %s", location)
+    }
+    if store.path != "/metrics/etl/date%3D2025-08-01/metrics.ndjson" {
+        t.Errorf("Unexpected object path %s", store.path)
+    }
+    if !strings.HasPrefix(store.auth, "AWS4-HMAC-SHA256 Credential=AKID/") || !strings.Contains(store.auth, "/us-east-1/s3/aws4_request") {
+        t.Errorf("Unexpected Authorization header %s", store.auth)
+    }
+    // Sin key id configurado el historial no recibe la access key
+    s, _ = sink.NewS3Sink("lake", sink.FormatNDJSON, sink.S3Options{
+        Endpoint: server.URL, Bucket: "metrics", AccessKey: "AKID", SecretKey: "SECRET",
+    })
+    if receipt, _ := s.Deliver(context.Background(), testBatch()); receipt.KeyID != "" {
+        t.Errorf("Expected an empty key id, got %s", receipt.KeyID)
+    }
+}
+
+func TestDispatcher_RetriesThenDeadLetters(t *testing.T) {
+    var calls int32
+    failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        atomic.AddInt32(&calls, 1)
+        w.WriteHeader(http.StatusInternalServerError)
+    }))
+    defer failing.Close()
+
+    cfg := &config.Config{
+        Timeout:    time.Second,
+        SinkKeyID:  "default",
+        SinkSecret: "secret",
+        Sinks: []config.SinkConfig{
+            {Name: "local", Type: "file", Format: "json", Dir: t.TempDir()},
+            {Name: "webhook", Type: "http", URL: failing.URL},
+        },
+    }
+    sinks, err := sink.Open(cfg)
+    if err != nil {
+        t.Fatalf("Open failed: %v", err)
+    }
+
+    outbox := storage.NewOutbox()
+    history := storage.NewExportHistory(0)
+    dispatcher := sink.NewDispatcher(outbox, history, sinks, sink.DispatcherOptions{
+        MaxAttempts:  2,
+        Backoff:      retry.Backoff{Base: time.Millisecond, Max: time.Millisecond},
+        PollInterval: 5 * time.Millisecond,
+    })
+
+    results, err := dispatcher.Export(context.Background(), []*sink.Batch{testBatch()})
+    if err != nil {
+        t.Fatalf("Export failed: %v", err)
+    }
+    if results[0].Name != "local" || results[0].Status != sink.StatusDelivered {
+        t.Errorf("Expected local sink to succeed, got %+v", results[0])
+    }
+    if results[1].Name != "webhook" || results[1].Status != sink.StatusRetrying || len(results[1].PendingOutboxIDs) != 1 {
+        t.Errorf("Expected webhook sink to be retried, got %+v", results[1])
+    }
+
+    dispatcher.Start()
+    defer dispatcher.Stop()
+
+    deadline := time.Now().Add(2 * time.Second)
+    for len(outbox.List(storage.OutboxFilter{Status: storage.OutboxDead})) == 0 {
+        if time.Now().After(deadline) {
+            t.Fatalf("Entry never reached the dead-letter list")
+        }
+        time.Sleep(5 * time.Millisecond)
+    }
+
+    dead := outbox.List(storage.OutboxFilter{Status: storage.OutboxDead})
+    if len(dead) != 1 || dead[0].Sink != "webhook" || dead[0].Attempts != 2 || dead[0].LastError == "" {
+        t.Errorf("Unexpected dead letters: %+v", dead)
+    }
+
+    // Cada intento queda en el historial con el estado HTTP y el hash del cuerpo
+    attempts := history.List(storage.ExportHistoryFilter{Sink: "webhook"})
+    if len(attempts) != 2 || attempts[0].Status != sink.StatusDead || attempts[0].Trigger != "retry" ||
+        attempts[1].Trigger != "export" || attempts[1].HTTPStatus != http.StatusInternalServerError ||
+        attempts[1].KeyID != "default" || attempts[1].PayloadHash == "" || attempts[1].BatchID != "batch_test" {
+        t.Errorf("Unexpected history: %+v", attempts)
+    }
+
+    delivered := outbox.List(storage.OutboxFilter{Status: storage.OutboxDelivered})
+    if len(delivered) != 1 {
+        t.Fatalf("Expected one delivered entry, got %+v", delivered)
+    }
+    if _, err := dispatcher.Replay(delivered[0].ID); err == nil {
+        t.Errorf("Expected replay of a delivered entry to fail")
+    }
+    if _, err := dispatcher.Replay(dead[0].ID); err != nil {
+        t.Fatalf("Replay failed: %v", err)
+    }
+    for atomic.LoadInt32(&calls) < 3 {
+        if time.Now().After(deadline) {
+            t.Fatalf("Replayed entry was never retried")
+        }
+        time.Sleep(5 * time.Millisecond)
+    }
+}
+
+func TestDispatcher_ExportAndLoopDeliverEachChunkOnce(t *testing.T) {
+    var mu sync.Mutex
+    received := make(map[string]int)
+    slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        time.Sleep(20 * time.Millisecond)
+        mu.Lock()
+        received[r.Header.Get("X-Batch-Sequence")]++
+        mu.Unlock()
+    }))
+    defer slow.Close()
+
+    sinks, err := sink.Open(&config.Config{
+        Timeout:    time.Second,
+        SinkKeyID:  "default",
+        SinkSecret: "secret",
+        Sinks:      []config.SinkConfig{{Name: "webhook", Type: "http", URL: slow.URL}},
+    })
+    if err != nil {
+        t.Fatalf("Open failed: %v", err)
+    }
+    outbox := storage.NewOutbox()
+    dispatcher := sink.NewDispatcher(outbox, nil, sinks, sink.DispatcherOptions{
+        MaxAttempts:  2,
+        Backoff:      retry.Backoff{Base: time.Millisecond, Max: time.Millisecond},
+        PollInterval: time.Millisecond,
+    })
+    // El bucle revisa el outbox mientras la exportación entrega sus chunks
+    dispatcher.Start()
+    defer dispatcher.Stop()
+
+    var batches []*sink.Batch
+    for i := 1; i <= 3; i++ {
+        batch := testBatch()
+        batch.Sequence, batch.Total, batch.Part, batch.Parts = i, 3, i, 3
+        batches = append(batches, batch)
+    }
+    results, err := dispatcher.Export(context.Background(), batches)
+    if err != nil {
+        t.Fatalf("Export failed: %v", err)
+    }
+    if results[0].Status != sink.StatusDelivered || results[0].Delivered != 3 {
+        t.Errorf("Expected the three chunks to be delivered by the export, got %+v", results[0])
+    }
+
+    time.Sleep(20 * time.Millisecond)
+    mu.Lock()
+    defer mu.Unlock()
+    for _, sequence := range []string{"1", "2", "3"} {
+        if received[sequence] != 1 {
+            t.Errorf("Expected chunk %s to be delivered once, got %v", sequence, received)
+        }
+    }
+}
+
+func TestDispatcher_RedeliversPendingEntriesAfterRestart(t *testing.T) {
+    dir := t.TempDir()
+    path := filepath.Join(dir, "outbox.jsonl")
+
+    // Un lote escrito en el outbox que no llegó a entregarse antes de parar
+    outbox, err := storage.OpenOutboxStore(path)
+    if err != nil {
+        t.Fatalf("OpenOutboxStore failed: %v", err)
+    }
+    batch := testBatch()
+    entry, err := outbox.Enqueue(storage.OutboxEntry{
+        Sink:       "local",
+        BatchID:    batch.ID,
+        Sequence:   batch.Sequence,
+        Total:      batch.Total,
+        Date:       batch.Date,
+        Part:       batch.Part,
+        Parts:      batch.Parts,
+        Metrics:    batch.Metrics,
+        ExportedAt: batch.ExportedAt,
+    })
+    if err != nil {
+        t.Fatalf("Enqueue failed: %v", err)
+    }
+    outbox.Close()
+
+    outbox, err = storage.OpenOutboxStore(path)
+    if err != nil {
+        t.Fatalf("Reopen failed: %v", err)
+    }
+    defer outbox.Close()
+
+    sinks, err := sink.Open(&config.Config{
+        Timeout: time.Second,
+        Sinks:   []config.SinkConfig{{Name: "local", Type: "file", Format: "json", Dir: filepath.Join(dir, "exports")}},
+    })
+    if err != nil {
+        t.Fatalf("Open failed: %v", err)
+    }
+    dispatcher := sink.NewDispatcher(outbox, nil, sinks, sink.DispatcherOptions{
+        MaxAttempts:  2,
+        Backoff:      retry.Backoff{Base: time.Millisecond, Max: time.Millisecond},
+        PollInterval: 5 * time.Millisecond,
+    })
+    dispatcher.Start()
+    defer dispatcher.Stop()
+
+    deadline := time.Now().Add(2 * time.Second)
+    for {
+        delivered, _ := outbox.Get(entry.ID)
+        if delivered.Status == storage.OutboxDelivered {
+            if delivered.Attempts != 1 || delivered.Location == "" || delivered.Metrics != nil {
+                t.Errorf("Unexpected delivered entry: %+v", delivered)
+            }
+            break
+        }
+        if time.Now().After(deadline) {
+            t.Fatalf("Pending entry was never redelivered after the restart: %+v", delivered)
+        }
+        time.Sleep(5 * time.Millisecond)
+    }
+
+    files, _ := filepath.Glob(filepath.Join(dir, "exports", "*", "*"))
+    if len(files) != 1 {
+        t.Errorf("Expected one exported file, got %v", files)
+    }
+}
+
+func TestDispatcher_RecordsFailuresBeforeTheOutbox(t *testing.T) {
+    dir := t.TempDir()
+    sinks, err := sink.Open(&config.Config{
+        Timeout: time.Second,
+        Sinks: []config.SinkConfig{
+            {Name: "local", Type: "file", Format: "json", Dir: dir},
+            {Name: "archive", Type: "file", Format: "csv", Dir: dir},
+        },
+    })
+    if err != nil {
+        t.Fatalf("Open failed: %v", err)
+    }
+
+    // Un outbox cerrado no admite escrituras
+    outbox, err := storage.OpenOutboxStore(filepath.Join(dir, "outbox.jsonl"))
+    if err != nil {
+        t.Fatalf("OpenOutboxStore failed: %v", err)
+    }
+    outbox.Close()
+
+    history := storage.NewExportHistory(0)
+    dispatcher := sink.NewDispatcher(outbox, history, sinks, sink.DispatcherOptions{})
+    if _, err := dispatcher.Export(context.Background(), []*sink.Batch{testBatch()}); err == nil {
+        t.Fatalf("Expected Export to fail with a closed outbox")
+    }
+    failed := history.List(storage.ExportHistoryFilter{Status: sink.StatusFailed})
+    if len(failed) != 2 || failed[0].Sink != "archive" || failed[1].Sink != "local" ||
+        failed[0].Date != "2025-08-01" || failed[0].Error == "" || failed[0].Records != 2 {
+        t.Errorf("Expected a failed attempt per sink, got %+v", failed)
+    }
+
+    // Sin sinks queda una única entrada sin sink
+    history = storage.NewExportHistory(0)
+    dispatcher = sink.NewDispatcher(storage.NewOutbox(), history, nil, sink.DispatcherOptions{})
+    dispatcher.RecordFailure(sink.Batch{ID: "batch_test", From: "2025-08-01", To: "2025-08-01", Date: "2025-08-01"}, sink.StatusSkipped, "no sinks configured")
+    skipped := history.List(storage.ExportHistoryFilter{From: "2025-08-01", To: "2025-08-01"})
+    if len(skipped) != 1 || skipped[0].Sink != "" || skipped[0].Status != sink.StatusSkipped || skipped[0].BatchID != "batch_test" {
+        t.Errorf("Unexpected history without sinks: %+v", skipped)
+    }
+}
@@ -0,0 +1,128 @@
+package storage
+
+import (
+    "encoding/json"
+    "sync"
+    "time"
+
+    "admira-etl/internal/models"
+)
+
+// FileStorage persiste las métricas en un journal en disco y sirve las
+// consultas desde una copia en memoria reconstruida al arrancar.
+type FileStorage struct {
+    *MemoryStorage
+    writeMu sync.Mutex
+    journal *Journal
+}
+
+func NewFileStorage(path string) (*FileStorage, error) {
+    journal, err := OpenJournal(path)
+    if err != nil {
+        return nil, err
+    }
+
+    s := &FileStorage{
+        MemoryStorage: NewMemoryStorage(),
+        journal:       journal,
+    }
+
+    // Cada línea es una fila o una marca de ReplaceMetrics, que se aplica en orden
+    var pending []models.Metrics
+    lines := 0
+    err = journal.Replay(func(line []byte) error {
+        var entry metricsEntry
+        if err := json.Unmarshal(line, &entry); err != nil {
+            return err
+        }
+        lines++
+        if entry.ReplaceFrom == "" {
+            pending = append(pending, entry.Metrics)
+            return nil
+        }
+        s.MemoryStorage.StoreMetrics(pending)
+        pending = nil
+        from, err := time.Parse("2006-01-02", entry.ReplaceFrom)
+        if err != nil {
+            return err
+        }
+        s.MemoryStorage.ReplaceMetrics(from, nil)
+        return nil
+    })
+    if err != nil {
+        journal.Close()
+        return nil, err
+    }
+
+    s.MemoryStorage.StoreMetrics(pending)
+
+    // El journal acumula versiones antiguas de cada fila y marcas de borrado:
+    // compactar al arrancar
+    if lines > s.MemoryStorage.Len() {
+        if err := s.compact(); err != nil {
+            journal.Close()
+            return nil, err
+        }
+    }
+    return s, nil
+}
+
+// replaceMarker es la línea que escribe ReplaceMetrics: borra las filas con
+// fecha desde ReplaceFrom
+type replaceMarker struct {
+    ReplaceFrom string `json:"replace_from,omitempty"`
+}
+
+// metricsEntry es una línea del journal: una fila o una replaceMarker
+type metricsEntry struct {
+    models.Metrics
+    replaceMarker
+}
+
+func (s *FileStorage) StoreMetrics(metrics []models.Metrics) error {
+    s.writeMu.Lock()
+    defer s.writeMu.Unlock()
+
+    entries := make([]interface{}, len(metrics))
+    for i := range metrics {
+        entries[i] = metrics[i]
+    }
+
+    // Primero a disco: si falla, la copia en memoria no diverge del fichero
+    if err := s.journal.Append(entries...); err != nil {
+        return err
+    }
+    return s.MemoryStorage.StoreMetrics(metrics)
+}
+
+// ReplaceMetrics escribe en el journal la marca de borrado y las filas nuevas
+// de una sola vez antes de aplicarlas en memoria
+func (s *FileStorage) ReplaceMetrics(from time.Time, metrics []models.Metrics) error {
+    s.writeMu.Lock()
+    defer s.writeMu.Unlock()
+
+    entries := make([]interface{}, 0, len(metrics)+1)
+    entries = append(entries, replaceMarker{ReplaceFrom: from.Format("2006-01-02")})
+    for i := range metrics {
+        entries = append(entries, metrics[i])
+    }
+
+    if err := s.journal.Append(entries...); err != nil {
+        return err
+    }
+    return s.MemoryStorage.ReplaceMetrics(from, metrics)
+}
+
+// compact reescribe el journal dejando solo la última versión de cada fila
+func (s *FileStorage) compact() error {
+    current := s.MemoryStorage.GetMetrics(func(models.Metrics) bool { return true })
+    entries := make([]interface{}, len(current))
+    for i := range current {
+        entries[i] = current[i]
+    }
+    return s.journal.Rewrite(entries)
+}
+
+func (s *FileStorage) Close() error {
+    return s.journal.Close()
+}
@@ -0,0 +1,184 @@
+package storage
+
+import (
+    "crypto/rand"
+    "encoding/hex"
+    "encoding/json"
+    "sync"
+    "time"
+)
+
+// ExportAttempt registra un intento de entrega de un chunk a un sink
+type ExportAttempt struct {
+    ID       string `json:"id"`
+    BatchID  string `json:"batch_id"`
+    OutboxID string `json:"outbox_id"`
+    Sink     string `json:"sink"`
+    SinkType string `json:"sink_type,omitempty"`
+    Format   string `json:"format,omitempty"`
+    // From y To son el rango de la exportación; Date es el día del chunk
+    From     string `json:"from"`
+    To       string `json:"to"`
+    Date     string `json:"date"`
+    Sequence int    `json:"sequence"`
+    Total    int    `json:"total"`
+    Records  int    `json:"records"`
+    // PayloadHash es el sha256 del cuerpo enviado
+    PayloadHash string `json:"payload_sha256,omitempty"`
+    KeyID       string `json:"key_id,omitempty"`
+    HTTPStatus  int    `json:"http_status,omitempty"`
+    LatencyMs   int64  `json:"latency_ms"`
+    Attempt     int    `json:"attempt"`
+    // Trigger es export para el primer intento y retry para los del outbox
+    Trigger     string    `json:"trigger"`
+    Status      string    `json:"status"`
+    Location    string    `json:"location,omitempty"`
+    Error       string    `json:"error,omitempty"`
+    AttemptedAt time.Time `json:"attempted_at"`
+}
+
+// ExportHistoryFilter filtra el historial; los campos vacíos no filtran.
+// From y To acotan el día del chunk (YYYY-MM-DD, ambos incluidos).
+type ExportHistoryFilter struct {
+    Sink     string
+    BatchID  string
+    OutboxID string
+    Status   string
+    From     string
+    To       string
+}
+
+// ExportHistory es el registro de auditoría de las entregas. Es append-only:
+// cada intento se persiste una vez y se descarta al superar la retención.
+type ExportHistory struct {
+    mu        sync.RWMutex
+    attempts  []ExportAttempt
+    retention time.Duration
+    journal   *Journal
+}
+
+// NewExportHistory crea un historial en memoria; retention 0 conserva todo
+func NewExportHistory(retention time.Duration) *ExportHistory {
+    return &ExportHistory{retention: retention}
+}
+
+func OpenExportHistoryStore(path string, retention time.Duration) (*ExportHistory, error) {
+    journal, err := OpenJournal(path)
+    if err != nil {
+        return nil, err
+    }
+
+    h := NewExportHistory(retention)
+    h.journal = journal
+
+    err = journal.Replay(func(line []byte) error {
+        var attempt ExportAttempt
+        if err := json.Unmarshal(line, &attempt); err != nil {
+            return err
+        }
+        h.attempts = append(h.attempts, attempt)
+        return nil
+    })
+    if err != nil {
+        journal.Close()
+        return nil, err
+    }
+
+    // Compactar si hay intentos fuera de la retención
+    if h.expireLocked(time.Now()) {
+        entries := make([]interface{}, 0, len(h.attempts))
+        for i := range h.attempts {
+            entries = append(entries, &h.attempts[i])
+        }
+        if err := journal.Rewrite(entries); err != nil {
+            journal.Close()
+            return nil, err
+        }
+    }
+    return h, nil
+}
+
+// Record añade un intento al historial y le asigna ID
+func (h *ExportHistory) Record(attempt ExportAttempt) (ExportAttempt, error) {
+    h.mu.Lock()
+    defer h.mu.Unlock()
+
+    attempt.ID = newHistoryID()
+    if attempt.AttemptedAt.IsZero() {
+        attempt.AttemptedAt = time.Now().UTC()
+    }
+
+    if h.journal != nil {
+        if err := h.journal.Append(&attempt); err != nil {
+            return ExportAttempt{}, err
+        }
+    }
+    h.attempts = append(h.attempts, attempt)
+    h.expireLocked(time.Now())
+    return attempt, nil
+}
+
+// List devuelve los intentos más recientes primero
+func (h *ExportHistory) List(filter ExportHistoryFilter) []ExportAttempt {
+    h.mu.RLock()
+    defer h.mu.RUnlock()
+
+    result := make([]ExportAttempt, 0)
+    for i := len(h.attempts) - 1; i >= 0; i-- {
+        attempt := h.attempts[i]
+        if filter.Sink != "" && attempt.Sink != filter.Sink {
+            continue
+        }
+        if filter.BatchID != "" && attempt.BatchID != filter.BatchID {
+            continue
+        }
+        if filter.OutboxID != "" && attempt.OutboxID != filter.OutboxID {
+            continue
+        }
+        if filter.Status != "" && attempt.Status != filter.Status {
+            continue
+        }
+        // Las fechas YYYY-MM-DD se pueden comparar como texto
+        if filter.From != "" && attempt.Date < filter.From {
+            continue
+        }
+        if filter.To != "" && attempt.Date > filter.To {
+            continue
+        }
+        result = append(result, attempt)
+    }
+    return result
+}
+
+func (h *ExportHistory) Close() error {
+    if h.journal != nil {
+        return h.journal.Close()
+    }
+    return nil
+}
+
+// expireLocked descarta de memoria los intentos más antiguos que la retención;
+// el journal se compacta al abrirlo. Como el historial está en orden de
+// llegada basta con recortar el principio.
+func (h *ExportHistory) expireLocked(now time.Time) bool {
+    if h.retention <= 0 {
+        return false
+    }
+
+    cutoff := now.Add(-h.retention)
+    expired := 0
+    for expired < len(h.attempts) && h.attempts[expired].AttemptedAt.Before(cutoff) {
+        expired++
+    }
+    if expired == 0 {
+        return false
+    }
+    h.attempts = append([]ExportAttempt{}, h.attempts[expired:]...)
+    return true
+}
+
+func newHistoryID() string {
+    b := make([]byte, 8)
+    rand.Read(b)
+    return "exp_" + hex.EncodeToString(b)
+}
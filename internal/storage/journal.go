@@ -0,0 +1,123 @@
+package storage
+
+import (
+    "bufio"
+    "encoding/json"
+    "fmt"
+    "os"
+    "path/filepath"
+    "sync"
+)
+
+// Journal es un fichero append-only de entradas JSON, una por línea.
+// Cada Append se sincroniza a disco antes de volver.
+type Journal struct {
+    mu   sync.Mutex
+    path string
+    file *os.File
+}
+
+func OpenJournal(path string) (*Journal, error) {
+    if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+        return nil, fmt.Errorf("failed to create data directory: %v", err)
+    }
+
+    file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_RDWR, 0o644)
+    if err != nil {
+        return nil, fmt.Errorf("failed to open journal %s: %v", path, err)
+    }
+
+    return &Journal{path: path, file: file}, nil
+}
+
+// Append escribe las entradas al final del fichero
+func (j *Journal) Append(entries ...interface{}) error {
+    j.mu.Lock()
+    defer j.mu.Unlock()
+
+    var buf []byte
+    for _, entry := range entries {
+        line, err := json.Marshal(entry)
+        if err != nil {
+            return fmt.Errorf("failed to marshal journal entry: %v", err)
+        }
+        buf = append(buf, line...)
+        buf = append(buf, '\n')
+    }
+
+    if _, err := j.file.Write(buf); err != nil {
+        return fmt.Errorf("failed to write journal: %v", err)
+    }
+    return j.file.Sync()
+}
+
+// Replay recorre las líneas del fichero en orden. Las líneas que no son JSON
+// válido (p.ej. una escritura cortada por un crash) se ignoran.
+func (j *Journal) Replay(fn func(line []byte) error) error {
+    j.mu.Lock()
+    defer j.mu.Unlock()
+
+    file, err := os.Open(j.path)
+    if err != nil {
+        return err
+    }
+    defer file.Close()
+
+    scanner := bufio.NewScanner(file)
+    scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+    for scanner.Scan() {
+        line := scanner.Bytes()
+        if len(line) == 0 || !json.Valid(line) {
+            fmt.Printf("Debug: Ignorando línea inválida en %s\n", j.path)
+            continue
+        }
+        if err := fn(line); err != nil {
+            return err
+        }
+    }
+    return scanner.Err()
+}
+
+// Rewrite reemplaza atómicamente el contenido del fichero (compactación)
+func (j *Journal) Rewrite(entries []interface{}) error {
+    j.mu.Lock()
+    defer j.mu.Unlock()
+
+    tmpPath := j.path + ".tmp"
+    tmp, err := os.Create(tmpPath)
+    if err != nil {
+        return fmt.Errorf("failed to create journal snapshot: %v", err)
+    }
+
+    writer := bufio.NewWriter(tmp)
+    encoder := json.NewEncoder(writer)
+    for _, entry := range entries {
+        if err := encoder.Encode(entry); err != nil {
+            tmp.Close()
+            return fmt.Errorf("failed to marshal journal entry: %v", err)
+        }
+    }
+    if err := writer.Flush(); err != nil {
+        tmp.Close()
+        return err
+    }
+    if err := tmp.Sync(); err != nil {
+        tmp.Close()
+        return err
+    }
+    tmp.Close()
+
+    if err := os.Rename(tmpPath, j.path); err != nil {
+        return fmt.Errorf("failed to replace journal: %v", err)
+    }
+
+    j.file.Close()
+    j.file, err = os.OpenFile(j.path, os.O_CREATE|os.O_APPEND|os.O_RDWR, 0o644)
+    return err
+}
+
+func (j *Journal) Close() error {
+    j.mu.Lock()
+    defer j.mu.Unlock()
+    return j.file.Close()
+}
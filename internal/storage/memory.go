@@ -3,28 +3,78 @@
 import (
     "sync"
     "time"
+    "admira-etl/internal/etl"
     "admira-etl/internal/models"
 )
 
 type MemoryStorage struct {
     mu      sync.RWMutex
     metrics []models.Metrics
+    index   map[etl.MetricKey]int
 }
 
 func NewMemoryStorage() *MemoryStorage {
     return &MemoryStorage{
         metrics: make([]models.Metrics, 0),
+        index:   make(map[etl.MetricKey]int),
     }
 }
 
+// StoreMetrics hace upsert por MetricKey: reingestar la misma ventana
+// reemplaza las filas existentes en lugar de sumarlas
 func (s *MemoryStorage) StoreMetrics(metrics []models.Metrics) error {
     s.mu.Lock()
     defer s.mu.Unlock()
     
-    s.metrics = append(s.metrics, metrics...)
+    s.storeLocked(metrics)
     return nil
 }
 
+func (s *MemoryStorage) storeLocked(metrics []models.Metrics) {
+    for _, metric := range metrics {
+        key := etl.MetricKeyOf(metric)
+        if i, exists := s.index[key]; exists {
+            s.metrics[i] = metric
+            continue
+        }
+        s.index[key] = len(s.metrics)
+        s.metrics = append(s.metrics, metric)
+    }
+}
+
+// ReplaceMetrics borra las filas con fecha desde from y guarda metrics en su
+// lugar: las claves que ya no aparecen al recalcular una ventana desaparecen
+func (s *MemoryStorage) ReplaceMetrics(from time.Time, metrics []models.Metrics) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    
+    s.deleteFromLocked(from.Format("2006-01-02"))
+    s.storeLocked(metrics)
+    return nil
+}
+
+// deleteFromLocked borra las filas con fecha igual o posterior a from (YYYY-MM-DD)
+func (s *MemoryStorage) deleteFromLocked(from string) {
+    kept := make([]models.Metrics, 0, len(s.metrics))
+    for _, metric := range s.metrics {
+        if metric.Date < from {
+            kept = append(kept, metric)
+        }
+    }
+    s.metrics = kept
+    s.index = make(map[etl.MetricKey]int, len(kept))
+    for i, metric := range kept {
+        s.index[etl.MetricKeyOf(metric)] = i
+    }
+}
+
+// Len devuelve el número de filas almacenadas
+func (s *MemoryStorage) Len() int {
+    s.mu.RLock()
+    defer s.mu.RUnlock()
+    return len(s.metrics)
+}
+
 func (s *MemoryStorage) GetMetrics(filter func(models.Metrics) bool) []models.Metrics {
     s.mu.RLock()
     defer s.mu.RUnlock()
@@ -72,3 +122,7 @@ func (s *MemoryStorage) GetMetricsByDate(date time.Time) []models.Metrics {
         return metricDate.Equal(date)
     })
 }
+
+func (s *MemoryStorage) Close() error {
+    return nil
+}
@@ -0,0 +1,348 @@
+package storage
+
+import (
+    "crypto/rand"
+    "encoding/hex"
+    "encoding/json"
+    "fmt"
+    "sync"
+    "time"
+
+    "admira-etl/internal/models"
+)
+
+type OutboxStatus string
+
+const (
+    OutboxPending   OutboxStatus = "pending"
+    OutboxDelivered OutboxStatus = "delivered"
+    OutboxDead      OutboxStatus = "dead"
+)
+
+// outboxRetention es cuánto se conservan las entregas completadas desde que se
+// entregaron. Las de la dead-letter list se conservan hasta que se reintentan
+// o se borran con Remove.
+const outboxRetention = 7 * 24 * time.Hour
+
+// OutboxEntry es la entrega de un lote exportado a un sink concreto. Se
+// escribe antes del primer intento para que un fallo no pierda el lote; las
+// métricas se descartan al entregarlo.
+type OutboxEntry struct {
+    ID            string           `json:"id"`
+    Sink          string           `json:"sink"`
+    BatchID       string           `json:"batch_id"`
+    Sequence      int              `json:"sequence"`
+    Total         int              `json:"total"`
+    From          string           `json:"from,omitempty"`
+    To            string           `json:"to,omitempty"`
+    Date          string           `json:"date"`
+    Part          int              `json:"part"`
+    Parts         int              `json:"parts"`
+    GroupBy       []string         `json:"group_by,omitempty"`
+    Records       int              `json:"records"`
+    Metrics       []models.Metrics `json:"metrics,omitempty"`
+    ExportedAt    time.Time        `json:"exported_at"`
+    Status        OutboxStatus     `json:"status"`
+    Attempts      int              `json:"attempts"`
+    NextAttemptAt time.Time        `json:"next_attempt_at"`
+    LastError     string           `json:"last_error,omitempty"`
+    Location      string           `json:"location,omitempty"`
+    CreatedAt     time.Time        `json:"created_at"`
+    UpdatedAt     time.Time        `json:"updated_at"`
+    DeliveredAt   *time.Time       `json:"delivered_at,omitempty"`
+}
+
+// OutboxFilter filtra el listado; los campos vacíos no filtran
+type OutboxFilter struct {
+    Status  OutboxStatus
+    Sink    string
+    Date    string
+    BatchID string
+}
+
+// Outbox guarda las entregas pendientes, completadas y fallidas (dead-letter)
+type Outbox struct {
+    mu      sync.RWMutex
+    entries map[string]*OutboxEntry
+    order   []string
+    journal *Journal
+    // snapshots es el número de líneas del journal, incluidas las versiones
+    // ya sustituidas de cada entrada
+    snapshots int
+}
+
+func NewOutbox() *Outbox {
+    return &Outbox{
+        entries: make(map[string]*OutboxEntry),
+    }
+}
+
+func OpenOutboxStore(path string) (*Outbox, error) {
+    journal, err := OpenJournal(path)
+    if err != nil {
+        return nil, err
+    }
+
+    o := NewOutbox()
+    o.journal = journal
+
+    err = journal.Replay(func(line []byte) error {
+        var entry OutboxEntry
+        if err := json.Unmarshal(line, &entry); err != nil {
+            return err
+        }
+        o.putLocked(&entry)
+        o.snapshots++
+        return nil
+    })
+    if err != nil {
+        journal.Close()
+        return nil, err
+    }
+
+    if _, err := o.Prune(time.Now()); err != nil {
+        journal.Close()
+        return nil, err
+    }
+    return o, nil
+}
+
+// Enqueue añade un lote pendiente, listo para entregarse ya. Del entry se usan
+// el sink, los datos del lote y las métricas; el resto lo rellena el outbox.
+func (o *Outbox) Enqueue(entry OutboxEntry) (OutboxEntry, error) {
+    o.mu.Lock()
+    defer o.mu.Unlock()
+
+    now := time.Now().UTC()
+    entry.ID = newOutboxID()
+    entry.Records = len(entry.Metrics)
+    entry.Status = OutboxPending
+    entry.Attempts = 0
+    entry.NextAttemptAt = now
+    entry.LastError = ""
+    entry.Location = ""
+    entry.CreatedAt = now
+    entry.UpdatedAt = now
+    entry.DeliveredAt = nil
+
+    if err := o.persistLocked(&entry); err != nil {
+        return OutboxEntry{}, err
+    }
+    o.putLocked(&entry)
+    return entry, nil
+}
+
+// MarkDelivered registra un intento correcto
+func (o *Outbox) MarkDelivered(id, location string) (OutboxEntry, error) {
+    return o.update(id, func(entry *OutboxEntry, now time.Time) {
+        entry.Attempts++
+        entry.Status = OutboxDelivered
+        entry.Location = location
+        entry.LastError = ""
+        entry.DeliveredAt = &now
+        entry.Metrics = nil
+    })
+}
+
+// MarkFailed registra un intento fallido. Con dead la entrada pasa a la
+// dead-letter list; si no, se reintenta en next.
+func (o *Outbox) MarkFailed(id, reason string, next time.Time, dead bool) (OutboxEntry, error) {
+    return o.update(id, func(entry *OutboxEntry, now time.Time) {
+        entry.Attempts++
+        entry.LastError = reason
+        entry.NextAttemptAt = next
+        if dead {
+            entry.Status = OutboxDead
+        }
+    })
+}
+
+// Remove borra una entrada de la dead-letter list y su lote
+func (o *Outbox) Remove(id string) (OutboxEntry, error) {
+    o.mu.Lock()
+    defer o.mu.Unlock()
+
+    entry, exists := o.entries[id]
+    if !exists {
+        return OutboxEntry{}, fmt.Errorf("outbox entry %s not found", id)
+    }
+    if entry.Status != OutboxDead {
+        return OutboxEntry{}, fmt.Errorf("outbox entry %s is %s, only dead entries can be removed", id, entry.Status)
+    }
+
+    delete(o.entries, id)
+    for i, other := range o.order {
+        if other == id {
+            o.order = append(o.order[:i], o.order[i+1:]...)
+            break
+        }
+    }
+    // El journal no tiene borrados: se reescribe sin la entrada
+    if err := o.rewriteLocked(); err != nil {
+        o.putLocked(entry)
+        return OutboxEntry{}, err
+    }
+    return *entry, nil
+}
+
+// Requeue devuelve una entrada de la dead-letter list a pendiente con los
+// intentos a cero
+func (o *Outbox) Requeue(id string) (OutboxEntry, error) {
+    o.mu.RLock()
+    entry, exists := o.entries[id]
+    o.mu.RUnlock()
+    if exists && entry.Status != OutboxDead {
+        return OutboxEntry{}, fmt.Errorf("outbox entry %s is %s, only dead entries can be replayed", id, entry.Status)
+    }
+
+    return o.update(id, func(entry *OutboxEntry, now time.Time) {
+        entry.Status = OutboxPending
+        entry.Attempts = 0
+        entry.NextAttemptAt = now
+    })
+}
+
+func (o *Outbox) update(id string, fn func(entry *OutboxEntry, now time.Time)) (OutboxEntry, error) {
+    o.mu.Lock()
+    defer o.mu.Unlock()
+
+    entry, exists := o.entries[id]
+    if !exists {
+        return OutboxEntry{}, fmt.Errorf("outbox entry %s not found", id)
+    }
+
+    updated := *entry
+    now := time.Now().UTC()
+    fn(&updated, now)
+    updated.UpdatedAt = now
+
+    if err := o.persistLocked(&updated); err != nil {
+        return OutboxEntry{}, err
+    }
+    o.putLocked(&updated)
+    return updated, nil
+}
+
+// Due devuelve las entradas pendientes cuyo próximo intento ya venció
+func (o *Outbox) Due(now time.Time) []OutboxEntry {
+    o.mu.RLock()
+    defer o.mu.RUnlock()
+
+    var due []OutboxEntry
+    for _, id := range o.order {
+        entry := o.entries[id]
+        if entry.Status == OutboxPending && !entry.NextAttemptAt.After(now) {
+            due = append(due, *entry)
+        }
+    }
+    return due
+}
+
+func (o *Outbox) Get(id string) (OutboxEntry, bool) {
+    o.mu.RLock()
+    defer o.mu.RUnlock()
+
+    entry, exists := o.entries[id]
+    if !exists {
+        return OutboxEntry{}, false
+    }
+    return *entry, true
+}
+
+// List devuelve las entradas más recientes primero
+func (o *Outbox) List(filter OutboxFilter) []OutboxEntry {
+    o.mu.RLock()
+    defer o.mu.RUnlock()
+
+    result := make([]OutboxEntry, 0)
+    for i := len(o.order) - 1; i >= 0; i-- {
+        entry := o.entries[o.order[i]]
+        if filter.Status != "" && entry.Status != filter.Status {
+            continue
+        }
+        if filter.Sink != "" && entry.Sink != filter.Sink {
+            continue
+        }
+        if filter.Date != "" && entry.Date != filter.Date {
+            continue
+        }
+        if filter.BatchID != "" && entry.BatchID != filter.BatchID {
+            continue
+        }
+        result = append(result, *entry)
+    }
+    return result
+}
+
+// Prune descarta las entregas completadas hace más de outboxRetention y
+// compacta el journal si guarda versiones sustituidas (p. ej. las métricas de
+// un lote ya entregado). Devuelve cuántas entradas se descartaron.
+func (o *Outbox) Prune(now time.Time) (int, error) {
+    o.mu.Lock()
+    defer o.mu.Unlock()
+
+    cutoff := now.Add(-outboxRetention)
+    var kept []string
+    for _, id := range o.order {
+        entry := o.entries[id]
+        if entry.Status == OutboxDelivered && entry.UpdatedAt.Before(cutoff) {
+            delete(o.entries, id)
+            continue
+        }
+        kept = append(kept, id)
+    }
+    pruned := len(o.order) - len(kept)
+    o.order = kept
+
+    if o.snapshots <= len(o.order) {
+        return pruned, nil
+    }
+    return pruned, o.rewriteLocked()
+}
+
+// rewriteLocked reescribe el journal con la última versión de cada entrada
+func (o *Outbox) rewriteLocked() error {
+    if o.journal == nil {
+        return nil
+    }
+    entries := make([]interface{}, 0, len(o.order))
+    for _, id := range o.order {
+        entries = append(entries, o.entries[id])
+    }
+    if err := o.journal.Rewrite(entries); err != nil {
+        return err
+    }
+    o.snapshots = len(entries)
+    return nil
+}
+
+func (o *Outbox) Close() error {
+    if o.journal != nil {
+        return o.journal.Close()
+    }
+    return nil
+}
+
+func (o *Outbox) putLocked(entry *OutboxEntry) {
+    if _, exists := o.entries[entry.ID]; !exists {
+        o.order = append(o.order, entry.ID)
+    }
+    o.entries[entry.ID] = entry
+}
+
+func (o *Outbox) persistLocked(entry *OutboxEntry) error {
+    if o.journal == nil {
+        return nil
+    }
+    if err := o.journal.Append(entry); err != nil {
+        return err
+    }
+    o.snapshots++
+    return nil
+}
+
+func newOutboxID() string {
+    b := make([]byte, 8)
+    rand.Read(b)
+    return "out_" + hex.EncodeToString(b)
+}
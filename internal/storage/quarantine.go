@@ -0,0 +1,234 @@
+package storage
+
+import (
+    "bytes"
+    "crypto/sha256"
+    "encoding/hex"
+    "encoding/json"
+    "fmt"
+    "sync"
+    "time"
+)
+
+type QuarantineStatus string
+
+const (
+    QuarantineOpen     QuarantineStatus = "quarantined"
+    QuarantineResolved QuarantineStatus = "resolved"
+)
+
+// QuarantinedRecord es un registro upstream que no pasó la validación
+type QuarantinedRecord struct {
+    ID          string           `json:"id"`
+    Source      string           `json:"source"`
+    Kind        string           `json:"kind"`
+    Raw         json.RawMessage  `json:"raw"`
+    Reason      string           `json:"reason"`
+    Status      QuarantineStatus `json:"status"`
+    Occurrences int              `json:"occurrences"`
+    FirstSeen   time.Time        `json:"first_seen"`
+    LastSeen    time.Time        `json:"last_seen"`
+    ResolvedAt  *time.Time       `json:"resolved_at,omitempty"`
+}
+
+// QuarantineFilter filtra el listado; los campos vacíos no filtran
+type QuarantineFilter struct {
+    Status QuarantineStatus
+    Source string
+    Kind   string
+}
+
+// QuarantineStore es la cola de registros inválidos (dead-letter) de la ingesta.
+// Con journal, cada cambio se persiste como una instantánea del registro.
+type QuarantineStore struct {
+    mu      sync.RWMutex
+    records map[string]*QuarantinedRecord
+    order   []string
+    journal *Journal
+}
+
+func NewQuarantineStore() *QuarantineStore {
+    return &QuarantineStore{
+        records: make(map[string]*QuarantinedRecord),
+    }
+}
+
+func OpenQuarantineStore(path string) (*QuarantineStore, error) {
+    journal, err := OpenJournal(path)
+    if err != nil {
+        return nil, err
+    }
+
+    q := NewQuarantineStore()
+    q.journal = journal
+
+    var snapshots int
+    err = journal.Replay(func(line []byte) error {
+        var record QuarantinedRecord
+        if err := json.Unmarshal(line, &record); err != nil {
+            return err
+        }
+        q.putLocked(&record)
+        snapshots++
+        return nil
+    })
+    if err != nil {
+        journal.Close()
+        return nil, err
+    }
+
+    // Compactar si el journal acumula varias versiones del mismo registro
+    if snapshots > len(q.order) {
+        entries := make([]interface{}, 0, len(q.order))
+        for _, id := range q.order {
+            entries = append(entries, q.records[id])
+        }
+        if err := journal.Rewrite(entries); err != nil {
+            journal.Close()
+            return nil, err
+        }
+    }
+    return q, nil
+}
+
+// QuarantineID identifica un registro por origen, tipo y contenido, de modo que
+// el mismo registro inválido en ingestas sucesivas no se duplica
+func QuarantineID(source, kind string, raw json.RawMessage) string {
+    // Compactar para que el ID no dependa del formato del JSON original
+    var compact bytes.Buffer
+    if err := json.Compact(&compact, raw); err != nil {
+        compact.Reset()
+        compact.Write(raw)
+    }
+
+    h := sha256.New()
+    h.Write([]byte(source + "\x00" + kind + "\x00"))
+    h.Write(compact.Bytes())
+    return "q_" + hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// Add pone un registro en cuarentena o actualiza sus ocurrencias si ya estaba
+func (q *QuarantineStore) Add(source, kind string, raw json.RawMessage, reason string) (QuarantinedRecord, error) {
+    q.mu.Lock()
+    defer q.mu.Unlock()
+
+    now := time.Now().UTC()
+    id := QuarantineID(source, kind, raw)
+
+    updated := QuarantinedRecord{
+        ID:        id,
+        Source:    source,
+        Kind:      kind,
+        Raw:       append(json.RawMessage{}, raw...),
+        FirstSeen: now,
+    }
+    if record, exists := q.records[id]; exists {
+        updated = *record
+    }
+    updated.Reason = reason
+    updated.Status = QuarantineOpen
+    updated.ResolvedAt = nil
+    updated.Occurrences++
+    updated.LastSeen = now
+
+    if err := q.persistLocked(&updated); err != nil {
+        return QuarantinedRecord{}, err
+    }
+    q.putLocked(&updated)
+    return updated, nil
+}
+
+// Resolve marca un registro como reprocesado correctamente
+func (q *QuarantineStore) Resolve(id string) (QuarantinedRecord, error) {
+    q.mu.Lock()
+    defer q.mu.Unlock()
+
+    record, exists := q.records[id]
+    if !exists {
+        return QuarantinedRecord{}, fmt.Errorf("quarantined record %s not found", id)
+    }
+
+    updated := *record
+    now := time.Now().UTC()
+    updated.Status = QuarantineResolved
+    updated.ResolvedAt = &now
+
+    if err := q.persistLocked(&updated); err != nil {
+        return QuarantinedRecord{}, err
+    }
+    q.putLocked(&updated)
+    return updated, nil
+}
+
+// UpdateReason actualiza el motivo tras un reproceso fallido
+func (q *QuarantineStore) UpdateReason(id, reason string) error {
+    q.mu.Lock()
+    defer q.mu.Unlock()
+
+    record, exists := q.records[id]
+    if !exists {
+        return fmt.Errorf("quarantined record %s not found", id)
+    }
+
+    updated := *record
+    updated.Reason = reason
+    if err := q.persistLocked(&updated); err != nil {
+        return err
+    }
+    q.putLocked(&updated)
+    return nil
+}
+
+func (q *QuarantineStore) Get(id string) (QuarantinedRecord, bool) {
+    q.mu.RLock()
+    defer q.mu.RUnlock()
+
+    record, exists := q.records[id]
+    if !exists {
+        return QuarantinedRecord{}, false
+    }
+    return *record, true
+}
+
+// List devuelve los registros más recientes primero
+func (q *QuarantineStore) List(filter QuarantineFilter) []QuarantinedRecord {
+    q.mu.RLock()
+    defer q.mu.RUnlock()
+
+    result := make([]QuarantinedRecord, 0)
+    for i := len(q.order) - 1; i >= 0; i-- {
+        record := q.records[q.order[i]]
+        if filter.Status != "" && record.Status != filter.Status {
+            continue
+        }
+        if filter.Source != "" && record.Source != filter.Source {
+            continue
+        }
+        if filter.Kind != "" && record.Kind != filter.Kind {
+            continue
+        }
+        result = append(result, *record)
+    }
+    return result
+}
+
+func (q *QuarantineStore) Close() error {
+    if q.journal != nil {
+        return q.journal.Close()
+    }
+    return nil
+}
+
+func (q *QuarantineStore) putLocked(record *QuarantinedRecord) {
+    if _, exists := q.records[record.ID]; !exists {
+        q.order = append(q.order, record.ID)
+    }
+    q.records[record.ID] = record
+}
+
+func (q *QuarantineStore) persistLocked(record *QuarantinedRecord) error {
+    if q.journal == nil {
+        return nil
+    }
+    return q.journal.Append(record)
+}
@@ -0,0 +1,578 @@
+package storage
+
+import (
+    "bytes"
+    "encoding/json"
+    "fmt"
+    "net/url"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+    "sync"
+    "time"
+
+    "admira-etl/internal/etl"
+    "admira-etl/internal/models"
+)
+
+// Tipos de registro que se guardan por día
+const (
+    recordsAds = "ads"
+    recordsCRM = "crm"
+)
+
+// Dentro del directorio los registros guardados están en recordsSources y cada
+// carga escribe los días que toca en recordsStaging hasta que termina bien
+const (
+    recordsSources   = "sources"
+    recordsStaging   = "staging"
+    recordsStateFile = "state.json"
+)
+
+// RecordStore guarda por origen y día los registros de Ads y CRM extraídos,
+// para recalcular las métricas de una ventana con todos sus datos y no solo con
+// los de la última extracción incremental. Los registros CRM se agrupan por su
+// día de creación. Con directorio cada día es un fichero JSON y solo se leen los
+// días que se recalculan, así que la memoria no crece con el histórico; sin él
+// todo queda en memoria.
+type RecordStore struct {
+    mu  sync.Mutex
+    dir string
+    // days guarda el contenido de cada día cuando no hay directorio
+    days  map[string][]byte
+    state recordState
+    // loads numera las cargas para separar sus días en preparación
+    loads int
+}
+
+// recordState es el estado de las métricas calculadas con los registros
+type recordState struct {
+    // Pending es el primer día con cambios cuyas métricas no se han recalculado
+    Pending string `json:"pending,omitempty"`
+    // Loading es el primer día que cambia una carga mientras se confirma; si
+    // el proceso se para a mitad se pasa a Pending al abrir
+    Loading string `json:"loading,omitempty"`
+    // Attribution es la atribución con la que se calcularon las métricas
+    // guardadas; nil si no se conoce o si mezclan varias
+    Attribution *etl.AttributionConfig `json:"attribution,omitempty"`
+}
+
+func NewRecordStore() *RecordStore {
+    return &RecordStore{days: make(map[string][]byte)}
+}
+
+func OpenRecordStore(dir string) (*RecordStore, error) {
+    if err := os.MkdirAll(dir, 0o755); err != nil {
+        return nil, fmt.Errorf("failed to create records directory: %v", err)
+    }
+
+    r := &RecordStore{dir: dir}
+    data, err := os.ReadFile(filepath.Join(dir, recordsStateFile))
+    if err != nil && !os.IsNotExist(err) {
+        return nil, err
+    }
+    if len(data) > 0 {
+        if err := json.Unmarshal(data, &r.state); err != nil {
+            return nil, fmt.Errorf("invalid records state: %v", err)
+        }
+    }
+
+    // Las cargas sin terminar no llegaron a cambiar nada
+    if err := os.RemoveAll(filepath.Join(dir, recordsStaging)); err != nil {
+        return nil, err
+    }
+    // Una carga interrumpida al confirmarse pudo cambiar días sin registrarlos
+    // como pendientes
+    if r.state.Loading != "" {
+        r.state.Pending = earliest(r.state.Pending, r.state.Loading)
+        r.state.Loading = ""
+        if err := r.saveStateLocked(); err != nil {
+            return nil, err
+        }
+    }
+    return r, nil
+}
+
+// Pending devuelve el primer día con cambios sin recalcular, vacío si no hay
+func (r *RecordStore) Pending() string {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    return r.state.Pending
+}
+
+// Attribution devuelve la atribución con la que se calcularon las métricas
+// guardadas, si se conoce
+func (r *RecordStore) Attribution() (etl.AttributionConfig, bool) {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    if r.state.Attribution == nil {
+        return etl.AttributionConfig{}, false
+    }
+    return *r.state.Attribution, true
+}
+
+// MetricsLoaded registra una carga de métricas recalculadas: con cleared ya
+// no queda nada pendiente, y attribution es la atribución de todas las
+// métricas guardadas (nil si mezclan varias)
+func (r *RecordStore) MetricsLoaded(cleared bool, attribution *etl.AttributionConfig) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    state := r.state
+    if cleared {
+        state.Pending = ""
+    }
+    state.Attribution = attribution
+    return r.setStateLocked(state)
+}
+
+// Add añade registros sueltos (p. ej. reprocesados de la cuarentena) a los de
+// su origen sin sustituir los días que ya estaban guardados
+func (r *RecordStore) Add(source string, ads []models.AdsPerformance, crm []models.CRMOpportunity) error {
+    load := r.Load(source, false, "9999-12-31")
+    if err := load.Add(ads, crm); err != nil {
+        load.Finish(false)
+        return err
+    }
+    return load.Finish(true)
+}
+
+// RecordLoad es la carga de los registros de un origen durante una ingesta.
+// Cada página se escribe según llega en los días en preparación de la carga:
+// la primera vez que toca un día lo empieza de cero y las siguientes le añaden
+// filas. Los días anteriores a completeFrom pueden llegar incompletos y se
+// combinan registro a registro con lo guardado. Nada cambia hasta Finish.
+type RecordLoad struct {
+    store        *RecordStore
+    source       string
+    full         bool
+    completeFrom string
+    staging      string
+    // touched son los días (tipo y fecha) que ha escrito la carga
+    touched map[string]bool
+}
+
+// Load abre la carga de un origen. Con full la extracción es completa y al
+// confirmarla se borran los días del origen que no llegaron.
+func (r *RecordStore) Load(source string, full bool, completeFrom string) *RecordLoad {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    r.loads++
+    return &RecordLoad{
+        store:        r,
+        source:       source,
+        full:         full,
+        completeFrom: completeFrom,
+        staging:      filepath.Join(recordsStaging, fmt.Sprintf("%d", r.loads)),
+        touched:      make(map[string]bool),
+    }
+}
+
+// Add guarda una página de registros del origen
+func (l *RecordLoad) Add(ads []models.AdsPerformance, crm []models.CRMOpportunity) error {
+    adsDays := make(map[string][]json.RawMessage)
+    for _, ad := range ads {
+        ad.IngestedAt = time.Time{}
+        if err := appendRecord(adsDays, ad.Date, ad); err != nil {
+            return err
+        }
+    }
+    crmDays := make(map[string][]json.RawMessage)
+    for _, record := range crm {
+        record.IngestedAt = time.Time{}
+        if err := appendRecord(crmDays, crmDay(record), record); err != nil {
+            return err
+        }
+    }
+
+    r := l.store
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    for _, group := range []struct {
+        kind string
+        days map[string][]json.RawMessage
+    }{{recordsAds, adsDays}, {recordsCRM, crmDays}} {
+        for _, day := range sortedDays(group.days) {
+            if err := l.addDayLocked(group.kind, day, group.days[day]); err != nil {
+                return err
+            }
+        }
+    }
+    return nil
+}
+
+func (l *RecordLoad) addDayLocked(kind, day string, records []json.RawMessage) error {
+    r := l.store
+    name := filepath.Join(kind, day+".json")
+    path := filepath.Join(l.staging, name)
+    if !l.touched[name] && day < l.completeFrom {
+        // Un día incompleto parte de lo que ya estaba guardado
+        current, err := r.readLocked(dayPath(l.source, kind, day))
+        if err != nil {
+            return err
+        }
+        if err := r.writeLocked(path, current); err != nil {
+            return err
+        }
+    }
+    l.touched[name] = true
+
+    current, err := r.readLocked(path)
+    if err != nil {
+        return err
+    }
+    staged, err := decodeDay(current)
+    if err != nil {
+        return fmt.Errorf("invalid records in %s: %v", path, err)
+    }
+    if day < l.completeFrom {
+        // Día incompleto: se añaden solo los registros que no estaban
+        for _, record := range records {
+            if !containsRecord(staged, record) {
+                staged = append(staged, record)
+            }
+        }
+    } else {
+        staged = append(staged, records...)
+    }
+    return r.writeLocked(path, encodeDay(staged))
+}
+
+// Finish cierra la carga. Con succeeded sustituye los días guardados por los
+// que escribió la carga, borra en una extracción completa los que no llegaron
+// y deja como pendientes los que cambiaron; sin él los descarta.
+func (l *RecordLoad) Finish(succeeded bool) error {
+    r := l.store
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    if !succeeded {
+        return r.removeLocked(l.staging)
+    }
+
+    // Qué días cambian se decide antes de tocar nada, para apuntarlos en
+    // Loading por si el proceso se para a mitad
+    changes := make(map[string][]byte)
+    changed := ""
+    for _, kind := range []string{recordsAds, recordsCRM} {
+        days, err := r.listDaysLocked(l.source, kind)
+        if err != nil {
+            return err
+        }
+        for _, day := range days {
+            if l.full && !l.touched[filepath.Join(kind, day+".json")] {
+                changes[dayPath(l.source, kind, day)] = nil
+                changed = earliest(changed, day)
+            }
+        }
+    }
+    for name := range l.touched {
+        kind, file := filepath.Split(name)
+        day := strings.TrimSuffix(file, ".json")
+        path := dayPath(l.source, filepath.Clean(kind), day)
+        staged, err := r.readLocked(filepath.Join(l.staging, name))
+        if err != nil {
+            return err
+        }
+        current, err := r.readLocked(path)
+        if err != nil {
+            return err
+        }
+        if !bytes.Equal(staged, current) {
+            changes[path] = staged
+            changed = earliest(changed, day)
+        }
+    }
+    if changed == "" {
+        return r.removeLocked(l.staging)
+    }
+
+    state := r.state
+    state.Loading = changed
+    if err := r.setStateLocked(state); err != nil {
+        return err
+    }
+    for path, data := range changes {
+        if err := r.writeLocked(path, data); err != nil {
+            return err
+        }
+    }
+    state.Pending = earliest(state.Pending, changed)
+    state.Loading = ""
+    if err := r.setStateLocked(state); err != nil {
+        return err
+    }
+    return r.removeLocked(l.staging)
+}
+
+// Window recorre, de todos los orígenes y día a día, las filas de Ads con
+// fecha desde adsFrom y los registros CRM creados desde crmFrom. Solo se leen
+// los días de la ventana y el orden es estable para que los repartos de la
+// atribución sean reproducibles.
+func (r *RecordStore) Window(adsFrom, crmFrom string, fn func(ads []models.AdsPerformance, crm []models.CRMOpportunity)) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    sources, err := r.listSourcesLocked()
+    if err != nil {
+        return err
+    }
+    for _, source := range sources {
+        for _, kind := range []string{recordsAds, recordsCRM} {
+            from := adsFrom
+            if kind == recordsCRM {
+                from = crmFrom
+            }
+            days, err := r.listDaysLocked(source, kind)
+            if err != nil {
+                return err
+            }
+            for _, day := range days {
+                if day < from {
+                    continue
+                }
+                data, err := r.readLocked(dayPath(source, kind, day))
+                if err != nil {
+                    return err
+                }
+                if kind == recordsAds {
+                    var ads []models.AdsPerformance
+                    if err := json.Unmarshal(data, &ads); err != nil {
+                        return fmt.Errorf("invalid ads records of %s on %s: %v", source, day, err)
+                    }
+                    fn(ads, nil)
+                    continue
+                }
+                var crm []models.CRMOpportunity
+                if err := json.Unmarshal(data, &crm); err != nil {
+                    return fmt.Errorf("invalid crm records of %s on %s: %v", source, day, err)
+                }
+                fn(nil, crm)
+            }
+        }
+    }
+    return nil
+}
+
+func (r *RecordStore) Close() error {
+    return nil
+}
+
+func (r *RecordStore) setStateLocked(state recordState) error {
+    previous := r.state
+    r.state = state
+    if err := r.saveStateLocked(); err != nil {
+        r.state = previous
+        return err
+    }
+    return nil
+}
+
+func (r *RecordStore) saveStateLocked() error {
+    if r.dir == "" {
+        return nil
+    }
+    data, err := json.Marshal(r.state)
+    if err != nil {
+        return err
+    }
+    return writeFileAtomic(filepath.Join(r.dir, recordsStateFile), data)
+}
+
+// readLocked devuelve el contenido de un día, nil si no existe
+func (r *RecordStore) readLocked(path string) ([]byte, error) {
+    if r.dir == "" {
+        return r.days[path], nil
+    }
+    data, err := os.ReadFile(filepath.Join(r.dir, path))
+    if os.IsNotExist(err) {
+        return nil, nil
+    }
+    return data, err
+}
+
+// writeLocked sustituye el contenido de un día; sin contenido lo borra
+func (r *RecordStore) writeLocked(path string, data []byte) error {
+    if r.dir == "" {
+        if data == nil {
+            delete(r.days, path)
+        } else {
+            r.days[path] = data
+        }
+        return nil
+    }
+    full := filepath.Join(r.dir, path)
+    if data == nil {
+        if err := os.Remove(full); err != nil && !os.IsNotExist(err) {
+            return err
+        }
+        return nil
+    }
+    if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+        return err
+    }
+    return writeFileAtomic(full, data)
+}
+
+// listDaysLocked devuelve los días guardados de un origen y tipo, ordenados
+func (r *RecordStore) listDaysLocked(source, kind string) ([]string, error) {
+    prefix := filepath.Join(recordsSources, url.PathEscape(source), kind) + string(filepath.Separator)
+    var days []string
+    if r.dir == "" {
+        for path := range r.days {
+            if strings.HasPrefix(path, prefix) {
+                days = append(days, strings.TrimSuffix(strings.TrimPrefix(path, prefix), ".json"))
+            }
+        }
+    } else {
+        entries, err := os.ReadDir(filepath.Join(r.dir, prefix))
+        if err != nil && !os.IsNotExist(err) {
+            return nil, err
+        }
+        for _, entry := range entries {
+            if name := entry.Name(); strings.HasSuffix(name, ".json") {
+                days = append(days, strings.TrimSuffix(name, ".json"))
+            }
+        }
+    }
+    sort.Strings(days)
+    return days, nil
+}
+
+// listSourcesLocked devuelve los orígenes con registros guardados, ordenados
+func (r *RecordStore) listSourcesLocked() ([]string, error) {
+    prefix := recordsSources + string(filepath.Separator)
+    seen := make(map[string]bool)
+    if r.dir == "" {
+        for path := range r.days {
+            if strings.HasPrefix(path, prefix) {
+                seen[strings.SplitN(strings.TrimPrefix(path, prefix), string(filepath.Separator), 2)[0]] = true
+            }
+        }
+    } else {
+        entries, err := os.ReadDir(filepath.Join(r.dir, recordsSources))
+        if err != nil && !os.IsNotExist(err) {
+            return nil, err
+        }
+        for _, entry := range entries {
+            if entry.IsDir() {
+                seen[entry.Name()] = true
+            }
+        }
+    }
+
+    var sources []string
+    for escaped := range seen {
+        source, err := url.PathUnescape(escaped)
+        if err != nil {
+            continue
+        }
+        sources = append(sources, source)
+    }
+    sort.Strings(sources)
+    return sources, nil
+}
+
+// removeLocked borra un directorio de días entero
+func (r *RecordStore) removeLocked(dir string) error {
+    if r.dir == "" {
+        prefix := dir + string(filepath.Separator)
+        for path := range r.days {
+            if strings.HasPrefix(path, prefix) {
+                delete(r.days, path)
+            }
+        }
+        return nil
+    }
+    return os.RemoveAll(filepath.Join(r.dir, dir))
+}
+
+func dayPath(source, kind, day string) string {
+    return filepath.Join(recordsSources, url.PathEscape(source), kind, day+".json")
+}
+
+// appendRecord añade un registro a su día; el día debe ser YYYY-MM-DD porque
+// da nombre al fichero
+func appendRecord(days map[string][]json.RawMessage, day string, record interface{}) error {
+    if _, err := time.Parse("2006-01-02", day); err != nil {
+        return fmt.Errorf("invalid record date %q", day)
+    }
+    encoded, err := json.Marshal(record)
+    if err != nil {
+        return err
+    }
+    days[day] = append(days[day], encoded)
+    return nil
+}
+
+func decodeDay(data []byte) ([]json.RawMessage, error) {
+    if len(data) == 0 {
+        return nil, nil
+    }
+    var records []json.RawMessage
+    err := json.Unmarshal(data, &records)
+    return records, err
+}
+
+// encodeDay escribe un registro por línea; un día vacío se borra
+func encodeDay(records []json.RawMessage) []byte {
+    if len(records) == 0 {
+        return nil
+    }
+    var buf bytes.Buffer
+    buf.WriteString("[\n")
+    for i, record := range records {
+        buf.Write(record)
+        if i < len(records)-1 {
+            buf.WriteByte(',')
+        }
+        buf.WriteByte('\n')
+    }
+    buf.WriteString("]\n")
+    return buf.Bytes()
+}
+
+// containsRecord compara registros enteros: dos registros con el mismo
+// opportunity_id pero otro contenido (p. ej. el historial de etapas) son distintos
+func containsRecord(records []json.RawMessage, record json.RawMessage) bool {
+    for _, stored := range records {
+        if bytes.Equal(stored, record) {
+            return true
+        }
+    }
+    return false
+}
+
+func crmDay(record models.CRMOpportunity) string {
+    return record.CreatedAt.Format("2006-01-02")
+}
+
+func sortedDays(days map[string][]json.RawMessage) []string {
+    result := make([]string, 0, len(days))
+    for day := range days {
+        result = append(result, day)
+    }
+    sort.Strings(result)
+    return result
+}
+
+// earliest devuelve el menor de dos días, ignorando los vacíos
+func earliest(a, b string) string {
+    if a == "" || (b != "" && b < a) {
+        return b
+    }
+    return a
+}
+
+// writeFileAtomic escribe en un temporal y lo renombra para no dejar ficheros a medias
+func writeFileAtomic(path string, data []byte) error {
+    tmpPath := path + ".tmp"
+    if err := os.WriteFile(tmpPath, data, 0o644); err != nil {
+        return err
+    }
+    return os.Rename(tmpPath, path)
+}
@@ -0,0 +1,75 @@
+package storage
+
+import (
+    "fmt"
+    "path/filepath"
+    "time"
+
+    "admira-etl/internal/models"
+    "admira-etl/pkg/config"
+)
+
+// Storage abstrae el almacenamiento de métricas consolidadas
+type Storage interface {
+    StoreMetrics(metrics []models.Metrics) error
+    // ReplaceMetrics sustituye todas las filas con fecha desde from por metrics
+    ReplaceMetrics(from time.Time, metrics []models.Metrics) error
+    GetMetrics(filter func(models.Metrics) bool) []models.Metrics
+    GetMetricsByChannel(channel string, from, to time.Time) []models.Metrics
+    GetMetricsByCampaign(campaign string, from, to time.Time) []models.Metrics
+    GetMetricsByDate(date time.Time) []models.Metrics
+    Close() error
+}
+
+// Open crea el backend de almacenamiento seleccionado en la configuración
+func Open(cfg *config.Config) (Storage, error) {
+    switch cfg.StorageBackend {
+    case "", "memory":
+        return NewMemoryStorage(), nil
+    case "file":
+        return NewFileStorage(filepath.Join(cfg.DataDir, "metrics.jsonl"))
+    default:
+        return nil, fmt.Errorf("unknown storage backend: %s", cfg.StorageBackend)
+    }
+}
+
+// OpenQuarantine crea la cuarentena de registros inválidos con el mismo backend
+func OpenQuarantine(cfg *config.Config) (*QuarantineStore, error) {
+    if cfg.StorageBackend == "file" {
+        return OpenQuarantineStore(filepath.Join(cfg.DataDir, "quarantine.jsonl"))
+    }
+    return NewQuarantineStore(), nil
+}
+
+// OpenOutbox crea el outbox de exportaciones con el mismo backend
+func OpenOutbox(cfg *config.Config) (*Outbox, error) {
+    if cfg.StorageBackend == "file" {
+        return OpenOutboxStore(filepath.Join(cfg.DataDir, "outbox.jsonl"))
+    }
+    return NewOutbox(), nil
+}
+
+// OpenExportHistory crea el historial de exportaciones con el mismo backend
+func OpenExportHistory(cfg *config.Config) (*ExportHistory, error) {
+    retention := time.Duration(cfg.ExportHistoryRetentionDays) * 24 * time.Hour
+    if cfg.StorageBackend == "file" {
+        return OpenExportHistoryStore(filepath.Join(cfg.DataDir, "export_history.jsonl"), retention)
+    }
+    return NewExportHistory(retention), nil
+}
+
+// OpenWatermarks crea el almacén de marcas de extracción incremental con el mismo backend
+func OpenWatermarks(cfg *config.Config) (*WatermarkStore, error) {
+    if cfg.StorageBackend == "file" {
+        return OpenWatermarkStore(filepath.Join(cfg.DataDir, "watermarks.jsonl"))
+    }
+    return NewWatermarkStore(), nil
+}
+
+// OpenRecords crea el almacén de registros extraídos con el mismo backend
+func OpenRecords(cfg *config.Config) (*RecordStore, error) {
+    if cfg.StorageBackend == "file" {
+        return OpenRecordStore(filepath.Join(cfg.DataDir, "records"))
+    }
+    return NewRecordStore(), nil
+}
@@ -0,0 +1,40 @@
+package test
+
+import (
+    "path/filepath"
+    "testing"
+    "time"
+    "admira-etl/internal/storage"
+)
+
+func TestExportHistory_FiltersAndRetention(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "export_history.jsonl")
+    history, err := storage.OpenExportHistoryStore(path, 24*time.Hour)
+    if err != nil {
+        t.Fatalf("OpenExportHistoryStore failed: %v", err)
+    }
+
+    // Un intento fuera de la retención y dos recientes de días distintos
+    history.Record(storage.ExportAttempt{Sink: "webhook", Date: "2024-05-01", Status: "delivered", AttemptedAt: time.Now().Add(-48 * time.Hour)})
+    history.Record(storage.ExportAttempt{Sink: "webhook", Date: "2024-05-03", Status: "retrying"})
+    history.Record(storage.ExportAttempt{Sink: "webhook", Date: "2024-05-03", Status: "delivered"})
+    history.Close()
+
+    history, err = storage.OpenExportHistoryStore(path, 24*time.Hour)
+    if err != nil {
+        t.Fatalf("Reopen failed: %v", err)
+    }
+    defer history.Close()
+
+    if all := history.List(storage.ExportHistoryFilter{}); len(all) != 2 {
+        t.Fatalf("Expected the expired attempt to be dropped, got %+v", all)
+    }
+
+    delivered := history.List(storage.ExportHistoryFilter{From: "2024-05-03", To: "2024-05-03", Status: "delivered"})
+    if len(delivered) != 1 || delivered[0].ID == "" {
+        t.Errorf("Expected 2024-05-03 to be delivered once, got %+v", delivered)
+    }
+    if rest := history.List(storage.ExportHistoryFilter{To: "2024-05-02"}); len(rest) != 0 {
+        t.Errorf("Expected no attempts before 2024-05-03, got %+v", rest)
+    }
+}
@@ -0,0 +1,126 @@
+package test
+
+import (
+    "path/filepath"
+    "testing"
+    "time"
+    "admira-etl/internal/models"
+    "admira-etl/internal/storage"
+)
+
+func outboxEntry(sink string) storage.OutboxEntry {
+    return storage.OutboxEntry{
+        Sink:    sink,
+        BatchID: "batch_test",
+        Date:    "2025-08-01",
+        Metrics: []models.Metrics{{Date: "2025-08-01", Channel: "google_ads", Clicks: 10}},
+    }
+}
+
+func TestOpenOutboxStore_ReplaysAndCompacts(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "outbox.jsonl")
+    outbox, err := storage.OpenOutboxStore(path)
+    if err != nil {
+        t.Fatalf("OpenOutboxStore failed: %v", err)
+    }
+
+    pending, _ := outbox.Enqueue(outboxEntry("webhook"))
+    delivered, _ := outbox.Enqueue(outboxEntry("local"))
+    dead, _ := outbox.Enqueue(outboxEntry("bucket"))
+    outbox.MarkFailed(pending.ID, "HTTP 500", time.Now().Add(time.Minute), false)
+    if entry, err := outbox.MarkDelivered(delivered.ID, "file:///tmp/x.json"); err != nil || entry.Metrics != nil || entry.Records != 1 {
+        t.Fatalf("Expected delivered entry without metrics, got %+v (%v)", entry, err)
+    }
+    outbox.MarkFailed(dead.ID, "HTTP 500", time.Now(), true)
+    outbox.Close()
+
+    if lines := journalLines(t, path); lines != 6 {
+        t.Fatalf("Expected 6 snapshots before compaction, got %d", lines)
+    }
+
+    outbox, err = storage.OpenOutboxStore(path)
+    if err != nil {
+        t.Fatalf("Reopen failed: %v", err)
+    }
+    defer outbox.Close()
+
+    if lines := journalLines(t, path); lines != 3 {
+        t.Errorf("Expected the journal to be compacted to 3 entries, got %d", lines)
+    }
+    if entry, _ := outbox.Get(pending.ID); entry.Status != storage.OutboxPending || entry.Attempts != 1 || len(entry.Metrics) != 1 {
+        t.Errorf("Unexpected pending entry after replay: %+v", entry)
+    }
+    if entry, _ := outbox.Get(delivered.ID); entry.Status != storage.OutboxDelivered || entry.Metrics != nil {
+        t.Errorf("Unexpected delivered entry after replay: %+v", entry)
+    }
+    // Las entradas muertas conservan las métricas para poder reenviarlas
+    if entry, _ := outbox.Get(dead.ID); entry.Status != storage.OutboxDead || len(entry.Metrics) != 1 {
+        t.Errorf("Unexpected dead entry after replay: %+v", entry)
+    }
+    if due := outbox.Due(time.Now().Add(time.Hour)); len(due) != 1 || due[0].ID != pending.ID {
+        t.Errorf("Expected only the pending entry to be due, got %+v", due)
+    }
+}
+
+func TestOutbox_PruneDropsFinishedEntries(t *testing.T) {
+    outbox := storage.NewOutbox()
+
+    pending, _ := outbox.Enqueue(outboxEntry("webhook"))
+    delivered, _ := outbox.Enqueue(outboxEntry("local"))
+    dead, _ := outbox.Enqueue(outboxEntry("bucket"))
+    outbox.MarkDelivered(delivered.ID, "")
+    outbox.MarkFailed(dead.ID, "HTTP 500", time.Now(), true)
+
+    if pruned, err := outbox.Prune(time.Now()); err != nil || pruned != 0 {
+        t.Fatalf("Expected recent entries to be kept, pruned %d (%v)", pruned, err)
+    }
+
+    // Pasada la retención se descarta la entrega completada; la de la
+    // dead-letter list sigue ahí hasta que se reintenta o se borra
+    pruned, err := outbox.Prune(time.Now().Add(8 * 24 * time.Hour))
+    if err != nil || pruned != 1 {
+        t.Fatalf("Expected 1 pruned entry, got %d (%v)", pruned, err)
+    }
+    if _, ok := outbox.Get(delivered.ID); ok {
+        t.Errorf("Expected the delivered entry to be pruned")
+    }
+    if entry, ok := outbox.Get(dead.ID); !ok || len(entry.Metrics) != 1 {
+        t.Errorf("Expected the dead entry and its batch to be kept, got %+v", entry)
+    }
+    if _, ok := outbox.Get(pending.ID); !ok {
+        t.Errorf("Expected the pending entry to be kept")
+    }
+}
+
+func TestOutbox_RemoveDeadEntry(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "outbox.jsonl")
+    outbox, err := storage.OpenOutboxStore(path)
+    if err != nil {
+        t.Fatalf("OpenOutboxStore failed: %v", err)
+    }
+
+    pending, _ := outbox.Enqueue(outboxEntry("webhook"))
+    dead, _ := outbox.Enqueue(outboxEntry("bucket"))
+    outbox.MarkFailed(dead.ID, "HTTP 500", time.Now(), true)
+
+    if _, err := outbox.Remove(pending.ID); err == nil {
+        t.Error("Expected an error removing a pending entry")
+    }
+    if _, err := outbox.Remove(dead.ID); err != nil {
+        t.Fatalf("Remove failed: %v", err)
+    }
+    outbox.Close()
+
+    outbox, err = storage.OpenOutboxStore(path)
+    if err != nil {
+        t.Fatalf("Reopen failed: %v", err)
+    }
+    defer outbox.Close()
+
+    if _, ok := outbox.Get(dead.ID); ok {
+        t.Errorf("Expected the removed entry to stay removed after reopening")
+    }
+    if all := outbox.List(storage.OutboxFilter{}); len(all) != 1 || all[0].ID != pending.ID {
+        t.Errorf("Expected only the pending entry to remain, got %+v", all)
+    }
+}
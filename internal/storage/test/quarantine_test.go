@@ -0,0 +1,98 @@
+package test
+
+import (
+    "bytes"
+    "encoding/json"
+    "os"
+    "path/filepath"
+    "testing"
+    "admira-etl/internal/storage"
+)
+
+func journalLines(t *testing.T, path string) int {
+    data, err := os.ReadFile(path)
+    if err != nil {
+        t.Fatalf("ReadFile failed: %v", err)
+    }
+    return bytes.Count(data, []byte("\n"))
+}
+
+func TestQuarantineStore_AddDeduplicatesByContent(t *testing.T) {
+    quarantine := storage.NewQuarantineStore()
+
+    first, err := quarantine.Add("crm", "crm", json.RawMessage(`{"opportunity_id": "O-1", "created_at": "08/01/2025"}`), "invalid created_at")
+    if err != nil {
+        t.Fatalf("Add failed: %v", err)
+    }
+    // El mismo registro con otro formato JSON es el mismo ID
+    second, _ := quarantine.Add("crm", "crm", json.RawMessage(`{"opportunity_id":"O-1","created_at":"08/01/2025"}`), "still invalid")
+    if second.ID != first.ID || second.Occurrences != 2 || second.Reason != "still invalid" || !second.FirstSeen.Equal(first.FirstSeen) {
+        t.Errorf("Expected the same record with 2 occurrences, got %+v", second)
+    }
+    // En otro origen es otro registro
+    if other, _ := quarantine.Add("hubspot", "crm", json.RawMessage(`{"opportunity_id":"O-1","created_at":"08/01/2025"}`), "invalid"); other.ID == first.ID {
+        t.Errorf("Expected a different ID for another source, got %s", other.ID)
+    }
+    if records := quarantine.List(storage.QuarantineFilter{Source: "crm"}); len(records) != 1 {
+        t.Errorf("Expected a single crm record, got %+v", records)
+    }
+}
+
+func TestQuarantineStore_ResolveAndReopen(t *testing.T) {
+    quarantine := storage.NewQuarantineStore()
+
+    if _, err := quarantine.Resolve("q_missing"); err == nil {
+        t.Error("Expected an error resolving an unknown record")
+    }
+
+    raw := json.RawMessage(`{"date":"2025/08/01"}`)
+    record, _ := quarantine.Add("ads", "ads", raw, "invalid date")
+    resolved, err := quarantine.Resolve(record.ID)
+    if err != nil || resolved.Status != storage.QuarantineResolved || resolved.ResolvedAt == nil {
+        t.Fatalf("Expected a resolved record, got %+v (%v)", resolved, err)
+    }
+    if open := quarantine.List(storage.QuarantineFilter{Status: storage.QuarantineOpen}); len(open) != 0 {
+        t.Errorf("Expected no open records, got %+v", open)
+    }
+
+    // Si el upstream lo vuelve a enviar inválido vuelve a la cuarentena
+    reopened, _ := quarantine.Add("ads", "ads", raw, "invalid date")
+    if reopened.Status != storage.QuarantineOpen || reopened.ResolvedAt != nil || reopened.Occurrences != 2 {
+        t.Errorf("Expected the record to be quarantined again, got %+v", reopened)
+    }
+}
+
+func TestOpenQuarantineStore_ReplaysJournal(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "quarantine.jsonl")
+    quarantine, err := storage.OpenQuarantineStore(path)
+    if err != nil {
+        t.Fatalf("OpenQuarantineStore failed: %v", err)
+    }
+
+    resolved, _ := quarantine.Add("ads", "ads", json.RawMessage(`{"date":"2025/08/01"}`), "invalid date")
+    open, _ := quarantine.Add("crm", "crm", json.RawMessage(`{"created_at":"x"}`), "invalid created_at")
+    quarantine.Add("crm", "crm", json.RawMessage(`{"created_at":"x"}`), "invalid created_at")
+    quarantine.Resolve(resolved.ID)
+    quarantine.UpdateReason(open.ID, "still invalid")
+    quarantine.Close()
+
+    if lines := journalLines(t, path); lines != 5 {
+        t.Fatalf("Expected 5 snapshots before compaction, got %d", lines)
+    }
+
+    quarantine, err = storage.OpenQuarantineStore(path)
+    if err != nil {
+        t.Fatalf("Reopen failed: %v", err)
+    }
+    defer quarantine.Close()
+
+    if lines := journalLines(t, path); lines != 2 {
+        t.Errorf("Expected the journal to be compacted to 2 entries, got %d", lines)
+    }
+    if record, _ := quarantine.Get(resolved.ID); record.Status != storage.QuarantineResolved {
+        t.Errorf("Expected a resolved record after replay, got %+v", record)
+    }
+    if record, _ := quarantine.Get(open.ID); record.Status != storage.QuarantineOpen || record.Occurrences != 2 || record.Reason != "still invalid" {
+        t.Errorf("Unexpected open record after replay: %+v", record)
+    }
+}
@@ -0,0 +1,181 @@
+package test
+
+import (
+    "os"
+    "path/filepath"
+    "testing"
+    "time"
+    "admira-etl/internal/etl"
+    "admira-etl/internal/models"
+    "admira-etl/internal/storage"
+)
+
+func adsRow(date string, clicks int) models.AdsPerformance {
+    return models.AdsPerformance{Date: date, CampaignID: "C-1", Channel: "google_ads", Clicks: clicks, UTMCampaign: "bts", IngestedAt: time.Now()}
+}
+
+func crmRecord(id, created, stage string) models.CRMOpportunity {
+    createdAt, _ := time.Parse("2006-01-02", created)
+    return models.CRMOpportunity{OpportunityID: id, Stage: stage, CreatedAt: createdAt, UTMCampaign: "bts", IngestedAt: time.Now()}
+}
+
+// load guarda las páginas de una extracción que termina bien
+func load(t *testing.T, records *storage.RecordStore, source string, full bool, completeFrom string, pages ...[]models.AdsPerformance) {
+    l := records.Load(source, full, completeFrom)
+    for _, page := range pages {
+        if err := l.Add(page, nil); err != nil {
+            t.Fatalf("Add failed: %v", err)
+        }
+    }
+    if err := l.Finish(true); err != nil {
+        t.Fatalf("Finish failed: %v", err)
+    }
+}
+
+// window devuelve todo lo que Window recorre desde los días indicados
+func window(t *testing.T, records *storage.RecordStore, adsFrom, crmFrom string) ([]models.AdsPerformance, []models.CRMOpportunity) {
+    var ads []models.AdsPerformance
+    var crm []models.CRMOpportunity
+    err := records.Window(adsFrom, crmFrom, func(a []models.AdsPerformance, c []models.CRMOpportunity) {
+        ads = append(ads, a...)
+        crm = append(crm, c...)
+    })
+    if err != nil {
+        t.Fatalf("Window failed: %v", err)
+    }
+    return ads, crm
+}
+
+func TestRecordStore_LoadTracksPending(t *testing.T) {
+    records := storage.NewRecordStore()
+
+    // Las páginas de un mismo día se suman
+    load(t, records, "ads", true, "", []models.AdsPerformance{adsRow("2025-08-01", 10)}, []models.AdsPerformance{adsRow("2025-08-01", 3), adsRow("2025-08-02", 5)})
+    if pending := records.Pending(); pending != "2025-08-01" {
+        t.Fatalf("Expected pending 2025-08-01 after the first load, got %q", pending)
+    }
+    records.MetricsLoaded(true, nil)
+
+    // Los mismos registros con otro IngestedAt no cambian nada
+    load(t, records, "ads", false, "", []models.AdsPerformance{adsRow("2025-08-02", 5)})
+    if pending := records.Pending(); pending != "" {
+        t.Errorf("Expected nothing pending for unchanged records, got %q", pending)
+    }
+
+    // Una extracción incremental sustituye solo sus días
+    load(t, records, "ads", false, "", []models.AdsPerformance{adsRow("2025-08-02", 7)})
+    if pending := records.Pending(); pending != "2025-08-02" {
+        t.Errorf("Expected pending 2025-08-02 after changing a day, got %q", pending)
+    }
+    ads, _ := window(t, records, "", "")
+    if len(ads) != 3 || ads[2].Clicks != 7 {
+        t.Fatalf("Unexpected records after incremental loads: %+v", ads)
+    }
+
+    // Una extracción completa borra los días que ya no están
+    records.MetricsLoaded(true, nil)
+    load(t, records, "ads", true, "", []models.AdsPerformance{adsRow("2025-08-02", 7)})
+    if pending := records.Pending(); pending != "2025-08-01" {
+        t.Errorf("Expected pending 2025-08-01 after a full load drops it, got %q", pending)
+    }
+    if ads, _ := window(t, records, "", ""); len(ads) != 1 || ads[0].Date != "2025-08-02" {
+        t.Errorf("Expected only 2025-08-02 after the full load, got %+v", ads)
+    }
+    if ads, crm := window(t, records, "2025-08-03", "2025-08-04"); len(ads) != 0 || len(crm) != 0 {
+        t.Errorf("Expected an empty window, got %+v %+v", ads, crm)
+    }
+}
+
+func TestRecordStore_FailedLoadChangesNothing(t *testing.T) {
+    records := storage.NewRecordStore()
+    load(t, records, "ads", true, "", []models.AdsPerformance{adsRow("2025-08-01", 10), adsRow("2025-08-02", 5)})
+    records.MetricsLoaded(true, nil)
+
+    l := records.Load("ads", true, "")
+    l.Add([]models.AdsPerformance{adsRow("2025-08-01", 1)}, nil)
+    if err := l.Finish(false); err != nil {
+        t.Fatalf("Finish failed: %v", err)
+    }
+    if pending := records.Pending(); pending != "" {
+        t.Errorf("Expected nothing pending after a failed load, got %q", pending)
+    }
+    if ads, _ := window(t, records, "", ""); len(ads) != 2 || ads[0].Clicks != 10 {
+        t.Errorf("Expected the stored days to be kept, got %+v", ads)
+    }
+}
+
+func TestRecordStore_KeepsCRMRowsWithTheSameID(t *testing.T) {
+    records := storage.NewRecordStore()
+
+    // El historial de etapas repite opportunity_id y cuenta cada fila
+    history := []models.CRMOpportunity{crmRecord("O-1", "2025-08-01", "lead"), crmRecord("O-1", "2025-08-01", "opportunity")}
+    if err := records.Add("crm", nil, history); err != nil {
+        t.Fatalf("Add failed: %v", err)
+    }
+    // Un día incompleto solo añade los registros que no estaban
+    l := records.Load("crm", false, "2025-08-02")
+    l.Add(nil, []models.CRMOpportunity{crmRecord("O-1", "2025-08-01", "opportunity"), crmRecord("O-1", "2025-08-01", "closed_won")})
+    l.Finish(true)
+
+    _, crm := window(t, records, "", "")
+    if len(crm) != 3 || crm[0].Stage != "lead" || crm[2].Stage != "closed_won" {
+        t.Errorf("Expected the three stage rows of O-1, got %+v", crm)
+    }
+}
+
+func TestOpenRecordStore_ReadsDaysAndState(t *testing.T) {
+    dir := filepath.Join(t.TempDir(), "records")
+    records, err := storage.OpenRecordStore(dir)
+    if err != nil {
+        t.Fatalf("OpenRecordStore failed: %v", err)
+    }
+
+    load(t, records, "ads", true, "", []models.AdsPerformance{adsRow("2025-08-01", 10), adsRow("2025-08-02", 5)})
+    records.Add("crm", nil, []models.CRMOpportunity{crmRecord("O-1", "2025-08-01", "lead"), crmRecord("O-2", "2025-08-02", "closed_won")})
+    attribution := etl.AttributionConfig{Model: "linear", LookbackDays: 7}
+    records.MetricsLoaded(true, &attribution)
+    load(t, records, "ads", false, "", []models.AdsPerformance{adsRow("2025-08-02", 8)})
+    // Una carga que no termina no deja nada al reabrir
+    records.Load("ads", false, "").Add([]models.AdsPerformance{adsRow("2025-08-03", 1)}, nil)
+    records.Close()
+
+    records, err = storage.OpenRecordStore(dir)
+    if err != nil {
+        t.Fatalf("Reopen failed: %v", err)
+    }
+    defer records.Close()
+
+    if pending := records.Pending(); pending != "2025-08-02" {
+        t.Errorf("Expected pending 2025-08-02 after reopening, got %q", pending)
+    }
+    if stored, ok := records.Attribution(); !ok || stored != attribution {
+        t.Errorf("Expected the stored attribution %+v, got %+v (%v)", attribution, stored, ok)
+    }
+    ads, crm := window(t, records, "2025-08-02", "2025-08-01")
+    if len(ads) != 1 || ads[0].Clicks != 8 {
+        t.Errorf("Unexpected ads after reopening: %+v", ads)
+    }
+    if len(crm) != 2 || crm[1].OpportunityID != "O-2" {
+        t.Errorf("Unexpected CRM records after reopening: %+v", crm)
+    }
+    if _, err := os.Stat(filepath.Join(dir, "staging")); !os.IsNotExist(err) {
+        t.Errorf("Expected unfinished loads to be removed, got %v", err)
+    }
+}
+
+func TestOpenRecordStore_InterruptedCommitIsPending(t *testing.T) {
+    dir := t.TempDir()
+    state := `{"loading":"2025-08-03","attribution":{"model":"linear","lookback_days":7}}`
+    if err := os.WriteFile(filepath.Join(dir, "state.json"), []byte(state), 0o644); err != nil {
+        t.Fatal(err)
+    }
+    records, err := storage.OpenRecordStore(dir)
+    if err != nil {
+        t.Fatalf("OpenRecordStore failed: %v", err)
+    }
+    defer records.Close()
+
+    if pending := records.Pending(); pending != "2025-08-03" {
+        t.Errorf("Expected the interrupted day to be pending, got %q", pending)
+    }
+}
@@ -0,0 +1,149 @@
+package test
+
+import (
+    "path/filepath"
+    "testing"
+    "time"
+    "admira-etl/internal/etl"
+    "admira-etl/internal/models"
+    "admira-etl/internal/storage"
+)
+
+func sampleData() ([]models.AdsPerformance, []models.CRMOpportunity) {
+    created, _ := time.Parse("2006-01-02", "2024-01-01")
+
+    adsData := []models.AdsPerformance{
+        {Date: "2024-01-01", CampaignID: "C-1001", Channel: "google_ads", Clicks: 100, Impressions: 5000, Cost: 50.0, UTMCampaign: "test_campaign", UTMSource: "google", UTMMedium: "cpc"},
+        {Date: "2024-01-02", CampaignID: "C-1001", Channel: "google_ads", Clicks: 80, Impressions: 4000, Cost: 40.0, UTMCampaign: "test_campaign", UTMSource: "google", UTMMedium: "cpc"},
+    }
+    crmData := []models.CRMOpportunity{
+        {OpportunityID: "O-1", Stage: "lead", CreatedAt: created, UTMCampaign: "test_campaign", UTMSource: "google", UTMMedium: "cpc"},
+        {OpportunityID: "O-2", Stage: "closed_won", Amount: 1000.0, CreatedAt: created, UTMCampaign: "test_campaign", UTMSource: "google", UTMMedium: "cpc"},
+    }
+    return adsData, crmData
+}
+
+func totals(metrics []models.Metrics) (clicks int, cost, revenue float64) {
+    for _, m := range metrics {
+        clicks += m.Clicks
+        cost += m.Cost
+        revenue += m.Revenue
+    }
+    return
+}
+
+func ingest(t *testing.T, store storage.Storage, runs int) {
+    transformer := etl.NewTransformer()
+    adsData, crmData := sampleData()
+
+    for i := 0; i < runs; i++ {
+        metrics, err := transformer.Transform(adsData, crmData)
+        if err != nil {
+            t.Fatalf("Transform failed: %v", err)
+        }
+        if err := store.StoreMetrics(metrics); err != nil {
+            t.Fatalf("StoreMetrics failed: %v", err)
+        }
+    }
+}
+
+func TestMemoryStorage_RepeatedIngestIsIdempotent(t *testing.T) {
+    once := storage.NewMemoryStorage()
+    ingest(t, once, 1)
+
+    repeated := storage.NewMemoryStorage()
+    ingest(t, repeated, 5)
+
+    all := func(models.Metrics) bool { return true }
+    onceRows := once.GetMetrics(all)
+    repeatedRows := repeated.GetMetrics(all)
+
+    if len(onceRows) != len(repeatedRows) {
+        t.Errorf("Expected %d rows after repeated ingests, got %d", len(onceRows), len(repeatedRows))
+    }
+
+    clicks1, cost1, revenue1 := totals(onceRows)
+    clicksN, costN, revenueN := totals(repeatedRows)
+    if clicks1 != clicksN || cost1 != costN || revenue1 != revenueN {
+        t.Errorf("Totals changed after repeated ingests: clicks %d/%d, cost %.2f/%.2f, revenue %.2f/%.2f",
+            clicks1, clicksN, cost1, costN, revenue1, revenueN)
+    }
+}
+
+func TestMemoryStorage_UpsertReplacesRow(t *testing.T) {
+    store := storage.NewMemoryStorage()
+
+    row := models.Metrics{Date: "2024-01-01", Channel: "google_ads", CampaignID: "C-1", UTMCampaign: "c", UTMSource: "google", UTMMedium: "cpc", Clicks: 10}
+    store.StoreMetrics([]models.Metrics{row})
+
+    row.Clicks = 25
+    store.StoreMetrics([]models.Metrics{row})
+
+    date, _ := time.Parse("2006-01-02", "2024-01-01")
+    stored := store.GetMetricsByDate(date)
+    if len(stored) != 1 || stored[0].Clicks != 25 {
+        t.Errorf("Expected a single row with 25 clicks, got %+v", stored)
+    }
+}
+
+func TestFileStorage_ReopenKeepsUpsertedRows(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "metrics.jsonl")
+
+    store, err := storage.NewFileStorage(path)
+    if err != nil {
+        t.Fatalf("NewFileStorage failed: %v", err)
+    }
+    ingest(t, store, 3)
+    expected := store.Len()
+    store.Close()
+
+    reopened, err := storage.NewFileStorage(path)
+    if err != nil {
+        t.Fatalf("Reopen failed: %v", err)
+    }
+    defer reopened.Close()
+
+    if reopened.Len() != expected {
+        t.Errorf("Expected %d rows after reopen, got %d", expected, reopened.Len())
+    }
+
+    clicks, _, _ := totals(reopened.GetMetrics(func(models.Metrics) bool { return true }))
+    if clicks != 180 {
+        t.Errorf("Expected 180 clicks after reopen, got %d", clicks)
+    }
+}
+
+func TestFileStorage_ReplaceMetricsSurvivesReopen(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "metrics.jsonl")
+
+    store, err := storage.NewFileStorage(path)
+    if err != nil {
+        t.Fatalf("NewFileStorage failed: %v", err)
+    }
+    store.StoreMetrics([]models.Metrics{
+        {Date: "2024-01-01", Channel: "google_ads", CampaignID: "C-1", Clicks: 10},
+        {Date: "2024-01-02", Channel: "google_ads", CampaignID: "C-1", Clicks: 20},
+        {Date: "2024-01-02", Channel: "organic", Leads: 1},
+    })
+
+    // La fila de organic desaparece al recalcular la ventana desde el día 2
+    from, _ := time.Parse("2006-01-02", "2024-01-02")
+    if err := store.ReplaceMetrics(from, []models.Metrics{{Date: "2024-01-02", Channel: "google_ads", CampaignID: "C-1", Clicks: 25}}); err != nil {
+        t.Fatalf("ReplaceMetrics failed: %v", err)
+    }
+    store.Close()
+
+    reopened, err := storage.NewFileStorage(path)
+    if err != nil {
+        t.Fatalf("Reopen failed: %v", err)
+    }
+    defer reopened.Close()
+
+    if reopened.Len() != 2 {
+        t.Errorf("Expected 2 rows after reopen, got %d", reopened.Len())
+    }
+    stored := reopened.GetMetricsByDate(from)
+    if len(stored) != 1 || stored[0].Clicks != 25 {
+        t.Errorf("Expected only the replaced row on 2024-01-02, got %+v", stored)
+    }
+}
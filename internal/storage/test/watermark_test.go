@@ -0,0 +1,45 @@
+package test
+
+import (
+    "path/filepath"
+    "testing"
+    "admira-etl/internal/etl"
+    "admira-etl/internal/storage"
+)
+
+func TestWatermarkStore_AdvanceOverrideAndReopen(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "watermarks.jsonl")
+    store, err := storage.OpenWatermarkStore(path)
+    if err != nil {
+        t.Fatalf("OpenWatermarkStore failed: %v", err)
+    }
+
+    store.Advance("ads", etl.IncrementalDate, "2025-08-03T00:00:00Z", "job_1")
+    // Una ingesta con overlap que no llega más lejos no hace retroceder la marca
+    if _, moved, _ := store.Advance("ads", etl.IncrementalDate, "2025-08-02T00:00:00Z", "job_2"); moved {
+        t.Errorf("Expected a date watermark never to move back")
+    }
+    store.Advance("crm", etl.IncrementalCursor, "sync-1", "job_2")
+    store.Advance("crm", etl.IncrementalCursor, "sync-2", "job_3")
+    // Un override manual sí puede ir hacia atrás (backfill)
+    store.Set("ads", etl.IncrementalDate, "2025-07-01T00:00:00Z")
+    store.Close()
+
+    store, err = storage.OpenWatermarkStore(path)
+    if err != nil {
+        t.Fatalf("Reopen failed: %v", err)
+    }
+    defer store.Close()
+
+    ads, _ := store.Get("ads")
+    if ads.Value != "2025-07-01T00:00:00Z" || !ads.Manual {
+        t.Errorf("Expected the manual override to persist, got %+v", ads)
+    }
+    crm, _ := store.Get("crm")
+    if crm.Value != "sync-2" || crm.JobID != "job_3" {
+        t.Errorf("Expected the latest cursor, got %+v", crm)
+    }
+    if marks := store.List(); len(marks) != 2 || marks[0].Source != "ads" {
+        t.Errorf("Expected marks sorted by source, got %+v", marks)
+    }
+}
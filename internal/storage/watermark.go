@@ -0,0 +1,174 @@
+package storage
+
+import (
+    "encoding/json"
+    "sort"
+    "sync"
+    "time"
+
+    "admira-etl/internal/etl"
+)
+
+// Watermark es la marca de la última extracción incremental correcta de un
+// origen. Un Value vacío hace que la siguiente ingesta descargue todo.
+type Watermark struct {
+    Source string `json:"source"`
+    Kind   string `json:"kind"`
+    Value  string `json:"value"`
+    // JobID es la ingesta que fijó la marca; Manual indica que se fijó a mano
+    JobID     string    `json:"job_id,omitempty"`
+    Manual    bool      `json:"manual"`
+    UpdatedAt time.Time `json:"updated_at"`
+}
+
+// WatermarkStore guarda la marca de cada origen incremental. Con journal, cada
+// cambio se persiste como una instantánea de la marca.
+type WatermarkStore struct {
+    mu      sync.RWMutex
+    marks   map[string]*Watermark
+    journal *Journal
+}
+
+func NewWatermarkStore() *WatermarkStore {
+    return &WatermarkStore{
+        marks: make(map[string]*Watermark),
+    }
+}
+
+func OpenWatermarkStore(path string) (*WatermarkStore, error) {
+    journal, err := OpenJournal(path)
+    if err != nil {
+        return nil, err
+    }
+
+    w := NewWatermarkStore()
+    w.journal = journal
+
+    var snapshots int
+    err = journal.Replay(func(line []byte) error {
+        var mark Watermark
+        if err := json.Unmarshal(line, &mark); err != nil {
+            return err
+        }
+        w.marks[mark.Source] = &mark
+        snapshots++
+        return nil
+    })
+    if err != nil {
+        journal.Close()
+        return nil, err
+    }
+
+    // Compactar si el journal acumula varias versiones de la misma marca
+    if snapshots > len(w.marks) {
+        entries := make([]interface{}, 0, len(w.marks))
+        for _, mark := range w.List() {
+            entries = append(entries, mark)
+        }
+        if err := journal.Rewrite(entries); err != nil {
+            journal.Close()
+            return nil, err
+        }
+    }
+    return w, nil
+}
+
+func (w *WatermarkStore) Get(source string) (Watermark, bool) {
+    w.mu.RLock()
+    defer w.mu.RUnlock()
+
+    mark, exists := w.marks[source]
+    if !exists {
+        return Watermark{}, false
+    }
+    return *mark, true
+}
+
+// List devuelve las marcas ordenadas por origen
+func (w *WatermarkStore) List() []Watermark {
+    w.mu.RLock()
+    defer w.mu.RUnlock()
+
+    result := make([]Watermark, 0, len(w.marks))
+    for _, mark := range w.marks {
+        result = append(result, *mark)
+    }
+    sort.Slice(result, func(i, j int) bool {
+        return result[i].Source < result[j].Source
+    })
+    return result
+}
+
+// Advance guarda la marca alcanzada por una ingesta. Una marca de fecha nunca
+// retrocede: si la guardada es posterior se conserva y devuelve false.
+func (w *WatermarkStore) Advance(source, kind, value, jobID string) (Watermark, bool, error) {
+    w.mu.Lock()
+    defer w.mu.Unlock()
+
+    if current, exists := w.marks[source]; exists && kind == etl.IncrementalDate && current.Kind == kind {
+        if !laterDate(value, current.Value) {
+            return *current, false, nil
+        }
+    }
+
+    mark := Watermark{
+        Source:    source,
+        Kind:      kind,
+        Value:     value,
+        JobID:     jobID,
+        UpdatedAt: time.Now().UTC(),
+    }
+    if err := w.persistLocked(&mark); err != nil {
+        return Watermark{}, false, err
+    }
+    w.marks[source] = &mark
+    return mark, true, nil
+}
+
+// Set fija a mano la marca de un origen, también hacia atrás para un
+// backfill; un Value vacío la resetea
+func (w *WatermarkStore) Set(source, kind, value string) (Watermark, error) {
+    w.mu.Lock()
+    defer w.mu.Unlock()
+
+    mark := Watermark{
+        Source:    source,
+        Kind:      kind,
+        Value:     value,
+        Manual:    true,
+        UpdatedAt: time.Now().UTC(),
+    }
+    if err := w.persistLocked(&mark); err != nil {
+        return Watermark{}, err
+    }
+    w.marks[source] = &mark
+    return mark, nil
+}
+
+func (w *WatermarkStore) Close() error {
+    if w.journal != nil {
+        return w.journal.Close()
+    }
+    return nil
+}
+
+func (w *WatermarkStore) persistLocked(mark *Watermark) error {
+    if w.journal == nil {
+        return nil
+    }
+    return w.journal.Append(mark)
+}
+
+// laterDate indica si value es posterior a current; una marca vacía o
+// ilegible siempre se reemplaza
+func laterDate(value, current string) bool {
+    currentTime, err := time.Parse(time.RFC3339Nano, current)
+    if err != nil {
+        return true
+    }
+    valueTime, err := time.Parse(time.RFC3339Nano, value)
+    if err != nil {
+        return false
+    }
+    return valueTime.After(currentTime)
+}
@@ -1,8 +1,11 @@
 package config
 
 import (
+	"encoding/json"
+	"fmt"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
@@ -14,9 +17,158 @@ type Config struct {
 	CrmURL      string
 	SinkURL     string
 	SinkSecret  string
+	SinkKeyID   string
 	Timeout     time.Duration
 	MaxRetries  int
 	BackoffTime time.Duration
+	// BackoffMax limita la espera entre reintentos de descarga y RetryAfterMax
+	// el Retry-After que se respeta
+	BackoffMax    time.Duration
+	RetryAfterMax time.Duration
+	Sources       []SourceConfig
+	Sinks         []SinkConfig
+
+	// Extracción: orígenes descargados en paralelo y política por defecto ante
+	// el fallo de un origen ("abort" o "continue")
+	ExtractConcurrency int
+	SourceOnError      string
+	// ExtractMaxPages limita las páginas de un origen paginado sin max_pages
+	ExtractMaxPages int
+
+	// Circuit breaker por origen: fallos consecutivos que lo abren y cool-down
+	BreakerFailureThreshold int
+	BreakerCoolDown         time.Duration
+
+	// Almacenamiento: "memory" (por defecto) o "file" (journal en DataDir)
+	StorageBackend string
+	DataDir        string
+
+	// Pool de workers para los jobs de ingesta asíncronos
+	IngestWorkers   int
+	IngestQueueSize int
+
+	// Ejecuciones programadas (expresiones cron; vacío = deshabilitado)
+	ScheduleIngest       string
+	ScheduleExport       string
+	ScheduleMissedPolicy string
+	ScheduleTimezone     string
+
+	// Atribución de registros CRM a filas de Ads
+	AttributionModel        string
+	AttributionLookbackDays int
+	AttributionHalfLifeDays float64
+
+	// Fichero JSON con las reglas de inferencia de canal (vacío = reglas por defecto)
+	ChannelRulesFile string
+
+	// Normalización de UTMs (trim, minúsculas, URL-decode) y tabla de alias
+	UTMNormalization bool
+	UTMAliasesFile   string
+
+	// Layouts de fecha adicionales (formato Go, separados por "|") para created_at
+	CRMDateFormats []string
+
+	// Primer día de la semana para granularity=week ("monday" = semanas ISO)
+	WeekStart string
+
+	// Outbox de exportaciones: intentos antes de dead-letter y backoff entre ellos
+	OutboxMaxAttempts  int
+	OutboxBackoff      time.Duration
+	OutboxBackoffMax   time.Duration
+	OutboxPollInterval time.Duration
+
+	// Tamaño máximo de cada chunk de una exportación (0 = sin límite)
+	ExportMaxRecords int
+	ExportMaxBytes   int
+	// ExportMaxRangeDays limita los días de una exportación from/to
+	ExportMaxRangeDays int
+	// ExportGroupBy son las dimensiones con las que se consolida cada día
+	// exportado (vacío = todas las de MetricKey)
+	ExportGroupBy []string
+	// Días que se conserva el historial de entregas (0 = indefinidamente)
+	ExportHistoryRetentionDays int
+}
+
+// SourceConfig describe un origen de datos upstream registrado en el extractor.
+// Format indica el decoder a usar ("ads", "crm" o uno registrado en etl).
+type SourceConfig struct {
+	Name   string `json:"name"`
+	Format string `json:"format"`
+	URL    string `json:"url"`
+	// OnError es "abort" o "continue" (vacío = SourceOnError)
+	OnError string `json:"on_error,omitempty"`
+	// Pagination indica cómo recorrer el upstream si pagina (nil = una sola petición)
+	Pagination *PaginationConfig `json:"pagination,omitempty"`
+	// Incremental activa la extracción incremental con marca persistida (nil = descarga completa)
+	Incremental *IncrementalConfig `json:"incremental,omitempty"`
+}
+
+// PaginationConfig describe cómo recorre el extractor un upstream paginado.
+// Type es "page", "offset", "cursor" o "next_link".
+type PaginationConfig struct {
+	Type string `json:"type"`
+
+	// page y offset: parámetro con el número de página (o el desplazamiento)
+	// y parámetro con el tamaño de página
+	PageParam   string `json:"page_param,omitempty"`
+	StartPage   int    `json:"start_page,omitempty"`
+	OffsetParam string `json:"offset_param,omitempty"`
+	SizeParam   string `json:"size_param,omitempty"`
+	PageSize    int    `json:"page_size,omitempty"`
+
+	// cursor: parámetro con el que se envía el cursor y ruta en la respuesta
+	// (separada por puntos, p. ej. "meta.next_cursor") del siguiente
+	CursorParam string `json:"cursor_param,omitempty"`
+	CursorPath  string `json:"cursor_path,omitempty"`
+
+	// next_link: ruta en la respuesta de la URL de la siguiente página
+	NextPath string `json:"next_path,omitempty"`
+
+	// MaxPages es el límite de seguridad de páginas (0 = ExtractMaxPages)
+	MaxPages int `json:"max_pages,omitempty"`
+}
+
+// IncrementalConfig describe cómo se envía a un upstream la marca (watermark)
+// de la última extracción correcta. Type es "date" (mayor date/created_at
+// extraído) o "cursor" (valor opaco que devuelve el propio upstream).
+type IncrementalConfig struct {
+	Type string `json:"type"`
+	// Param es el parámetro de consulta con el que se envía la marca
+	Param string `json:"param"`
+
+	// date: formato Go de la fecha enviada (por defecto 2006-01-02) y días que
+	// se restan para volver a descargar el final de la ventana anterior
+	Layout      string `json:"layout,omitempty"`
+	OverlapDays int    `json:"overlap_days,omitempty"`
+
+	// cursor: ruta en la respuesta (separada por puntos) del cursor de la
+	// siguiente extracción incremental
+	CursorPath string `json:"cursor_path,omitempty"`
+}
+
+// SinkConfig describe un destino de exportación. Type es "http", "file" o "s3";
+// Format es "json" (por defecto), "csv" o "ndjson".
+type SinkConfig struct {
+	Name   string `json:"name"`
+	Type   string `json:"type"`
+	Format string `json:"format"`
+
+	// http (en s3, KeyID es un identificador no secreto de las credenciales
+	// para el historial de exportaciones)
+	URL    string `json:"url,omitempty"`
+	Secret string `json:"secret,omitempty"`
+	KeyID  string `json:"key_id,omitempty"`
+
+	// file
+	Dir string `json:"dir,omitempty"`
+
+	// s3 (si no se indican credenciales se usan AWS_ACCESS_KEY_ID/AWS_SECRET_ACCESS_KEY)
+	Endpoint  string `json:"endpoint,omitempty"`
+	Bucket    string `json:"bucket,omitempty"`
+	Region    string `json:"region,omitempty"`
+	Prefix    string `json:"prefix,omitempty"`
+	AccessKey string `json:"access_key,omitempty"`
+	SecretKey string `json:"secret_key,omitempty"`
 }
 
 func LoadConfig() (*Config, error) {
@@ -26,17 +178,151 @@ func LoadConfig() (*Config, error) {
 	timeout, _ := strconv.Atoi(getEnv("TIMEOUT_SECONDS", "30"))
 	maxRetries, _ := strconv.Atoi(getEnv("MAX_RETRIES", "3"))
 	backoff, _ := strconv.Atoi(getEnv("BACKOFF_MS", "1000"))
+	backoffMax, _ := strconv.Atoi(getEnv("BACKOFF_MAX_MS", "30000"))
+	retryAfterMax, _ := strconv.Atoi(getEnv("RETRY_AFTER_MAX_MS", "60000"))
+	breakerThreshold, _ := strconv.Atoi(getEnv("BREAKER_FAILURE_THRESHOLD", "5"))
+	extractConcurrency, _ := strconv.Atoi(getEnv("EXTRACT_CONCURRENCY", "4"))
+	extractMaxPages, _ := strconv.Atoi(getEnv("EXTRACT_MAX_PAGES", "100"))
+	breakerCoolDown, _ := strconv.Atoi(getEnv("BREAKER_COOLDOWN_MS", "30000"))
+	ingestWorkers, _ := strconv.Atoi(getEnv("INGEST_WORKERS", "2"))
+	ingestQueueSize, _ := strconv.Atoi(getEnv("INGEST_QUEUE_SIZE", "16"))
+	lookbackDays, _ := strconv.Atoi(getEnv("ATTRIBUTION_LOOKBACK_DAYS", "30"))
+	halfLifeDays, _ := strconv.ParseFloat(getEnv("ATTRIBUTION_HALF_LIFE_DAYS", "7"), 64)
+	outboxMaxAttempts, _ := strconv.Atoi(getEnv("OUTBOX_MAX_ATTEMPTS", "6"))
+	outboxBackoff, _ := strconv.Atoi(getEnv("OUTBOX_BACKOFF_MS", "2000"))
+	outboxBackoffMax, _ := strconv.Atoi(getEnv("OUTBOX_BACKOFF_MAX_MS", "300000"))
+	outboxPoll, _ := strconv.Atoi(getEnv("OUTBOX_POLL_MS", "1000"))
+	exportMaxRecords, _ := strconv.Atoi(getEnv("EXPORT_MAX_RECORDS", "1000"))
+	exportMaxBytes, _ := strconv.Atoi(getEnv("EXPORT_MAX_BYTES", "1048576"))
+	exportMaxRangeDays, _ := strconv.Atoi(getEnv("EXPORT_MAX_RANGE_DAYS", "366"))
+	historyRetention, _ := strconv.Atoi(getEnv("EXPORT_HISTORY_RETENTION_DAYS", "90"))
 
-	return &Config{
+	cfg := &Config{
 		Port:        getEnv("PORT", "8080"),
 		AdsURL:      getEnv("ADS_API_URL", "https://mocki.io/v1/9dcc2981-2bc8-465a-bce3-47767e1278e6"),
 		CrmURL:      getEnv("CRM_API_URL", "https://mocki.io/v1/6a064f10-829d-432c-9f0d-24d5b8cb71c7"),
 		SinkURL:     getEnv("SINK_URL", ""),
 		SinkSecret:  getEnv("SINK_SECRET", "admira_secret_example"),
+		SinkKeyID:   getEnv("SINK_KEY_ID", "default"),
 		Timeout:     time.Duration(timeout) * time.Second,
 		MaxRetries:  maxRetries,
 		BackoffTime: time.Duration(backoff) * time.Millisecond,
-	}, nil
+
+		BackoffMax:    time.Duration(backoffMax) * time.Millisecond,
+		RetryAfterMax: time.Duration(retryAfterMax) * time.Millisecond,
+
+		ExtractConcurrency: extractConcurrency,
+		SourceOnError:      getEnv("SOURCE_ON_ERROR", "abort"),
+		ExtractMaxPages:    extractMaxPages,
+
+		BreakerFailureThreshold: breakerThreshold,
+		BreakerCoolDown:         time.Duration(breakerCoolDown) * time.Millisecond,
+
+		StorageBackend: getEnv("STORAGE_BACKEND", "memory"),
+		DataDir:        getEnv("DATA_DIR", "data"),
+
+		IngestWorkers:   ingestWorkers,
+		IngestQueueSize: ingestQueueSize,
+
+		ScheduleIngest:       getEnv("SCHEDULE_INGEST", ""),
+		ScheduleExport:       getEnv("SCHEDULE_EXPORT", ""),
+		ScheduleMissedPolicy: getEnv("SCHEDULE_MISSED_POLICY", "skip"),
+		ScheduleTimezone:     getEnv("SCHEDULE_TIMEZONE", "UTC"),
+
+		AttributionModel:        getEnv("ATTRIBUTION_MODEL", "last_touch"),
+		AttributionLookbackDays: lookbackDays,
+		AttributionHalfLifeDays: halfLifeDays,
+
+		ChannelRulesFile: getEnv("CHANNEL_RULES_FILE", ""),
+
+		UTMNormalization: getEnv("UTM_NORMALIZATION", "true") == "true",
+		UTMAliasesFile:   getEnv("UTM_ALIASES_FILE", ""),
+
+		CRMDateFormats: splitList(getEnv("CRM_DATE_FORMATS", ""), "|"),
+		WeekStart:      getEnv("WEEK_START", "monday"),
+
+		OutboxMaxAttempts:  outboxMaxAttempts,
+		OutboxBackoff:      time.Duration(outboxBackoff) * time.Millisecond,
+		OutboxBackoffMax:   time.Duration(outboxBackoffMax) * time.Millisecond,
+		OutboxPollInterval: time.Duration(outboxPoll) * time.Millisecond,
+
+		ExportMaxRecords:   exportMaxRecords,
+		ExportMaxBytes:     exportMaxBytes,
+		ExportMaxRangeDays: exportMaxRangeDays,
+		ExportGroupBy:      splitList(getEnv("EXPORT_GROUP_BY", ""), ","),
+
+		ExportHistoryRetentionDays: historyRetention,
+	}
+
+	// Los endpoints de Ads y CRM son siempre los dos primeros orígenes
+	cfg.Sources = []SourceConfig{
+		{Name: "ads", Format: "ads", URL: cfg.AdsURL, OnError: getEnv("ADS_ON_ERROR", "")},
+		{Name: "crm", Format: "crm", URL: cfg.CrmURL, OnError: getEnv("CRM_ON_ERROR", "")},
+	}
+	// Su paginación y extracción incremental se indican como JSON en
+	// ADS_PAGINATION, ADS_INCREMENTAL, CRM_PAGINATION y CRM_INCREMENTAL
+	for i, prefix := range []string{"ADS", "CRM"} {
+		if err := jsonEnv(prefix+"_PAGINATION", &cfg.Sources[i].Pagination); err != nil {
+			return nil, err
+		}
+		if err := jsonEnv(prefix+"_INCREMENTAL", &cfg.Sources[i].Incremental); err != nil {
+			return nil, err
+		}
+	}
+
+	// Orígenes adicionales (otras plataformas de ads o CRMs) desde un fichero JSON
+	if path := getEnv("SOURCES_FILE", ""); path != "" {
+		var extra []SourceConfig
+		if err := loadJSONFile(path, &extra); err != nil {
+			return nil, fmt.Errorf("failed to load sources file: %v", err)
+		}
+		cfg.Sources = append(cfg.Sources, extra...)
+	}
+
+	// SINK_URL sigue configurando el webhook original
+	if cfg.SinkURL != "" {
+		cfg.Sinks = append(cfg.Sinks, SinkConfig{Name: "webhook", Type: "http", Format: "json", URL: cfg.SinkURL})
+	}
+	if path := getEnv("SINKS_FILE", ""); path != "" {
+		var extra []SinkConfig
+		if err := loadJSONFile(path, &extra); err != nil {
+			return nil, fmt.Errorf("failed to load sinks file: %v", err)
+		}
+		cfg.Sinks = append(cfg.Sinks, extra...)
+	}
+
+	return cfg, nil
+}
+
+func loadJSONFile(path string, v interface{}) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// jsonEnv decodifica en v el JSON de una variable de entorno si está definida
+func jsonEnv(key string, v interface{}) error {
+	value := getEnv(key, "")
+	if value == "" {
+		return nil
+	}
+	if err := json.Unmarshal([]byte(value), v); err != nil {
+		return fmt.Errorf("failed to parse %s: %v", key, err)
+	}
+	return nil
+}
+
+// splitList separa una lista de valores ignorando los vacíos
+func splitList(value, sep string) []string {
+	var result []string
+	for _, item := range strings.Split(value, sep) {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
 }
 
 func getEnv(key, defaultValue string) string {
@@ -44,4 +330,4 @@ func getEnv(key, defaultValue string) string {
 		return value
 	}
 	return defaultValue
-}
\ No newline at end of file
+}
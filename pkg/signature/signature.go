@@ -0,0 +1,130 @@
+// Package signature firma y verifica los webhooks que envía el ETL.
+//
+// La firma es un HMAC-SHA256 sobre "timestamp.nonce.body" con el secreto
+// identificado por la cabecera X-Signature-Key-Id:
+//
+//    X-Signature-Timestamp: 1722470400
+//    X-Signature-Nonce:     9f2c4e...
+//    X-Signature-Key-Id:    2025-08
+//    X-Signature:           v1=5d41402abc4b2a76b9719d911017c592...
+//
+// Los receptores pueden importar este paquete y usar un Verifier con todos los
+// secretos activos durante una rotación.
+package signature
+
+import (
+    "crypto/hmac"
+    "crypto/rand"
+    "crypto/sha256"
+    "encoding/hex"
+    "errors"
+    "fmt"
+    "net/http"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// Cabeceras de la firma
+const (
+    HeaderSignature = "X-Signature"
+    HeaderTimestamp = "X-Signature-Timestamp"
+    HeaderNonce     = "X-Signature-Nonce"
+    HeaderKeyID     = "X-Signature-Key-Id"
+)
+
+// Version es el prefijo del esquema de firma actual
+const Version = "v1"
+
+var (
+    ErrMissingHeaders   = errors.New("signature: missing signature headers")
+    ErrUnknownKey       = errors.New("signature: unknown key id")
+    ErrStaleTimestamp   = errors.New("signature: timestamp outside tolerance")
+    ErrInvalidSignature = errors.New("signature: invalid signature")
+    ErrReplayedNonce    = errors.New("signature: nonce already used")
+)
+
+// Key es un secreto identificado por un key id
+type Key struct {
+    ID     string `json:"id"`
+    Secret string `json:"secret"`
+}
+
+// ParseKeys lee una lista "id:secret,id:secret"
+func ParseKeys(value string) ([]Key, error) {
+    var keys []Key
+    for _, item := range strings.Split(value, ",") {
+        item = strings.TrimSpace(item)
+        if item == "" {
+            continue
+        }
+        id, secret, ok := strings.Cut(item, ":")
+        if !ok || id == "" || secret == "" {
+            return nil, fmt.Errorf("signature: invalid key %q, expected id:secret", item)
+        }
+        keys = append(keys, Key{ID: id, Secret: secret})
+    }
+    return keys, nil
+}
+
+// Compute calcula la firma hexadecimal de un mensaje
+func Compute(secret string, timestamp int64, nonce string, body []byte) string {
+    h := hmac.New(sha256.New, []byte(secret))
+    h.Write([]byte(strconv.FormatInt(timestamp, 10)))
+    h.Write([]byte("."))
+    h.Write([]byte(nonce))
+    h.Write([]byte("."))
+    h.Write(body)
+    return hex.EncodeToString(h.Sum(nil))
+}
+
+// Signer firma mensajes con un único secreto activo
+type Signer struct {
+    key Key
+    now func() time.Time
+}
+
+func NewSigner(key Key) *Signer {
+    return &Signer{key: key, now: time.Now}
+}
+
+// KeyID devuelve el identificador de la clave con la que firma
+func (s *Signer) KeyID() string {
+    return s.key.ID
+}
+
+// Headers devuelve las cabeceras de firma de un cuerpo con un nonce nuevo
+func (s *Signer) Headers(body []byte) (http.Header, error) {
+    nonce, err := newNonce()
+    if err != nil {
+        return nil, err
+    }
+    timestamp := s.now().Unix()
+
+    header := http.Header{}
+    header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+    header.Set(HeaderNonce, nonce)
+    header.Set(HeaderKeyID, s.key.ID)
+    header.Set(HeaderSignature, Version+"="+Compute(s.key.Secret, timestamp, nonce, body))
+    return header, nil
+}
+
+// SignRequest añade las cabeceras de firma a una petición
+func (s *Signer) SignRequest(req *http.Request, body []byte) error {
+    header, err := s.Headers(body)
+    if err != nil {
+        return err
+    }
+    for name, values := range header {
+        req.Header[name] = values
+    }
+    return nil
+}
+
+func newNonce() (string, error) {
+    b := make([]byte, 16)
+    if _, err := rand.Read(b); err != nil {
+        return "", fmt.Errorf("signature: failed to generate nonce: %v", err)
+    }
+    return hex.EncodeToString(b), nil
+}
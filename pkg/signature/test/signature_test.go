@@ -0,0 +1,72 @@
+package test
+
+import (
+    "errors"
+    "testing"
+    "time"
+
+    "admira-etl/pkg/signature"
+)
+
+func TestVerifier_AcceptsAnyActiveKey(t *testing.T) {
+    body := []byte(`{"date":"2025-08-01"}`)
+    verifier := signature.NewVerifier([]signature.Key{
+        {ID: "2025-07", Secret: "old"},
+        {ID: "2025-08", Secret: "new"},
+    })
+
+    for _, key := range []signature.Key{{ID: "2025-07", Secret: "old"}, {ID: "2025-08", Secret: "new"}} {
+        header, err := signature.NewSigner(key).Headers(body)
+        if err != nil {
+            t.Fatalf("Headers failed: %v", err)
+        }
+        if err := verifier.Verify(header, body); err != nil {
+            t.Errorf("Key %s should verify: %v", key.ID, err)
+        }
+    }
+}
+
+func TestVerifier_Rejections(t *testing.T) {
+    body := []byte(`{"date":"2025-08-01"}`)
+    key := signature.Key{ID: "k1", Secret: "secret"}
+
+    now := time.Now()
+    verifier := signature.NewVerifier([]signature.Key{key}, signature.WithClock(func() time.Time { return now }))
+
+    header, _ := signature.NewSigner(key).Headers(body)
+    if err := verifier.Verify(header, []byte(`{"date":"2025-08-02"}`)); !errors.Is(err, signature.ErrInvalidSignature) {
+        t.Errorf("Expected invalid signature for a tampered body, got %v", err)
+    }
+    if err := verifier.Verify(header, body); err != nil {
+        t.Fatalf("Expected valid signature, got %v", err)
+    }
+    if err := verifier.Verify(header, body); !errors.Is(err, signature.ErrReplayedNonce) {
+        t.Errorf("Expected replayed nonce, got %v", err)
+    }
+
+    unknown, _ := signature.NewSigner(signature.Key{ID: "k2", Secret: "secret"}).Headers(body)
+    if err := verifier.Verify(unknown, body); !errors.Is(err, signature.ErrUnknownKey) {
+        t.Errorf("Expected unknown key, got %v", err)
+    }
+
+    stale := signature.NewVerifier([]signature.Key{key}, signature.WithClock(func() time.Time { return now.Add(10 * time.Minute) }))
+    fresh, _ := signature.NewSigner(key).Headers(body)
+    if err := stale.Verify(fresh, body); !errors.Is(err, signature.ErrStaleTimestamp) {
+        t.Errorf("Expected stale timestamp, got %v", err)
+    }
+
+    fresh.Del(signature.HeaderNonce)
+    if err := verifier.Verify(fresh, body); !errors.Is(err, signature.ErrMissingHeaders) {
+        t.Errorf("Expected missing headers, got %v", err)
+    }
+}
+
+func TestParseKeys(t *testing.T) {
+    keys, err := signature.ParseKeys("2025-08:new, 2025-07:old")
+    if err != nil || len(keys) != 2 || keys[0].ID != "2025-08" || keys[1].Secret != "old" {
+        t.Errorf("Unexpected keys %+v (%v)", keys, err)
+    }
+    if _, err := signature.ParseKeys("missing-secret"); err == nil {
+        t.Errorf("Expected error for a key without secret")
+    }
+}
@@ -0,0 +1,124 @@
+package signature
+
+import (
+    "bytes"
+    "crypto/hmac"
+    "fmt"
+    "io"
+    "net/http"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+)
+
+// DefaultTolerance es la antigüedad máxima aceptada de un timestamp
+const DefaultTolerance = 5 * time.Minute
+
+// Verifier comprueba firmas con cualquiera de los secretos activos y rechaza
+// timestamps fuera de la tolerancia y nonces repetidos
+type Verifier struct {
+    keys      map[string]string
+    tolerance time.Duration
+    now       func() time.Time
+
+    mu     sync.Mutex
+    nonces map[string]time.Time
+}
+
+// Option configura un Verifier
+type Option func(*Verifier)
+
+// WithTolerance cambia la antigüedad (y adelanto) máximos del timestamp
+func WithTolerance(d time.Duration) Option {
+    return func(v *Verifier) { v.tolerance = d }
+}
+
+// WithClock permite fijar el reloj, útil en tests
+func WithClock(now func() time.Time) Option {
+    return func(v *Verifier) { v.now = now }
+}
+
+func NewVerifier(keys []Key, options ...Option) *Verifier {
+    v := &Verifier{
+        keys:      make(map[string]string),
+        tolerance: DefaultTolerance,
+        now:       time.Now,
+        nonces:    make(map[string]time.Time),
+    }
+    for _, key := range keys {
+        v.keys[key.ID] = key.Secret
+    }
+    for _, option := range options {
+        option(v)
+    }
+    return v
+}
+
+// Verify comprueba las cabeceras de firma de un cuerpo. Un nonce solo se
+// acepta una vez mientras su timestamp esté dentro de la tolerancia.
+func (v *Verifier) Verify(header http.Header, body []byte) error {
+    timestampStr := header.Get(HeaderTimestamp)
+    nonce := header.Get(HeaderNonce)
+    keyID := header.Get(HeaderKeyID)
+    signature := header.Get(HeaderSignature)
+    if timestampStr == "" || nonce == "" || keyID == "" || signature == "" {
+        return ErrMissingHeaders
+    }
+
+    secret, ok := v.keys[keyID]
+    if !ok {
+        return fmt.Errorf("%w: %s", ErrUnknownKey, keyID)
+    }
+
+    timestamp, err := strconv.ParseInt(timestampStr, 10, 64)
+    if err != nil {
+        return fmt.Errorf("%w: invalid timestamp %q", ErrStaleTimestamp, timestampStr)
+    }
+    now := v.now()
+    age := now.Sub(time.Unix(timestamp, 0))
+    if age > v.tolerance || age < -v.tolerance {
+        return ErrStaleTimestamp
+    }
+
+    provided := strings.TrimPrefix(signature, Version+"=")
+    expected := Compute(secret, timestamp, nonce, body)
+    if !hmac.Equal([]byte(provided), []byte(expected)) {
+        return ErrInvalidSignature
+    }
+
+    return v.useNonce(keyID+":"+nonce, now)
+}
+
+// VerifyRequest lee el cuerpo de la petición, lo verifica y lo devuelve.
+// El cuerpo queda disponible de nuevo en req.Body.
+func (v *Verifier) VerifyRequest(req *http.Request) ([]byte, error) {
+    body, err := io.ReadAll(req.Body)
+    if err != nil {
+        return nil, fmt.Errorf("signature: failed to read body: %v", err)
+    }
+    req.Body.Close()
+    req.Body = io.NopCloser(bytes.NewReader(body))
+
+    if err := v.Verify(req.Header, body); err != nil {
+        return nil, err
+    }
+    return body, nil
+}
+
+// useNonce registra el nonce y purga los que ya no pueden ser válidos
+func (v *Verifier) useNonce(nonce string, now time.Time) error {
+    v.mu.Lock()
+    defer v.mu.Unlock()
+
+    for n, seen := range v.nonces {
+        if now.Sub(seen) > 2*v.tolerance {
+            delete(v.nonces, n)
+        }
+    }
+    if _, used := v.nonces[nonce]; used {
+        return ErrReplayedNonce
+    }
+    v.nonces[nonce] = now
+    return nil
+}